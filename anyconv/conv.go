@@ -5,6 +5,7 @@ package anyconv
 import (
 	"errors"
 	"math"
+	"math/rand"
 
 	"github.com/unixpickle/anydiff"
 	"github.com/unixpickle/anyvec"
@@ -70,10 +71,17 @@ func DeserializeConv(d []byte) (*Conv, error) {
 // InitRand the biases an filters in a randomized fashion
 // and sets the Conver.
 func (c *Conv) InitRand(cr anyvec.Creator) {
+	c.InitRandSource(cr, nil)
+}
+
+// InitRandSource is like InitRand, but it uses the given
+// source of randomness.
+// If r is nil, the global source from math/rand is used.
+func (c *Conv) InitRandSource(cr anyvec.Creator, r *rand.Rand) {
 	c.InitZero(cr)
 
 	normalizer := 1 / math.Sqrt(float64(c.FilterWidth*c.FilterHeight*c.InputDepth))
-	anyvec.Rand(c.Filters.Vector, anyvec.Normal, nil)
+	anyvec.Rand(c.Filters.Vector, anyvec.Normal, r)
 	c.Filters.Vector.Scale(cr.MakeNumeric(normalizer))
 }
 
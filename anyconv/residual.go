@@ -1,6 +1,8 @@
 package anyconv
 
 import (
+	"math/rand"
+
 	"github.com/unixpickle/anydiff"
 	"github.com/unixpickle/anynet"
 	"github.com/unixpickle/essentials"
@@ -61,6 +63,12 @@ func (r *Residual) Parameters() []*anydiff.Var {
 	return n.Parameters()
 }
 
+// SetRand sets the source of randomness for the Layer
+// and the Projection if they implement anynet.RandSetter.
+func (r *Residual) SetRand(rng *rand.Rand) {
+	anynet.SetAllRand(rng, r.Layer, r.Projection)
+}
+
 // SerializerType returns the unique ID used to serialize
 // a Residual with the serializer package.
 func (r *Residual) SerializerType() string {
@@ -0,0 +1,159 @@
+package anyctc
+
+import (
+	"math"
+
+	"github.com/unixpickle/anydiff/anyseq"
+)
+
+// A Segment is a span of frames during which a label is
+// emitted.
+type Segment struct {
+	Label int
+
+	// Start is the first frame of the segment.
+	Start int
+
+	// End is one past the last frame of the segment.
+	End int
+}
+
+// An Alignment is a frame-level path through a sequence of
+// CTC outputs.
+type Alignment struct {
+	// Path stores the symbol emitted at each frame.
+	// As in the network outputs, the blank symbol is
+	// represented by the last index.
+	Path []int
+
+	// Segments stores one segment per label, in order.
+	Segments []*Segment
+
+	// LogProb is the log probability of Path.
+	LogProb float64
+}
+
+// ForcedAlign finds the most likely frame-level path for
+// each output sequence, given the sequence's label.
+//
+// The last entry of each input vector is the log of the
+// probability of the blank symbol.
+//
+// If a label cannot be produced by its sequence (e.g.
+// because the label is too long), the corresponding
+// alignment is nil.
+func ForcedAlign(seqs anyseq.Seq, labels [][]int) []*Alignment {
+	separated := anyseq.SeparateSeqs(batchesTo64(seqs.Output()))
+	if len(separated) != len(labels) {
+		panic("label count must match sequence count")
+	}
+	var res []*Alignment
+	for i, seq := range separated {
+		floatSeq := make([][]float64, len(seq))
+		for j, x := range seq {
+			floatSeq[j] = x.Data().([]float64)
+		}
+		res = append(res, viterbiAlign(floatSeq, labels[i]))
+	}
+	return res
+}
+
+// Timestamps decodes the output sequences with BestLabels
+// and returns a segment for each decoded label.
+//
+// The blankThresh argument is passed to BestLabels.
+func Timestamps(seqs anyseq.Seq, blankThresh float64) [][]*Segment {
+	labels := BestLabels(seqs, blankThresh)
+	var res [][]*Segment
+	for _, a := range ForcedAlign(seqs, labels) {
+		if a == nil {
+			// Should never happen, since the decoded labels
+			// are always possible.
+			res = append(res, nil)
+		} else {
+			res = append(res, a.Segments)
+		}
+	}
+	return res
+}
+
+// viterbiAlign finds the most likely path through the
+// blank-infused label.
+func viterbiAlign(seq [][]float64, label []int) *Alignment {
+	if len(seq) == 0 {
+		if len(label) == 0 {
+			return &Alignment{}
+		}
+		return nil
+	}
+
+	numStates := len(label)*2 + 1
+	blank := len(seq[0]) - 1
+	stateSymbol := func(s int) int {
+		if s%2 == 0 {
+			return blank
+		}
+		return label[s/2]
+	}
+
+	// backPtrs[t][s] is the state at time t-1 on the best
+	// path to state s at time t.
+	backPtrs := make([][]int, len(seq))
+	probs := make([]float64, numStates)
+	for s := range probs {
+		probs[s] = math.Inf(-1)
+	}
+	probs[0] = seq[0][blank]
+	if numStates > 1 {
+		probs[1] = seq[0][label[0]]
+	}
+
+	for t := 1; t < len(seq); t++ {
+		backPtrs[t] = make([]int, numStates)
+		newProbs := make([]float64, numStates)
+		for s := range newProbs {
+			bestPrev := s
+			if s > 0 && probs[s-1] > probs[bestPrev] {
+				bestPrev = s - 1
+			}
+			if s%2 == 1 && s > 1 && label[s/2] != label[s/2-1] &&
+				probs[s-2] > probs[bestPrev] {
+				bestPrev = s - 2
+			}
+			backPtrs[t][s] = bestPrev
+			newProbs[s] = probs[bestPrev] + seq[t][stateSymbol(s)]
+		}
+		probs = newProbs
+	}
+
+	state := numStates - 1
+	if numStates > 1 && probs[numStates-2] > probs[state] {
+		state = numStates - 2
+	}
+	if math.IsInf(probs[state], -1) {
+		return nil
+	}
+
+	res := &Alignment{
+		Path:     make([]int, len(seq)),
+		Segments: make([]*Segment, len(label)),
+		LogProb:  probs[state],
+	}
+	for i, l := range label {
+		res.Segments[i] = &Segment{Label: l, Start: -1}
+	}
+	for t := len(seq) - 1; t >= 0; t-- {
+		res.Path[t] = stateSymbol(state)
+		if state%2 == 1 {
+			seg := res.Segments[state/2]
+			if seg.Start < 0 {
+				seg.End = t + 1
+			}
+			seg.Start = t
+		}
+		if t > 0 {
+			state = backPtrs[t][state]
+		}
+	}
+	return res
+}
@@ -0,0 +1,147 @@
+package anyctc
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestForcedAlignOutput(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	probs := [][]float64{
+		{0.1, 0.1, 0.8},
+		{0.7, 0.2, 0.1},
+		{0.6, 0.3, 0.1},
+		{0.2, 0.2, 0.6},
+		{0.1, 0.8, 0.1},
+		{0.1, 0.1, 0.8},
+	}
+	seq := make([]anyvec.Vector, len(probs))
+	for i, p := range probs {
+		logs := make([]float64, len(p))
+		for j, x := range p {
+			logs[j] = math.Log(x)
+		}
+		seq[i] = c.MakeVectorData(logs)
+	}
+	in := anyseq.ConstSeqList(c, [][]anyvec.Vector{seq, seq, seq})
+	actual := ForcedAlign(in, [][]int{{0, 1}, {1, 1, 1, 1}, {1, 0}})
+
+	expected := &Alignment{
+		Path: []int{2, 0, 0, 2, 1, 2},
+		Segments: []*Segment{
+			{Label: 0, Start: 1, End: 3},
+			{Label: 1, Start: 4, End: 5},
+		},
+		LogProb: math.Log(0.8 * 0.7 * 0.6 * 0.6 * 0.8 * 0.8),
+	}
+	if math.Abs(actual[0].LogProb-expected.LogProb) > 1e-5 {
+		t.Errorf("expected log prob %f but got %f", expected.LogProb, actual[0].LogProb)
+	}
+	actual[0].LogProb = expected.LogProb
+	if !reflect.DeepEqual(actual[0], expected) {
+		t.Errorf("expected %v but got %v", expected, actual[0])
+	}
+
+	if actual[1] != nil {
+		t.Errorf("impossible label should give nil but got %v", actual[1])
+	}
+
+	if actual[2] == nil || !reflect.DeepEqual(collapsePath(actual[2].Path, 2), []int{1, 0}) {
+		t.Errorf("unexpected alignment for reversed label: %v", actual[2])
+	}
+}
+
+func TestForcedAlignOptimal(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	for i := 0; i < 10; i++ {
+		label := make([]int, 1+rand.Intn(3))
+		for j := range label {
+			label[j] = rand.Intn(2)
+		}
+		_, resSeq := createTestSequence(c, len(label)+rand.Intn(3), 2)
+		var seq []anyvec.Vector
+		for _, x := range resSeq {
+			seq = append(seq, x.Output())
+		}
+		in := anyseq.ConstSeqList(c, [][]anyvec.Vector{seq})
+		actual := ForcedAlign(in, [][]int{label})[0]
+
+		expected := bestPathProb(seq, label, nil)
+		if math.IsInf(expected, -1) {
+			if actual != nil {
+				t.Errorf("expected nil alignment but got %v", actual)
+			}
+			continue
+		}
+		if actual == nil {
+			t.Errorf("expected log prob %f but got nil", expected)
+			continue
+		}
+		if math.Abs(actual.LogProb-expected) > 1e-5 {
+			t.Errorf("expected log prob %f but got %f", expected, actual.LogProb)
+		}
+		if !reflect.DeepEqual(collapsePath(actual.Path, 2), label) {
+			t.Errorf("path %v does not produce label %v", actual.Path, label)
+		}
+	}
+}
+
+func TestTimestamps(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	seq := []anyvec.Vector{
+		c.MakeVectorData([]float64{-9.2, -9.2, -0.0002}),
+		c.MakeVectorData([]float64{-0.0002, -9.2, -9.2}),
+		c.MakeVectorData([]float64{-0.0002, -9.2, -9.2}),
+		c.MakeVectorData([]float64{-9.2, -9.2, -0.0002}),
+		c.MakeVectorData([]float64{-9.2, -0.0002, -9.2}),
+	}
+	in := anyseq.ConstSeqList(c, [][]anyvec.Vector{seq, seq[:1]})
+	actual := Timestamps(in, -1e-3)
+	expected := [][]*Segment{
+		{
+			{Label: 0, Start: 1, End: 3},
+			{Label: 1, Start: 4, End: 5},
+		},
+		{},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+}
+
+// bestPathProb computes the log probability of the most
+// likely path which produces the label by brute force.
+func bestPathProb(seq []anyvec.Vector, label, prefix []int) float64 {
+	if len(prefix) == len(seq) {
+		if !reflect.DeepEqual(collapsePath(prefix, seq[0].Len()-1), label) {
+			return math.Inf(-1)
+		}
+		var res float64
+		for t, sym := range prefix {
+			res += seq[t].Data().([]float64)[sym]
+		}
+		return res
+	}
+	res := math.Inf(-1)
+	for sym := 0; sym < seq[0].Len(); sym++ {
+		p := append(append([]int{}, prefix...), sym)
+		res = math.Max(res, bestPathProb(seq, label, p))
+	}
+	return res
+}
+
+func collapsePath(path []int, blank int) []int {
+	res := []int{}
+	for i, sym := range path {
+		if sym != blank && (i == 0 || path[i-1] != sym) {
+			res = append(res, sym)
+		}
+	}
+	return res
+}
@@ -0,0 +1,229 @@
+package anyctc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/essentials"
+)
+
+// EditCounts stores the edit operations needed to turn a
+// predicted sequence into a reference sequence.
+type EditCounts struct {
+	Substitutions int
+	Insertions    int
+	Deletions     int
+
+	// RefLen is the length of the reference sequence.
+	RefLen int
+}
+
+// EditDistance computes the minimum number of edits needed
+// to turn hyp into ref.
+//
+// Insertions are tokens in hyp which are not in ref, and
+// deletions are tokens in ref which are missing from hyp.
+func EditDistance(ref, hyp []string) EditCounts {
+	// dists[i][j] is the distance between ref[:i] and
+	// hyp[:j].
+	dists := make([][]int, len(ref)+1)
+	for i := range dists {
+		dists[i] = make([]int, len(hyp)+1)
+		dists[i][0] = i
+	}
+	for j := range dists[0] {
+		dists[0][j] = j
+	}
+	for i := 1; i <= len(ref); i++ {
+		for j := 1; j <= len(hyp); j++ {
+			sub := dists[i-1][j-1]
+			if ref[i-1] != hyp[j-1] {
+				sub++
+			}
+			dists[i][j] = minInt(sub, minInt(dists[i-1][j], dists[i][j-1])+1)
+		}
+	}
+
+	res := EditCounts{RefLen: len(ref)}
+	i, j := len(ref), len(hyp)
+	for i > 0 || j > 0 {
+		if i > 0 && j > 0 {
+			if ref[i-1] == hyp[j-1] && dists[i][j] == dists[i-1][j-1] {
+				i, j = i-1, j-1
+				continue
+			} else if dists[i][j] == dists[i-1][j-1]+1 {
+				res.Substitutions++
+				i, j = i-1, j-1
+				continue
+			}
+		}
+		if i > 0 && dists[i][j] == dists[i-1][j]+1 {
+			res.Deletions++
+			i--
+		} else {
+			res.Insertions++
+			j--
+		}
+	}
+	return res
+}
+
+// Errors returns the total number of edits.
+func (e EditCounts) Errors() int {
+	return e.Substitutions + e.Insertions + e.Deletions
+}
+
+// Rate returns the number of edits divided by the length
+// of the reference.
+//
+// If the reference is empty, the rate is 0 when there are
+// no edits and 1 otherwise.
+func (e EditCounts) Rate() float64 {
+	if e.RefLen == 0 {
+		if e.Errors() == 0 {
+			return 0
+		}
+		return 1
+	}
+	return float64(e.Errors()) / float64(e.RefLen)
+}
+
+// Add adds the counts from e1 to e.
+func (e *EditCounts) Add(e1 EditCounts) {
+	e.Substitutions += e1.Substitutions
+	e.Insertions += e1.Insertions
+	e.Deletions += e1.Deletions
+	e.RefLen += e1.RefLen
+}
+
+// A SampleEval stores evaluation results for one sample.
+type SampleEval struct {
+	// Index is the index of the sample in the SampleList.
+	Index int
+
+	Reference  string
+	Prediction string
+
+	Chars EditCounts
+	Words EditCounts
+}
+
+// An Evaluation stores the results of an Evaluator.
+type Evaluation struct {
+	// Chars and Words store the total edit counts over
+	// all samples.
+	Chars EditCounts
+	Words EditCounts
+
+	Samples []*SampleEval
+}
+
+// CER returns the character error rate.
+func (e *Evaluation) CER() float64 {
+	return e.Chars.Rate()
+}
+
+// WER returns the word error rate.
+func (e *Evaluation) WER() float64 {
+	return e.Words.Rate()
+}
+
+// An Evaluator measures the error rates of a CTC model.
+//
+// Each label is converted to text by looking up every
+// label ID in the Vocab and joining the resulting tokens
+// with the Separator.
+// Character error rates are computed over the characters
+// of the text, and word error rates are computed over the
+// whitespace-separated words of the text.
+type Evaluator struct {
+	Func  func(in anyseq.Seq) anyseq.Seq
+	Vocab []string
+
+	// Separator is inserted between tokens.
+	// For example, a word-level vocabulary might use " ".
+	Separator string
+
+	// BatchSize is the number of samples to evaluate at
+	// once.
+	// If it is 0 (or negative), all samples are evaluated
+	// at once.
+	BatchSize int
+
+	// BlankThresh is passed to BestLabels.
+	BlankThresh float64
+}
+
+// Evaluate runs the model on every sample in the list and
+// computes the resulting error rates.
+func (e *Evaluator) Evaluate(s SampleList) (*Evaluation, error) {
+	batchSize := e.BatchSize
+	if batchSize <= 0 {
+		batchSize = s.Len()
+	}
+	res := &Evaluation{}
+	var trainer Trainer
+	for i := 0; i < s.Len(); i += batchSize {
+		end := i + batchSize
+		if end > s.Len() {
+			end = s.Len()
+		}
+		batch, err := trainer.Fetch(s.Slice(i, end))
+		if err != nil {
+			return nil, essentials.AddCtx("evaluate", err)
+		}
+		b := batch.(*Batch)
+		preds := BestLabels(e.Func(b.Inputs), e.BlankThresh)
+		if len(preds) != len(b.Labels) {
+			return nil, fmt.Errorf("evaluate: expected %d outputs but got %d",
+				len(b.Labels), len(preds))
+		}
+		for j, label := range b.Labels {
+			sample := &SampleEval{Index: i + j}
+			sample.Reference, err = e.Text(label)
+			if err != nil {
+				return nil, essentials.AddCtx("evaluate", err)
+			}
+			sample.Prediction, err = e.Text(preds[j])
+			if err != nil {
+				return nil, essentials.AddCtx("evaluate", err)
+			}
+			sample.Chars = EditDistance(splitChars(sample.Reference),
+				splitChars(sample.Prediction))
+			sample.Words = EditDistance(strings.Fields(sample.Reference),
+				strings.Fields(sample.Prediction))
+			res.Chars.Add(sample.Chars)
+			res.Words.Add(sample.Words)
+			res.Samples = append(res.Samples, sample)
+		}
+	}
+	return res, nil
+}
+
+// Text converts a label to text using the vocabulary.
+func (e *Evaluator) Text(label []int) (string, error) {
+	tokens := make([]string, len(label))
+	for i, id := range label {
+		if id < 0 || id >= len(e.Vocab) {
+			return "", fmt.Errorf("label %d not in vocabulary", id)
+		}
+		tokens[i] = e.Vocab[id]
+	}
+	return strings.Join(tokens, e.Separator), nil
+}
+
+func splitChars(s string) []string {
+	var res []string
+	for _, r := range s {
+		res = append(res, string(r))
+	}
+	return res
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,127 @@
+package anyctc
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anynet/anysgd"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		Ref      string
+		Hyp      string
+		Expected EditCounts
+	}{
+		{"a b c", "a b c", EditCounts{RefLen: 3}},
+		{"a b c", "a x c", EditCounts{Substitutions: 1, RefLen: 3}},
+		{"a b c", "a c", EditCounts{Deletions: 1, RefLen: 3}},
+		{"a b c", "a b c d", EditCounts{Insertions: 1, RefLen: 3}},
+		{"", "a b", EditCounts{Insertions: 2}},
+		{"a b", "", EditCounts{Deletions: 2, RefLen: 2}},
+		{"k i t t e n", "s i t t i n g", EditCounts{Substitutions: 2, Insertions: 1,
+			RefLen: 6}},
+	}
+	for _, test := range tests {
+		actual := EditDistance(strings.Fields(test.Ref), strings.Fields(test.Hyp))
+		if actual != test.Expected {
+			t.Errorf("%q -> %q: expected %+v but got %+v", test.Ref, test.Hyp,
+				test.Expected, actual)
+		}
+	}
+}
+
+func TestEvaluator(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	list := &testSampleList{
+		testLabeledInput(c, []int{0, 2, 1}, []int{0, 2, 1}),
+		testLabeledInput(c, []int{1, 2, 0, 0}, []int{1, 2, 0}),
+		testLabeledInput(c, []int{0}, []int{1, 2, 1}),
+	}
+	evaluator := &Evaluator{
+		Func:        func(in anyseq.Seq) anyseq.Seq { return in },
+		Vocab:       []string{"a", " ", "b"},
+		BatchSize:   2,
+		BlankThresh: -1e-3,
+	}
+	eval, err := evaluator.Evaluate(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// References are "ab ", " baa", and "a", and
+	// predictions are "ab ", " ba", and " b ".
+	expectedChars := EditCounts{Substitutions: 1, Deletions: 1, Insertions: 2, RefLen: 8}
+	if eval.Chars != expectedChars {
+		t.Errorf("expected chars %+v but got %+v", expectedChars, eval.Chars)
+	}
+	expectedWords := EditCounts{Substitutions: 2, RefLen: 3}
+	if eval.Words != expectedWords {
+		t.Errorf("expected words %+v but got %+v", expectedWords, eval.Words)
+	}
+	if math.Abs(eval.CER()-4.0/8) > 1e-8 || math.Abs(eval.WER()-2.0/3) > 1e-8 {
+		t.Errorf("unexpected rates: CER=%f WER=%f", eval.CER(), eval.WER())
+	}
+	if len(eval.Samples) != 3 {
+		t.Fatalf("expected 3 samples but got %d", len(eval.Samples))
+	}
+	for i, sample := range eval.Samples {
+		if sample.Index != i {
+			t.Errorf("sample %d: got index %d", i, sample.Index)
+		}
+	}
+	if eval.Samples[1].Prediction != " ba" || eval.Samples[1].Reference != " baa" {
+		t.Errorf("unexpected sample: %+v", eval.Samples[1])
+	}
+
+	// A negative batch size evaluates everything at once.
+	evaluator.BatchSize = -1
+	fullEval, err := evaluator.Evaluate(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fullEval.Chars != expectedChars || fullEval.Words != expectedWords {
+		t.Errorf("unexpected counts for full batch: %+v, %+v", fullEval.Chars,
+			fullEval.Words)
+	}
+}
+
+type testSampleList []*Sample
+
+func (t testSampleList) Len() int {
+	return len(t)
+}
+
+func (t testSampleList) Swap(i, j int) {
+	t[i], t[j] = t[j], t[i]
+}
+
+func (t testSampleList) Slice(i, j int) anysgd.SampleList {
+	return append(testSampleList{}, t[i:j]...)
+}
+
+func (t testSampleList) GetSample(idx int) (*Sample, error) {
+	return t[idx], nil
+}
+
+func (t testSampleList) Creator() anyvec.Creator {
+	return anyvec64.CurrentCreator()
+}
+
+// testLabeledInput creates a sample whose input is a
+// confident network output for the predicted labels.
+func testLabeledInput(c anyvec.Creator, label, predicted []int) *Sample {
+	var input []anyvec.Vector
+	for _, l := range predicted {
+		for _, sym := range []int{l, 3} {
+			vec := []float64{-10, -10, -10, -10}
+			vec[sym] = 0
+			input = append(input, c.MakeVectorData(vec))
+		}
+	}
+	return &Sample{Input: input, Label: label}
+}
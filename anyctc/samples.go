@@ -14,6 +14,10 @@ type Sample struct {
 
 // A SampleList is an anysgd.SampleList that produces
 // CTC samples.
+//
+// Lists which also implement anysgd.LenSampleList can be
+// wrapped in an anysgd.BucketSampleList to batch together
+// sequences of similar lengths.
 type SampleList interface {
 	anysgd.SampleList
 
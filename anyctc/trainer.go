@@ -2,9 +2,11 @@ package anyctc
 
 import (
 	"errors"
+	"math/rand"
 
 	"github.com/unixpickle/anydiff"
 	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anynet"
 	"github.com/unixpickle/anynet/anysgd"
 	"github.com/unixpickle/anyvec"
 	"github.com/unixpickle/essentials"
@@ -23,6 +25,10 @@ type Trainer struct {
 	Func   func(anyseq.Seq) anyseq.Seq
 	Params []*anydiff.Var
 
+	// Model, if non-nil, is the network behind Func.
+	// SetRand passes its source of randomness on to it.
+	Model interface{}
+
 	// Average indicates whether or not the total cost should
 	// be averaged before computing gradients.
 	// This affects gradients, LastCost, and the output of
@@ -84,3 +90,10 @@ func (t *Trainer) Gradient(b anysgd.Batch) anydiff.Grad {
 	t.LastCost = lc
 	return grad
 }
+
+// SetRand sets the source of randomness for t.Model if
+// it is an anynet.RandSetter.
+// This way, SGD can make dropout reproducible.
+func (t *Trainer) SetRand(r *rand.Rand) {
+	anynet.SetAllRand(r, t.Model)
+}
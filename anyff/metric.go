@@ -0,0 +1,156 @@
+package anyff
+
+import (
+	"errors"
+	"math/rand"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anysgd"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+)
+
+// A MetricSample is a training sample for metric learning,
+// where a network learns to embed inputs such that related
+// inputs are close together.
+//
+// Samples with equal labels are positives for each other,
+// while samples with different labels are negatives.
+// For example, to train with pairs of augmented views
+// (as in SimCLR), give both views of an image the same
+// label and give every image a different label.
+type MetricSample struct {
+	Input anyvec.Vector
+	Label int
+}
+
+// A MetricSampleList is an anysgd.SampleList that produces
+// metric learning samples.
+//
+// Since positives and negatives are found within each
+// batch, batches should contain several samples for each
+// label.
+type MetricSampleList interface {
+	anysgd.SampleList
+
+	GetSample(idx int) (*MetricSample, error)
+}
+
+// A MetricSliceSampleList is a concrete MetricSampleList
+// with predetermined samples.
+type MetricSliceSampleList []*MetricSample
+
+// Len returns the number of samples.
+func (m MetricSliceSampleList) Len() int {
+	return len(m)
+}
+
+// Swap swaps two samples.
+func (m MetricSliceSampleList) Swap(i, j int) {
+	m[i], m[j] = m[j], m[i]
+}
+
+// Slice copies a sub-slice of the list.
+func (m MetricSliceSampleList) Slice(i, j int) anysgd.SampleList {
+	return append(MetricSliceSampleList{}, m[i:j]...)
+}
+
+// Subset copies the samples at the given indices.
+func (m MetricSliceSampleList) Subset(indices []int) anysgd.SampleList {
+	res := make(MetricSliceSampleList, len(indices))
+	for i, idx := range indices {
+		res[i] = m[idx]
+	}
+	return res
+}
+
+// GetSample returns the sample at the index.
+func (m MetricSliceSampleList) GetSample(idx int) (*MetricSample, error) {
+	return m[idx], nil
+}
+
+// A MetricBatch stores a batch of metric learning samples
+// in a packed format.
+type MetricBatch struct {
+	Inputs *anydiff.Const
+	Labels []int
+	Num    int
+}
+
+// A MetricTrainer can construct batches, compute
+// gradients, and tally up costs for networks which
+// produce embeddings.
+type MetricTrainer struct {
+	Net    anynet.Layer
+	Cost   anynet.MetricCost
+	Params []*anydiff.Var
+
+	// Average indicates whether or not the total cost should
+	// be averaged over the anchors in a batch before
+	// computing gradients.
+	// This affects gradients, LastCost, and the output of
+	// TotalCost().
+	Average bool
+
+	// After every gradient computation, LastCost is set to
+	// the cost from the batch.
+	LastCost anyvec.Numeric
+}
+
+// Fetch produces a *MetricBatch for the subset of
+// samples.
+// The s argument must implement MetricSampleList.
+// The batch may not be empty.
+func (m *MetricTrainer) Fetch(s anysgd.SampleList) (anysgd.Batch, error) {
+	if s.Len() == 0 {
+		return nil, errors.New("fetch batch: empty batch")
+	}
+	l := s.(MetricSampleList)
+	ins := make([]anyvec.Vector, l.Len())
+	labels := make([]int, l.Len())
+	for i := 0; i < l.Len(); i++ {
+		sample, err := l.GetSample(i)
+		if err != nil {
+			return nil, essentials.AddCtx("fetch batch", err)
+		}
+		ins[i] = sample.Input
+		labels[i] = sample.Label
+	}
+	return &MetricBatch{
+		Inputs: anydiff.NewConst(ins[0].Creator().Concat(ins...)),
+		Labels: labels,
+		Num:    l.Len(),
+	}, nil
+}
+
+// TotalCost computes the total cost for the *MetricBatch.
+func (m *MetricTrainer) TotalCost(batch anysgd.Batch) anydiff.Res {
+	b := batch.(*MetricBatch)
+	embeddings := m.Net.Apply(b.Inputs, b.Num)
+	cost := m.Cost.MetricCost(b.Labels, embeddings, b.Num)
+	total := anydiff.Sum(cost)
+	if m.Average {
+		divisor := 1 / float64(cost.Output().Len())
+		return anydiff.Scale(total, total.Output().Creator().MakeNumeric(divisor))
+	} else {
+		return total
+	}
+}
+
+// Gradient computes the gradient for the batch's cost.
+// It also sets m.LastCost to the numerical value of the
+// total cost.
+//
+// The b argument must be a *MetricBatch.
+func (m *MetricTrainer) Gradient(b anysgd.Batch) anydiff.Grad {
+	grad, lc := anysgd.CosterGrad(m, b, m.Params)
+	m.LastCost = lc
+	return grad
+}
+
+// SetRand sets the source of randomness for m.Net if it
+// is an anynet.RandSetter.
+func (m *MetricTrainer) SetRand(r *rand.Rand) {
+	anynet.SetAllRand(r, m.Net)
+}
@@ -0,0 +1,75 @@
+package anyff
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestMetricTrainer(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	vec := func(x float64) anyvec.Vector {
+		return c.MakeVectorData([]float64{x})
+	}
+	samples := MetricSliceSampleList{
+		{Input: vec(0), Label: 3},
+		{Input: vec(1), Label: 3},
+		{Input: vec(3), Label: 5},
+	}
+	fc := &anynet.FC{
+		InCount:  1,
+		OutCount: 1,
+		Weights:  anydiff.NewVar(vec(1)),
+		Biases:   anydiff.NewVar(vec(0)),
+	}
+	trainer := &MetricTrainer{
+		Net:    fc,
+		Cost:   anynet.Contrastive{Margin: 3},
+		Params: fc.Parameters(),
+	}
+
+	if _, err := trainer.Fetch(MetricSliceSampleList{}); err == nil {
+		t.Error("expected error for empty batch")
+	}
+	batch, err := trainer.Fetch(samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := batch.(*MetricBatch)
+	if b.Num != 3 || !reflect.DeepEqual(b.Labels, []int{3, 3, 5}) {
+		t.Errorf("unexpected batch: %d samples with labels %v", b.Num, b.Labels)
+	}
+	if data := b.Inputs.Output().Data().([]float64); !reflect.DeepEqual(data,
+		[]float64{0, 1, 3}) {
+		t.Errorf("unexpected inputs: %v", data)
+	}
+
+	// With embeddings w*x, the positive pair costs w^2 and
+	// the negative pair at distance 2w costs (3-2w)^2.
+	// Each pair counts for both anchors, and each anchor
+	// averages over the two other embeddings.
+	total := anyvec.Sum(trainer.TotalCost(batch).Output()).(float64)
+	if math.Abs(total-2) > 1e-8 {
+		t.Errorf("expected total cost 2 but got %f", total)
+	}
+
+	trainer.Average = true
+	grad := trainer.Gradient(batch)
+	if actual := trainer.LastCost.(float64); math.Abs(actual-2.0/3) > 1e-8 {
+		t.Errorf("expected cost %f but got %f", 2.0/3, actual)
+	}
+	expected := map[*anydiff.Var]float64{
+		fc.Weights: (2 - 2*(3-2)*2) / 3.0,
+		fc.Biases:  0,
+	}
+	for v, x := range expected {
+		if actual := grad[v].Data().([]float64)[0]; math.Abs(actual-x) > 1e-8 {
+			t.Errorf("expected gradient %f but got %f", x, actual)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package anyff
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anysgd"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+)
+
+// A MultiSample is a training sample for a feed-forward
+// model with several named inputs and outputs.
+//
+// For example, a two-tower model might have inputs named
+// "query" and "document", and a multi-task model might
+// have one output per task.
+type MultiSample struct {
+	Inputs  map[string]anyvec.Vector
+	Outputs map[string]anyvec.Vector
+}
+
+// A MultiSampleList is an anysgd.SampleList that produces
+// multi-input, multi-output samples.
+type MultiSampleList interface {
+	anysgd.SampleList
+
+	GetSample(idx int) (*MultiSample, error)
+}
+
+// A MultiSliceSampleList is a concrete MultiSampleList
+// with predetermined samples.
+type MultiSliceSampleList []*MultiSample
+
+// Len returns the number of samples.
+func (m MultiSliceSampleList) Len() int {
+	return len(m)
+}
+
+// Swap swaps two samples.
+func (m MultiSliceSampleList) Swap(i, j int) {
+	m[i], m[j] = m[j], m[i]
+}
+
+// Slice copies a sub-slice of the list.
+func (m MultiSliceSampleList) Slice(i, j int) anysgd.SampleList {
+	return append(MultiSliceSampleList{}, m[i:j]...)
+}
+
+// Subset copies the samples at the given indices.
+func (m MultiSliceSampleList) Subset(indices []int) anysgd.SampleList {
+	res := make(MultiSliceSampleList, len(indices))
+	for i, idx := range indices {
+		res[i] = m[idx]
+	}
+	return res
+}
+
+// GetSample returns the sample at the index.
+func (m MultiSliceSampleList) GetSample(idx int) (*MultiSample, error) {
+	return m[idx], nil
+}
+
+// A MultiBatch stores named input and output batches in a
+// packed format.
+type MultiBatch struct {
+	Inputs  map[string]*anydiff.Const
+	Outputs map[string]*anydiff.Const
+	Num     int
+}
+
+// A Head specifies how to compute the cost for one of the
+// named outputs of a model.
+type Head struct {
+	Cost anynet.Cost
+
+	// Weight scales the cost of the head.
+	// If it is 0, a weight of 1 is used.
+	Weight float64
+}
+
+// A MultiTrainer can construct batches, compute gradients,
+// and tally up costs for feed-forward models with several
+// named inputs and outputs.
+type MultiTrainer struct {
+	// Func applies the model to a batch of n named inputs,
+	// producing a batch of named outputs.
+	// It must produce an output for every head.
+	Func func(inputs map[string]anydiff.Res, n int) map[string]anydiff.Res
+
+	// Heads maps output names to the corresponding costs.
+	// Outputs without a head do not affect the cost.
+	Heads map[string]*Head
+
+	Params []*anydiff.Var
+
+	// Model, if non-nil, is the model used by Func.
+	// It is seeded by SetRand if it implements
+	// anynet.RandSetter, which makes randomized layers
+	// like dropout reproducible.
+	Model interface{}
+
+	// Average indicates whether or not the cost of each head
+	// should be averaged over the batch before weighting.
+	// This affects gradients, LastCost, LastCosts, and the
+	// output of TotalCost().
+	Average bool
+
+	// After every gradient computation, LastCost is set to
+	// the total cost from the batch.
+	LastCost anyvec.Numeric
+
+	// After every gradient computation, LastCosts is set to
+	// the weighted cost of each head from the batch.
+	// The sum of these costs is LastCost.
+	LastCosts map[string]anyvec.Numeric
+}
+
+// Fetch produces a *MultiBatch for the subset of samples.
+// The s argument must implement MultiSampleList.
+// The batch may not be empty, and every sample must have
+// the same input and output names.
+func (m *MultiTrainer) Fetch(s anysgd.SampleList) (anysgd.Batch, error) {
+	if s.Len() == 0 {
+		return nil, errors.New("fetch batch: empty batch")
+	}
+	l := s.(MultiSampleList)
+	ins := map[string][]anyvec.Vector{}
+	outs := map[string][]anyvec.Vector{}
+	for i := 0; i < l.Len(); i++ {
+		sample, err := l.GetSample(i)
+		if err != nil {
+			return nil, essentials.AddCtx("fetch batch", err)
+		}
+		if err := appendNamed(ins, sample.Inputs, i); err != nil {
+			return nil, essentials.AddCtx("fetch batch: inputs", err)
+		}
+		if err := appendNamed(outs, sample.Outputs, i); err != nil {
+			return nil, essentials.AddCtx("fetch batch: outputs", err)
+		}
+	}
+	return &MultiBatch{
+		Inputs:  joinNamed(ins),
+		Outputs: joinNamed(outs),
+		Num:     l.Len(),
+	}, nil
+}
+
+// TotalCost computes the total cost for the *MultiBatch.
+func (m *MultiTrainer) TotalCost(batch anysgd.Batch) anydiff.Res {
+	_, total := m.headCosts(batch.(*MultiBatch))
+	return total
+}
+
+// Gradient computes the gradient for the batch's cost.
+// It also sets m.LastCost and m.LastCosts.
+//
+// The b argument must be a *MultiBatch.
+func (m *MultiTrainer) Gradient(b anysgd.Batch) anydiff.Grad {
+	var heads map[string]anydiff.Res
+	coster := costerFunc(func(b anysgd.Batch) anydiff.Res {
+		var total anydiff.Res
+		heads, total = m.headCosts(b.(*MultiBatch))
+		return total
+	})
+	grad, lc := anysgd.CosterGrad(coster, b, m.Params)
+	m.LastCost = lc
+	m.LastCosts = map[string]anyvec.Numeric{}
+	for name, cost := range heads {
+		m.LastCosts[name] = anyvec.Sum(cost.Output())
+	}
+	return grad
+}
+
+// SetRand sets the source of randomness for m.Model if
+// it is an anynet.RandSetter.
+// This way, SGD can make dropout reproducible.
+func (m *MultiTrainer) SetRand(r *rand.Rand) {
+	anynet.SetAllRand(r, m.Model)
+}
+
+// headCosts computes the weighted cost for each head, as
+// well as the sum of these costs.
+func (m *MultiTrainer) headCosts(b *MultiBatch) (map[string]anydiff.Res, anydiff.Res) {
+	ins := map[string]anydiff.Res{}
+	for name, in := range b.Inputs {
+		ins[name] = in
+	}
+	outs := m.Func(ins, b.Num)
+
+	var names []string
+	for name := range m.Heads {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	heads := map[string]anydiff.Res{}
+	var total anydiff.Res
+	for _, name := range names {
+		head := m.Heads[name]
+		actual, ok := outs[name]
+		if !ok {
+			panic("missing output for head: " + name)
+		}
+		desired, ok := b.Outputs[name]
+		if !ok {
+			panic("missing desired output for head: " + name)
+		}
+		cost := anydiff.Sum(head.Cost.Cost(desired, actual, b.Num))
+		scale := head.Weight
+		if scale == 0 {
+			scale = 1
+		}
+		if m.Average {
+			scale /= float64(b.Num)
+		}
+		if scale != 1 {
+			cost = anydiff.Scale(cost, cost.Output().Creator().MakeNumeric(scale))
+		}
+		heads[name] = cost
+		if total == nil {
+			total = cost
+		} else {
+			total = anydiff.Add(total, cost)
+		}
+	}
+	if total == nil {
+		panic("no heads")
+	}
+	return heads, total
+}
+
+func appendNamed(dest map[string][]anyvec.Vector, vecs map[string]anyvec.Vector,
+	idx int) error {
+	if idx > 0 && len(vecs) != len(dest) {
+		return errors.New("inconsistent names")
+	}
+	for name, vec := range vecs {
+		if len(dest[name]) != idx {
+			return fmt.Errorf("unexpected name: %s", name)
+		}
+		dest[name] = append(dest[name], vec)
+	}
+	return nil
+}
+
+func joinNamed(vecs map[string][]anyvec.Vector) map[string]*anydiff.Const {
+	res := map[string]*anydiff.Const{}
+	for name, list := range vecs {
+		res[name] = anydiff.NewConst(list[0].Creator().Concat(list...))
+	}
+	return res
+}
+
+// costerFunc is an anysgd.Coster which calls a function.
+type costerFunc func(b anysgd.Batch) anydiff.Res
+
+func (c costerFunc) TotalCost(b anysgd.Batch) anydiff.Res {
+	return c(b)
+}
@@ -0,0 +1,202 @@
+package anyff
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anydifftest"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestMultiTrainerFetch(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	vec := func(x ...float64) anyvec.Vector {
+		return c.MakeVectorData(x)
+	}
+	trainer := &MultiTrainer{}
+
+	samples := MultiSliceSampleList{
+		{
+			Inputs:  map[string]anyvec.Vector{"a": vec(1, 2), "b": vec(3)},
+			Outputs: map[string]anyvec.Vector{"x": vec(0)},
+		},
+		{
+			Inputs:  map[string]anyvec.Vector{"a": vec(4, 5), "b": vec(6)},
+			Outputs: map[string]anyvec.Vector{"x": vec(1)},
+		},
+	}
+	batch, err := trainer.Fetch(samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := batch.(*MultiBatch)
+	if b.Num != 2 {
+		t.Errorf("expected 2 samples but got %d", b.Num)
+	}
+	expected := map[string][]float64{"a": {1, 2, 4, 5}, "b": {3, 6}}
+	for name, data := range expected {
+		actual := b.Inputs[name].Output().Data().([]float64)
+		if len(actual) != len(data) {
+			t.Errorf("input %s: expected %v but got %v", name, data, actual)
+			continue
+		}
+		for i, x := range data {
+			if actual[i] != x {
+				t.Errorf("input %s: expected %v but got %v", name, data, actual)
+				break
+			}
+		}
+	}
+
+	bad := []MultiSliceSampleList{
+		{samples[0], {
+			Inputs:  map[string]anyvec.Vector{"a": vec(4, 5)},
+			Outputs: map[string]anyvec.Vector{"x": vec(1)},
+		}},
+		{samples[0], {
+			Inputs:  map[string]anyvec.Vector{"a": vec(4, 5), "c": vec(6)},
+			Outputs: map[string]anyvec.Vector{"x": vec(1)},
+		}},
+		{samples[0], {
+			Inputs:  samples[1].Inputs,
+			Outputs: map[string]anyvec.Vector{"x": vec(1), "y": vec(2)},
+		}},
+	}
+	for i, list := range bad {
+		if _, err := trainer.Fetch(list); err == nil {
+			t.Errorf("list %d: expected an error", i)
+		}
+	}
+}
+
+func TestMultiTrainerCosts(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	vec := func(x float64) anyvec.Vector {
+		return c.MakeVectorData([]float64{x})
+	}
+	samples := MultiSliceSampleList{
+		{
+			Inputs:  map[string]anyvec.Vector{"a": vec(1), "b": vec(3)},
+			Outputs: map[string]anyvec.Vector{"x": vec(0), "y": vec(1)},
+		},
+		{
+			Inputs:  map[string]anyvec.Vector{"a": vec(2), "b": vec(-1)},
+			Outputs: map[string]anyvec.Vector{"x": vec(0), "y": vec(0)},
+		},
+	}
+	biasX := anydiff.NewVar(vec(0))
+	biasY := anydiff.NewVar(vec(0))
+	trainer := &MultiTrainer{
+		Func: func(ins map[string]anydiff.Res, n int) map[string]anydiff.Res {
+			return map[string]anydiff.Res{
+				"x": anydiff.AddRepeated(ins["a"], biasX),
+				"y": anydiff.AddRepeated(ins["b"], biasY),
+			}
+		},
+		Heads: map[string]*Head{
+			"x": {Cost: anynet.MSE{}, Weight: 2},
+			"y": {Cost: anynet.MSE{}},
+		},
+		Params: []*anydiff.Var{biasX, biasY},
+	}
+	batch, err := trainer.Fetch(samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The squared errors are 1 and 4 for "x", which has a
+	// weight of 2, and 4 and 1 for "y".
+	for _, average := range []bool{false, true} {
+		trainer.Average = average
+		divisor := 1.0
+		if average {
+			divisor = 2
+		}
+		grad := trainer.Gradient(batch)
+		expectedCosts := map[string]float64{"x": 10 / divisor, "y": 5 / divisor}
+		var sum float64
+		for name, expected := range expectedCosts {
+			actual := trainer.LastCosts[name].(float64)
+			if math.Abs(actual-expected) > 1e-8 {
+				t.Errorf("average=%v head %s: expected cost %f but got %f",
+					average, name, expected, actual)
+			}
+			sum += actual
+		}
+		if len(trainer.LastCosts) != len(expectedCosts) {
+			t.Errorf("average=%v: unexpected heads: %v", average, trainer.LastCosts)
+		}
+		if actual := trainer.LastCost.(float64); math.Abs(actual-sum) > 1e-8 {
+			t.Errorf("average=%v: LastCost is %f but LastCosts sum to %f",
+				average, actual, sum)
+		}
+		total := anyvec.Sum(trainer.TotalCost(batch).Output()).(float64)
+		if math.Abs(total-sum) > 1e-8 {
+			t.Errorf("average=%v: expected total cost %f but got %f", average,
+				sum, total)
+		}
+		expectedGrads := map[*anydiff.Var]float64{
+			biasX: 2 * (2*1 + 2*2) / divisor,
+			biasY: (2*2 + 2*-1) / divisor,
+		}
+		for v, expected := range expectedGrads {
+			if actual := grad[v].Data().([]float64)[0]; math.Abs(actual-expected) > 1e-8 {
+				t.Errorf("average=%v: expected gradient %f but got %f", average,
+					expected, actual)
+			}
+		}
+	}
+}
+
+func TestMultiTrainerProp(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	vec := func(x ...float64) anyvec.Vector {
+		return c.MakeVectorData(x)
+	}
+	samples := MultiSliceSampleList{
+		{
+			Inputs:  map[string]anyvec.Vector{"a": vec(1, -0.5)},
+			Outputs: map[string]anyvec.Vector{"x": vec(0.3), "y": vec(-0.2, 0.1)},
+		},
+		{
+			Inputs:  map[string]anyvec.Vector{"a": vec(0.7, 0.2)},
+			Outputs: map[string]anyvec.Vector{"x": vec(-0.4), "y": vec(0.5, 0.9)},
+		},
+	}
+	shared := anynet.NewFC(c, 2, 2)
+	headX := anynet.NewFC(c, 2, 1)
+	headY := anynet.NewFC(c, 2, 2)
+	var params []*anydiff.Var
+	for _, fc := range []*anynet.FC{shared, headX, headY} {
+		params = append(params, fc.Parameters()...)
+	}
+	trainer := &MultiTrainer{
+		Func: func(ins map[string]anydiff.Res, n int) map[string]anydiff.Res {
+			hidden := anydiff.Tanh(shared.Apply(ins["a"], n))
+			return map[string]anydiff.Res{
+				"x": headX.Apply(hidden, n),
+				"y": headY.Apply(hidden, n),
+			}
+		},
+		Heads: map[string]*Head{
+			"x": {Cost: anynet.MSE{}, Weight: 0.5},
+			"y": {Cost: anynet.MSE{}, Weight: 3},
+		},
+		Params:  params,
+		Average: true,
+	}
+	batch, err := trainer.Fetch(samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker := &anydifftest.ResChecker{
+		F: func() anydiff.Res {
+			return trainer.TotalCost(batch)
+		},
+		V: params,
+	}
+	checker.FullCheck(t)
+}
@@ -11,6 +11,10 @@ import (
 type Sample struct {
 	Input  anyvec.Vector
 	Output anyvec.Vector
+
+	// Weight scales the cost of the sample.
+	// If it is 0, a weight of 1 is used.
+	Weight float64
 }
 
 // A SampleList is an anysgd.SampleList that produces
@@ -40,6 +44,17 @@ func (s SliceSampleList) Slice(i, j int) anysgd.SampleList {
 	return append(SliceSampleList{}, s[i:j]...)
 }
 
+// Subset copies the samples at the given indices.
+// This makes it possible to use the list with an
+// anysgd.WeightedSampleList.
+func (s SliceSampleList) Subset(indices []int) anysgd.SampleList {
+	res := make(SliceSampleList, len(indices))
+	for i, idx := range indices {
+		res[i] = s[idx]
+	}
+	return res
+}
+
 // GetSample returns the sample at the index.
 func (s SliceSampleList) GetSample(idx int) (*Sample, error) {
 	return s[idx], nil
@@ -2,12 +2,14 @@ package anyff
 
 import (
 	"errors"
+	"math/rand"
 	"runtime"
 	"sync"
 
 	"github.com/unixpickle/anydiff"
 	"github.com/unixpickle/anynet"
 	"github.com/unixpickle/anynet/anysgd"
+	"github.com/unixpickle/anynet/internal/sampleweight"
 	"github.com/unixpickle/anyvec"
 	"github.com/unixpickle/essentials"
 )
@@ -18,6 +20,14 @@ type Batch struct {
 	Inputs  *anydiff.Const
 	Outputs *anydiff.Const
 	Num     int
+
+	// Weights stores the weight of each sample.
+	// It is nil if every sample has a weight of 1.
+	Weights *anydiff.Const
+
+	// WeightSum is the sum of the components of Weights.
+	// It is only used if Weights is non-nil.
+	WeightSum float64
 }
 
 // A Trainer can construct batches, compute gradients, and
@@ -31,6 +41,12 @@ type Trainer struct {
 	// be averaged before computing gradients.
 	// This affects gradients, LastCost, and the output of
 	// TotalCost().
+	//
+	// If the samples are weighted, the weighted cost is
+	// divided by the sum of the weights, giving a weighted
+	// average.
+	// Otherwise, the cost is divided by the number of cost
+	// values.
 	Average bool
 
 	// After every gradient computation, LastCost is set to
@@ -54,6 +70,7 @@ func (t *Trainer) Fetch(s anysgd.SampleList) (anysgd.Batch, error) {
 	l := s.(SampleList)
 	ins := make([]anyvec.Vector, l.Len())
 	outs := make([]anyvec.Vector, l.Len())
+	weights := make([]float64, l.Len())
 
 	idxChan := make(chan int, l.Len())
 	for i := 0; i < l.Len(); i++ {
@@ -80,6 +97,7 @@ func (t *Trainer) Fetch(s anysgd.SampleList) (anysgd.Batch, error) {
 				}
 				ins[i] = sample.Input
 				outs[i] = sample.Output
+				weights[i] = sample.Weight
 			}
 		}()
 	}
@@ -94,18 +112,31 @@ func (t *Trainer) Fetch(s anysgd.SampleList) (anysgd.Batch, error) {
 	joinedIns := ins[0].Creator().Concat(ins...)
 	joinedOuts := outs[0].Creator().Concat(outs...)
 
-	return &Batch{
+	res := &Batch{
 		Inputs:  anydiff.NewConst(joinedIns),
 		Outputs: anydiff.NewConst(joinedOuts),
 		Num:     l.Len(),
-	}, nil
+	}
+	res.Weights, res.WeightSum = sampleweight.Pack(ins[0].Creator(), weights)
+	return res, nil
 }
 
 // TotalCost computes the total cost for the *Batch.
+//
+// If the batch is weighted, the cost of each sample is
+// multiplied by the sample's weight.
 func (t *Trainer) TotalCost(batch anysgd.Batch) anydiff.Res {
 	b := batch.(*Batch)
 	outRes := t.Net.Apply(b.Inputs, b.Num)
 	cost := t.Cost.Cost(b.Outputs, outRes, b.Num)
+	if b.Weights != nil {
+		total := anydiff.Sum(anydiff.Mul(cost, b.Weights))
+		if t.Average {
+			divisor := 1 / b.WeightSum
+			return anydiff.Scale(total, total.Output().Creator().MakeNumeric(divisor))
+		}
+		return total
+	}
 	total := anydiff.Sum(cost)
 	if t.Average {
 		divisor := 1 / float64(cost.Output().Len())
@@ -125,3 +156,10 @@ func (t *Trainer) Gradient(b anysgd.Batch) anydiff.Grad {
 	t.LastCost = lc
 	return grad
 }
+
+// SetRand sets the source of randomness for t.Net if it
+// is an anynet.RandSetter.
+// This way, SGD can make dropout reproducible.
+func (t *Trainer) SetRand(r *rand.Rand) {
+	anynet.SetAllRand(r, t.Net)
+}
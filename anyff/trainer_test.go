@@ -0,0 +1,55 @@
+package anyff
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestTrainerWeights(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	vec := func(x float64) anyvec.Vector {
+		return c.MakeVectorData([]float64{x})
+	}
+	samples := SliceSampleList{
+		{Input: vec(1), Output: vec(0), Weight: 3},
+		{Input: vec(2), Output: vec(0)},
+	}
+	fc := &anynet.FC{
+		InCount:  1,
+		OutCount: 1,
+		Weights:  anydiff.NewVar(vec(1)),
+		Biases:   anydiff.NewVar(vec(0)),
+	}
+	trainer := &Trainer{
+		Net:     fc,
+		Cost:    anynet.MSE{},
+		Params:  fc.Parameters(),
+		Average: true,
+	}
+	batch, err := trainer.Fetch(samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The weighted squared errors are 3*1 and 1*4, and the
+	// weights sum to 4.
+	grad := trainer.Gradient(batch)
+	if actual := trainer.LastCost.(float64); math.Abs(actual-7.0/4) > 1e-8 {
+		t.Errorf("expected cost %f but got %f", 7.0/4, actual)
+	}
+	expected := (2*3*1 + 2*1*2) / 4.0
+	if actual := grad[fc.Biases].Data().([]float64)[0]; math.Abs(actual-expected) > 1e-8 {
+		t.Errorf("expected gradient %f but got %f", expected, actual)
+	}
+
+	trainer.Average = false
+	total := anyvec.Sum(trainer.TotalCost(batch).Output()).(float64)
+	if math.Abs(total-7) > 1e-8 {
+		t.Errorf("expected total cost 7 but got %f", total)
+	}
+}
@@ -0,0 +1,12 @@
+// Package anyhyper implements hyperparameter search.
+//
+// A search repeatedly calls a TrialFunc, which trains a
+// model (typically with anysgd) using a configuration of
+// hyperparameters and returns a validation score.
+// Random search, grid search, successive halving, and
+// Hyperband are supported.
+// For Hyperband, see https://arxiv.org/abs/1603.06560.
+//
+// Trials run concurrently, and results can be stored in
+// a file so that an interrupted search can be resumed.
+package anyhyper
@@ -0,0 +1,259 @@
+package anyhyper
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"github.com/unixpickle/essentials"
+)
+
+// A TrialFunc trains a model with the given configuration
+// and returns a validation score.
+//
+// The budget indicates how much training to do, for
+// example the number of epochs.
+// Successive halving and Hyperband use small budgets to
+// discard poor configurations early.
+//
+// A TrialFunc may be called concurrently from multiple
+// Goroutines.
+type TrialFunc func(c Config, budget float64) (score float64, err error)
+
+// A Result records the outcome of a single trial.
+type Result struct {
+	Config Config
+	Budget float64
+	Score  float64
+}
+
+// A Search runs hyperparameter searches.
+type Search struct {
+	Func TrialFunc
+
+	// Maximize indicates that higher scores are better.
+	// Otherwise, lower scores (e.g. costs) are better.
+	//
+	// NaN scores are always the worst.
+	Maximize bool
+
+	// Workers is the maximum number of trials to run at
+	// once.
+	// If it is 0, trials are run one at a time.
+	Workers int
+
+	// Store, if non-nil, saves every result and provides
+	// previous results, so that a search can be resumed
+	// without re-running trials.
+	Store *Store
+
+	// Rand, if non-nil, is used to sample configurations.
+	//
+	// For random search and Hyperband, a search can only be
+	// resumed if Rand is seeded the same way every time.
+	Rand *rand.Rand
+}
+
+// Grid runs a trial for every configuration in the grid
+// of the space.
+func (s *Search) Grid(space Space, budget float64) ([]*Result, error) {
+	res, err := s.runTrials(space.Grid(), budget)
+	if err != nil {
+		return nil, essentials.AddCtx("grid search", err)
+	}
+	return res, nil
+}
+
+// Random runs trials for randomly sampled
+// configurations.
+func (s *Search) Random(space Space, numTrials int, budget float64) ([]*Result, error) {
+	res, err := s.runTrials(s.sample(space, numTrials), budget)
+	if err != nil {
+		return nil, essentials.AddCtx("random search", err)
+	}
+	return res, nil
+}
+
+// SuccessiveHalving runs successive halving on randomly
+// sampled configurations.
+//
+// All the configurations are first trained with
+// minBudget.
+// Then the best 1/eta of them are trained with eta times
+// the budget, and so on, until the budget reaches
+// maxBudget.
+// If eta is 0, a default of 3 is used.
+//
+// The results from every round are returned.
+func (s *Search) SuccessiveHalving(space Space, numTrials int, minBudget,
+	maxBudget, eta float64) ([]*Result, error) {
+	res, err := s.halving(s.sample(space, numTrials), minBudget, maxBudget, eta)
+	if err != nil {
+		return nil, essentials.AddCtx("successive halving", err)
+	}
+	return res, nil
+}
+
+// Hyperband runs Hyperband, which runs successive
+// halving several times with different trade-offs
+// between the number of configurations and the initial
+// budget.
+//
+// If eta is 0, a default of 3 is used.
+//
+// The results from every round are returned.
+func (s *Search) Hyperband(space Space, minBudget, maxBudget,
+	eta float64) (results []*Result, err error) {
+	defer essentials.AddCtxTo("hyperband", &err)
+	if eta == 0 {
+		eta = 3
+	}
+	if minBudget <= 0 || maxBudget < minBudget || eta <= 1 {
+		return nil, errors.New("invalid budgets or eta")
+	}
+	sMax := int(math.Floor(math.Log(maxBudget/minBudget)/math.Log(eta) + 1e-8))
+	for bracket := sMax; bracket >= 0; bracket-- {
+		numTrials := int(math.Ceil(float64(sMax+1) / float64(bracket+1) *
+			math.Pow(eta, float64(bracket))))
+		budget := maxBudget * math.Pow(eta, -float64(bracket))
+		res, err := s.halving(s.sample(space, numTrials), budget, maxBudget, eta)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, res...)
+	}
+	return results, nil
+}
+
+// Best returns the result with the best score.
+//
+// If maxBudget is non-zero, only results with that budget
+// are considered.
+func (s *Search) Best(results []*Result, maxBudget float64) *Result {
+	var best *Result
+	for _, r := range results {
+		if maxBudget != 0 && r.Budget != maxBudget {
+			continue
+		}
+		if best == nil || s.better(r.Score, best.Score) {
+			best = r
+		}
+	}
+	return best
+}
+
+func (s *Search) halving(configs []Config, minBudget, maxBudget,
+	eta float64) ([]*Result, error) {
+	if eta == 0 {
+		eta = 3
+	}
+	if minBudget <= 0 || maxBudget < minBudget || eta <= 1 {
+		return nil, errors.New("invalid budgets or eta")
+	}
+	var results []*Result
+	budget := minBudget
+	for {
+		res, err := s.runTrials(configs, budget)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, res...)
+		if budget >= maxBudget || len(configs) <= 1 {
+			return results, nil
+		}
+
+		sort.SliceStable(res, func(i, j int) bool {
+			return s.better(res[i].Score, res[j].Score)
+		})
+		numKeep := int(math.Max(1, math.Floor(float64(len(res))/eta)))
+		configs = nil
+		for _, r := range res[:numKeep] {
+			configs = append(configs, r.Config)
+		}
+		budget = math.Min(maxBudget, budget*eta)
+	}
+}
+
+// runTrials runs a trial for each configuration,
+// returning the results in the same order as the
+// configurations.
+func (s *Search) runTrials(configs []Config, budget float64) ([]*Result, error) {
+	results := make([]*Result, len(configs))
+	var pending []int
+	for i, c := range configs {
+		if r := s.Store.Lookup(c, budget); r != nil {
+			results[i] = r
+		} else {
+			pending = append(pending, i)
+		}
+	}
+
+	numWorkers := s.Workers
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+	idxChan := make(chan int, len(pending))
+	for _, i := range pending {
+		idxChan <- i
+	}
+	close(idxChan)
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var firstErr error
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range idxChan {
+				lock.Lock()
+				failed := firstErr != nil
+				lock.Unlock()
+				if failed {
+					return
+				}
+				score, err := s.Func(configs[idx], budget)
+				if err == nil {
+					results[idx] = &Result{Config: configs[idx], Budget: budget, Score: score}
+					err = s.Store.Add(results[idx])
+				}
+				if err != nil {
+					lock.Lock()
+					if firstErr == nil {
+						firstErr = essentials.AddCtx("trial "+configs[idx].String(), err)
+					}
+					lock.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return results, nil
+}
+
+func (s *Search) sample(space Space, n int) []Config {
+	res := make([]Config, n)
+	for i := range res {
+		res[i] = space.Sample(s.Rand)
+	}
+	return res
+}
+
+// better checks if score1 is strictly better than score2.
+func (s *Search) better(score1, score2 float64) bool {
+	if math.IsNaN(score1) {
+		return false
+	} else if math.IsNaN(score2) {
+		return true
+	}
+	if s.Maximize {
+		return score1 > score2
+	}
+	return score1 < score2
+}
@@ -0,0 +1,202 @@
+package anyhyper
+
+import (
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestSpaceGrid(t *testing.T) {
+	space := Space{
+		"rate":  &LogUniform{Min: 1e-4, Max: 1e-2, GridSize: 3},
+		"batch": Choice{16, 32},
+		"width": &IntRange{Min: 1, Max: 2},
+	}
+	grid := space.Grid()
+	if len(grid) != 12 {
+		t.Fatalf("expected 12 configs but got %d", len(grid))
+	}
+	seen := map[string]bool{}
+	for _, c := range grid {
+		seen[c.String()] = true
+		if len(c) != 3 {
+			t.Errorf("bad config: %v", c)
+		}
+	}
+	if len(seen) != 12 {
+		t.Errorf("expected 12 unique configs but got %d", len(seen))
+	}
+	rates := space["rate"].Grid()
+	for i, expected := range []float64{1e-4, 1e-3, 1e-2} {
+		if math.Abs(rates[i]-expected) > expected*1e-8 {
+			t.Errorf("rate %d: expected %e but got %e", i, expected, rates[i])
+		}
+	}
+}
+
+func TestSearchGrid(t *testing.T) {
+	trials := &testTrials{}
+	s := &Search{Func: trials.Run, Workers: 4}
+	space := Space{
+		"x": &Uniform{Min: -1, Max: 1},
+		"y": Choice{0, 1, 2},
+	}
+	results, err := s.Grid(space, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 15 || trials.Count() != 15 {
+		t.Fatalf("expected 15 trials but got %d (%d calls)", len(results), trials.Count())
+	}
+	best := s.Best(results, 0)
+	if best.Config["x"] != 0 || best.Config["y"] != 1 {
+		t.Errorf("unexpected best config: %v", best.Config)
+	}
+}
+
+func TestSearchRandom(t *testing.T) {
+	space := Space{"x": &Uniform{Min: -1, Max: 1}, "y": &IntRange{Min: 0, Max: 3}}
+	var runs [][]*Result
+	for i := 0; i < 2; i++ {
+		trials := &testTrials{}
+		s := &Search{Func: trials.Run, Workers: 3, Rand: rand.New(rand.NewSource(1337))}
+		results, err := s.Random(space, 20, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		runs = append(runs, results)
+	}
+	for i, r := range runs[0] {
+		if r.Config.String() != runs[1][i].Config.String() || r.Score != runs[1][i].Score {
+			t.Fatalf("seeded searches differ at trial %d", i)
+		}
+		if y := r.Config["y"]; y != math.Floor(y) || y < 0 || y > 3 {
+			t.Errorf("bad integer value: %f", y)
+		}
+	}
+}
+
+func TestSearchHyperband(t *testing.T) {
+	trials := &testTrials{}
+	s := &Search{Func: trials.Run, Workers: 2, Rand: rand.New(rand.NewSource(1337))}
+	space := Space{"x": &Uniform{Min: -1, Max: 1}, "y": Choice{0, 1, 2}}
+	results, err := s.Hyperband(space, 1, 9, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := map[float64]int{}
+	for _, r := range results {
+		counts[r.Budget]++
+	}
+	// Brackets of (9, 1), (5, 3), and (3, 9) trials.
+	expected := map[float64]int{1: 9, 3: 3 + 5, 9: 1 + 1 + 3}
+	for budget, count := range expected {
+		if counts[budget] != count {
+			t.Errorf("budget %f: expected %d trials but got %d", budget, count,
+				counts[budget])
+		}
+	}
+	if best := s.Best(results, 9); best == nil || best.Budget != 9 {
+		t.Errorf("bad best result: %v", best)
+	}
+}
+
+func TestSearchResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "anyhyper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "results.jsonl")
+
+	space := Space{"x": &Uniform{Min: -1, Max: 1}, "y": Choice{0, 1, 2}}
+	var calls []int
+	var scores [][]float64
+	for i := 0; i < 2; i++ {
+		store, err := OpenStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trials := &testTrials{}
+		s := &Search{Func: trials.Run, Store: store, Rand: rand.New(rand.NewSource(1337))}
+		results, err := s.SuccessiveHalving(space, 9, 1, 9, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Close(); err != nil {
+			t.Fatal(err)
+		}
+		calls = append(calls, trials.Count())
+		var runScores []float64
+		for _, r := range results {
+			runScores = append(runScores, r.Score)
+		}
+		scores = append(scores, runScores)
+	}
+	if calls[0] != 9+3+1 || calls[1] != 0 {
+		t.Errorf("unexpected call counts: %v", calls)
+	}
+	for i, score := range scores[0] {
+		if scores[1][i] != score && !(math.IsNaN(score) && math.IsNaN(scores[1][i])) {
+			t.Errorf("result %d: score %f was restored as %f", i, score, scores[1][i])
+		}
+	}
+
+	// Diverged trials may produce infinite scores.
+	store, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonFinite := []float64{math.Inf(1), math.Inf(-1), math.NaN()}
+	for i, score := range nonFinite {
+		r := &Result{Config: Config{"x": 2, "y": float64(i)}, Budget: 9, Score: score}
+		if err := store.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	store, err = OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	for i, score := range nonFinite {
+		r := store.Lookup(Config{"x": 2, "y": float64(i)}, 9)
+		if r == nil {
+			t.Errorf("score %f: result was not restored", score)
+		} else if r.Score != score && !(math.IsNaN(score) && math.IsNaN(r.Score)) {
+			t.Errorf("score %f was restored as %f", score, r.Score)
+		}
+	}
+}
+
+// testTrials scores configurations by their distance
+// from x=0, y=1, with scores improving as the budget
+// grows.
+// Values of x below -0.9 give NaN scores.
+type testTrials struct {
+	lock  sync.Mutex
+	count int
+}
+
+func (t *testTrials) Run(c Config, budget float64) (float64, error) {
+	t.lock.Lock()
+	t.count++
+	t.lock.Unlock()
+	if c["x"] < -0.9 {
+		return math.NaN(), nil
+	}
+	return math.Pow(c["x"], 2) + math.Pow(c["y"]-1, 2) + 1/budget, nil
+}
+
+func (t *testTrials) Count() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.count
+}
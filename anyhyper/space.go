@@ -0,0 +1,192 @@
+package anyhyper
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/unixpickle/anynet/internal/randutil"
+)
+
+// A Config assigns a value to every hyperparameter.
+//
+// Integer hyperparameters, such as batch sizes, are
+// stored as float64 values and can be read with Int.
+type Config map[string]float64
+
+// Int returns a hyperparameter rounded to an integer.
+func (c Config) Int(name string) int {
+	return int(math.Floor(c[name] + 0.5))
+}
+
+// String returns a canonical representation of the
+// configuration, with the names in sorted order.
+func (c Config) String() string {
+	var names []string
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s=%v", name, c[name])
+	}
+	return strings.Join(parts, " ")
+}
+
+// A Param describes the possible values of a single
+// hyperparameter.
+type Param interface {
+	// Sample draws a random value.
+	Sample(r *rand.Rand) float64
+
+	// Grid returns the values to try in a grid search.
+	Grid() []float64
+}
+
+// A Choice is a Param with a fixed set of values.
+type Choice []float64
+
+// Sample chooses a random value.
+func (c Choice) Sample(r *rand.Rand) float64 {
+	return c[randutil.Intn(r, len(c))]
+}
+
+// Grid returns the values.
+func (c Choice) Grid() []float64 {
+	return append([]float64{}, c...)
+}
+
+// Uniform is a Param that is uniformly distributed over
+// a range.
+type Uniform struct {
+	Min float64
+	Max float64
+
+	// GridSize is the number of evenly spaced values to
+	// use in a grid search.
+	// If it is 0, a default of 5 is used.
+	GridSize int
+}
+
+// Sample draws a value from the range.
+func (u *Uniform) Sample(r *rand.Rand) float64 {
+	return u.Min + randutil.Float64(r)*(u.Max-u.Min)
+}
+
+// Grid returns evenly spaced values from Min to Max.
+func (u *Uniform) Grid() []float64 {
+	return linspace(u.Min, u.Max, u.GridSize)
+}
+
+// LogUniform is a Param whose logarithm is uniformly
+// distributed, which is useful for learning rates and
+// other scale parameters.
+//
+// Both Min and Max must be positive.
+type LogUniform struct {
+	Min float64
+	Max float64
+
+	// GridSize is the number of values, evenly spaced on
+	// a log scale, to use in a grid search.
+	// If it is 0, a default of 5 is used.
+	GridSize int
+}
+
+// Sample draws a value from the range.
+func (l *LogUniform) Sample(r *rand.Rand) float64 {
+	logMin, logMax := math.Log(l.Min), math.Log(l.Max)
+	return math.Exp(logMin + randutil.Float64(r)*(logMax-logMin))
+}
+
+// Grid returns values that are evenly spaced on a log
+// scale.
+func (l *LogUniform) Grid() []float64 {
+	res := linspace(math.Log(l.Min), math.Log(l.Max), l.GridSize)
+	for i, x := range res {
+		res[i] = math.Exp(x)
+	}
+	return res
+}
+
+// IntRange is a Param that takes on every integer from
+// Min to Max, inclusive.
+type IntRange struct {
+	Min int
+	Max int
+}
+
+// Sample chooses a random integer.
+func (i *IntRange) Sample(r *rand.Rand) float64 {
+	return float64(i.Min + randutil.Intn(r, i.Max-i.Min+1))
+}
+
+// Grid returns every integer in the range.
+func (i *IntRange) Grid() []float64 {
+	var res []float64
+	for x := i.Min; x <= i.Max; x++ {
+		res = append(res, float64(x))
+	}
+	return res
+}
+
+// A Space maps hyperparameter names to their possible
+// values.
+type Space map[string]Param
+
+// Sample draws a random Config.
+func (s Space) Sample(r *rand.Rand) Config {
+	res := Config{}
+	for _, name := range s.names() {
+		res[name] = s[name].Sample(r)
+	}
+	return res
+}
+
+// Grid returns every combination of the grid values of
+// the parameters.
+func (s Space) Grid() []Config {
+	res := []Config{{}}
+	for _, name := range s.names() {
+		var next []Config
+		for _, c := range res {
+			for _, value := range s[name].Grid() {
+				newConfig := Config{name: value}
+				for k, v := range c {
+					newConfig[k] = v
+				}
+				next = append(next, newConfig)
+			}
+		}
+		res = next
+	}
+	return res
+}
+
+// names returns the parameter names in sorted order, so
+// that sampling is deterministic for a seeded source.
+func (s Space) names() []string {
+	var res []string
+	for name := range s {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func linspace(min, max float64, n int) []float64 {
+	if n == 0 {
+		n = 5
+	}
+	if n == 1 {
+		return []float64{min}
+	}
+	res := make([]float64, n)
+	for i := range res {
+		res[i] = min + (max-min)*float64(i)/float64(n-1)
+	}
+	return res
+}
@@ -0,0 +1,155 @@
+package anyhyper
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/unixpickle/essentials"
+)
+
+// A Store keeps track of trial results and appends them
+// to a JSON-lines file.
+//
+// All methods may be called on a nil *Store, in which
+// case nothing is stored.
+type Store struct {
+	lock    sync.Mutex
+	file    *os.File
+	results map[string]*Result
+}
+
+// OpenStore opens or creates a results file.
+// Results which are already in the file are loaded, so
+// the corresponding trials will not be re-run.
+func OpenStore(path string) (store *Store, err error) {
+	defer essentials.AddCtxTo("open store", &err)
+	store = &Store{results: map[string]*Result{}}
+	if f, err := os.Open(path); err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(nil, 1<<24)
+		for scanner.Scan() {
+			if len(scanner.Bytes()) == 0 {
+				continue
+			}
+			var obj storedResult
+			if err := json.Unmarshal(scanner.Bytes(), &obj); err != nil {
+				return nil, err
+			}
+			r, err := obj.result()
+			if err != nil {
+				return nil, err
+			}
+			store.results[resultKey(r.Config, r.Budget)] = r
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	store.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Results returns all of the stored results, in no
+// particular order.
+func (s *Store) Results() []*Result {
+	if s == nil {
+		return nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var res []*Result
+	for _, r := range s.results {
+		res = append(res, r)
+	}
+	return res
+}
+
+// Lookup finds the result for a trial.
+// It returns nil if the trial has not been run.
+func (s *Store) Lookup(c Config, budget float64) *Result {
+	if s == nil {
+		return nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.results[resultKey(c, budget)]
+}
+
+// Add adds a result and writes it to the file.
+func (s *Store) Add(r *Result) (err error) {
+	if s == nil {
+		return nil
+	}
+	defer essentials.AddCtxTo("store result", &err)
+	data, err := json.Marshal(newStoredResult(r))
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.results[resultKey(r.Config, r.Budget)] = r
+	_, err = s.file.Write(append(data, '\n'))
+	return err
+}
+
+// Close closes the file.
+func (s *Store) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.file.Close()
+}
+
+// storedResult is the JSON representation of a Result.
+//
+// JSON cannot represent non-finite numbers, so NaN
+// scores are stored as null, and infinite scores are
+// stored as the strings "+Inf" and "-Inf".
+type storedResult struct {
+	Config Config      `json:"config"`
+	Budget float64     `json:"budget"`
+	Score  interface{} `json:"score"`
+}
+
+func newStoredResult(r *Result) *storedResult {
+	res := &storedResult{Config: r.Config, Budget: r.Budget}
+	if math.IsInf(r.Score, 0) {
+		res.Score = strconv.FormatFloat(r.Score, 'f', -1, 64)
+	} else if !math.IsNaN(r.Score) {
+		res.Score = r.Score
+	}
+	return res
+}
+
+func (s *storedResult) result() (*Result, error) {
+	res := &Result{Config: s.Config, Budget: s.Budget, Score: math.NaN()}
+	switch score := s.Score.(type) {
+	case nil:
+	case float64:
+		res.Score = score
+	case string:
+		parsed, err := strconv.ParseFloat(score, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid score: %q", score)
+		}
+		res.Score = parsed
+	default:
+		return nil, fmt.Errorf("invalid score: %v", score)
+	}
+	return res, nil
+}
+
+func resultKey(c Config, budget float64) string {
+	return fmt.Sprintf("%s budget=%v", c.String(), budget)
+}
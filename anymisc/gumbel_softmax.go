@@ -1,6 +1,8 @@
 package anymisc
 
 import (
+	"math/rand"
+
 	"github.com/unixpickle/anydiff"
 	"github.com/unixpickle/anyvec"
 	"github.com/unixpickle/essentials"
@@ -20,6 +22,11 @@ func init() {
 // For more, see https://arxiv.org/abs/1611.01144.
 type GumbelSoftmax struct {
 	Temperature float64
+
+	// Rand, if non-nil, is used to generate the Gumbel
+	// noise.
+	// Otherwise, the global source from math/rand is used.
+	Rand *rand.Rand
 }
 
 // DeserializeGumbelSoftmax deserializes a GumbelSoftmax.
@@ -36,7 +43,7 @@ func DeserializeGumbelSoftmax(d []byte) (*GumbelSoftmax, error) {
 func (g *GumbelSoftmax) Apply(in anydiff.Res, n int) anydiff.Res {
 	c := in.Output().Creator()
 	gumbel := c.MakeVector(in.Output().Len())
-	anyvec.Rand(gumbel, anyvec.Uniform, nil)
+	anyvec.Rand(gumbel, anyvec.Uniform, g.Rand)
 	for i := 0; i < 2; i++ {
 		gumbel.AddScalar(c.MakeNumeric(gumbelEpsilon))
 		anyvec.Log(gumbel)
@@ -47,6 +54,11 @@ func (g *GumbelSoftmax) Apply(in anydiff.Res, n int) anydiff.Res {
 	return anydiff.Exp(anydiff.LogSoftmax(smIn, in.Output().Len()/n))
 }
 
+// SetRand sets g.Rand.
+func (g *GumbelSoftmax) SetRand(r *rand.Rand) {
+	g.Rand = r
+}
+
 // SerializerType returns the unique ID used to serialize
 // a GumbelSoftmax with the serializer package.
 func (g *GumbelSoftmax) SerializerType() string {
@@ -1,6 +1,8 @@
 package anymisc
 
 import (
+	"math/rand"
+
 	"github.com/unixpickle/anynet"
 	"github.com/unixpickle/anynet/anyrnn"
 	"github.com/unixpickle/anyvec"
@@ -11,7 +13,15 @@ import (
 //
 // This is based on https://arxiv.org/abs/1504.00941.
 func NewIRNN(c anyvec.Creator, in, out int, scale float64) *anyrnn.Vanilla {
-	res := anyrnn.NewVanilla(c, in, out, anynet.ReLU)
+	return NewIRNNRand(c, in, out, scale, nil)
+}
+
+// NewIRNNRand is like NewIRNN, but it uses the given
+// source of randomness.
+// If r is nil, the global source from math/rand is used.
+func NewIRNNRand(c anyvec.Creator, in, out int, scale float64,
+	r *rand.Rand) *anyrnn.Vanilla {
+	res := anyrnn.NewVanillaRand(c, in, out, anynet.ReLU, r)
 	res.StateWeights.Vector.Set(identityMatrix(c, out, scale).Data)
 	return res
 }
@@ -22,20 +32,27 @@ func NewIRNN(c anyvec.Creator, in, out int, scale float64) *anyrnn.Vanilla {
 //
 // This is based on https://arxiv.org/abs/1511.03771.
 func NewNPRNN(c anyvec.Creator, in, out int) *anyrnn.Vanilla {
-	res := anyrnn.NewVanilla(c, in, out, anynet.ReLU)
+	return NewNPRNNRand(c, in, out, nil)
+}
+
+// NewNPRNNRand is like NewNPRNN, but it uses the given
+// source of randomness.
+// If r is nil, the global source from math/rand is used.
+func NewNPRNNRand(c anyvec.Creator, in, out int, r *rand.Rand) *anyrnn.Vanilla {
+	res := anyrnn.NewVanillaRand(c, in, out, anynet.ReLU, r)
 
 	factor := &anyvec.Matrix{
 		Data: c.MakeVector(out * out),
 		Rows: out,
 		Cols: out,
 	}
-	anyvec.Rand(factor.Data, anyvec.Normal, nil)
+	anyvec.Rand(factor.Data, anyvec.Normal, r)
 	posDef := identityMatrix(c, out, 1)
 	posDef.Product(true, false, c.MakeNumeric(1/float64(out)), factor, factor,
 		c.MakeNumeric(1))
 
 	res.StateWeights.Vector.Set(posDef.Data)
-	res.StateWeights.Vector.Scale(inverseLargestEig(posDef))
+	res.StateWeights.Vector.Scale(inverseLargestEig(posDef, r))
 
 	return res
 }
@@ -54,7 +71,7 @@ func identityMatrix(c anyvec.Creator, size int, scale float64) *anyvec.Matrix {
 	return &anyvec.Matrix{Data: res, Rows: size, Cols: size}
 }
 
-func inverseLargestEig(mat *anyvec.Matrix) anyvec.Numeric {
+func inverseLargestEig(mat *anyvec.Matrix, r *rand.Rand) anyvec.Numeric {
 	const numIters = 100
 
 	c := mat.Data.Creator()
@@ -62,7 +79,7 @@ func inverseLargestEig(mat *anyvec.Matrix) anyvec.Numeric {
 	inVec := c.MakeVector(mat.Cols)
 	outVec := c.MakeVector(mat.Cols)
 
-	anyvec.Rand(inVec, anyvec.Normal, nil)
+	anyvec.Rand(inVec, anyvec.Normal, r)
 
 	// Power iteration method: it's slow, but it works.
 	for i := 0; i < numIters; i++ {
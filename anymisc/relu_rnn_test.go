@@ -1,6 +1,7 @@
 package anymisc
 
 import (
+	"math/rand"
 	"reflect"
 	"testing"
 
@@ -49,3 +50,14 @@ func TestNPRNN(t *testing.T) {
 		}
 	}
 }
+
+func TestNPRNNRand(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	rnn1 := NewNPRNNRand(c, 5, 4, rand.New(rand.NewSource(1337)))
+	rnn2 := NewNPRNNRand(c, 5, 4, rand.New(rand.NewSource(1337)))
+	for i, p := range rnn1.Parameters() {
+		if !reflect.DeepEqual(p.Vector.Data(), rnn2.Parameters()[i].Vector.Data()) {
+			t.Errorf("parameter %d differs between identical seeds", i)
+		}
+	}
+}
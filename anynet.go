@@ -6,6 +6,7 @@ package anynet
 
 import (
 	"fmt"
+	"math/rand"
 
 	"github.com/unixpickle/anydiff"
 	"github.com/unixpickle/essentials"
@@ -40,6 +41,26 @@ func AllParameters(args ...interface{}) []*anydiff.Var {
 	return res
 }
 
+// A RandSetter is anything that uses random numbers and
+// allows its source of randomness to be changed.
+//
+// Passing a nil source to SetRand restores the default
+// behavior of using the global source from math/rand.
+type RandSetter interface {
+	SetRand(r *rand.Rand)
+}
+
+// SetAllRand sets the source of randomness for every
+// argument that implements RandSetter, ignoring the
+// arguments that don't.
+func SetAllRand(r *rand.Rand, args ...interface{}) {
+	for _, x := range args {
+		if s, ok := x.(RandSetter); ok {
+			s.SetRand(r)
+		}
+	}
+}
+
 // A Layer is a composable computation unit for use in a
 // neural network.
 // In a feed-forward network, each layer's output is fed
@@ -95,6 +116,17 @@ func (n Net) Parameters() []*anydiff.Var {
 	return AllParameters(interfaces...)
 }
 
+// SetRand sets the source of randomness for every layer
+// that implements RandSetter.
+//
+// The layers share the source, so the network should not
+// be applied concurrently from multiple Goroutines.
+func (n Net) SetRand(r *rand.Rand) {
+	for _, x := range n {
+		SetAllRand(r, x)
+	}
+}
+
 // SerializerType returns the unique ID used to serialize
 // a Net with the serializer package.
 func (n Net) SerializerType() string {
@@ -0,0 +1,320 @@
+package anynpy
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyconv"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+)
+
+// A FilterLayout specifies how the dimensions of a
+// convolutional filter array are ordered.
+type FilterLayout int
+
+// These are the supported filter layouts.
+const (
+	// OIHW is used by PyTorch:
+	// [out depth, in depth, height, width].
+	OIHW FilterLayout = iota
+
+	// HWIO is used by TensorFlow and Keras:
+	// [height, width, in depth, out depth].
+	HWIO
+
+	// OHWI is used by anyconv.Conv itself:
+	// [out depth, height, width, in depth].
+	OHWI
+)
+
+// AssignFC sets the weights and biases of a FC layer.
+//
+// The weights must have shape [out, in], as used by
+// PyTorch.
+// Weights with shape [in, out], as used by Keras, should
+// first be converted with Transpose.
+// The biases must have shape [out].
+func AssignFC(f *anynet.FC, weights, biases *Array) (err error) {
+	defer essentials.AddCtxTo("assign FC", &err)
+	if err := checkShape("weights", weights, f.OutCount, f.InCount); err != nil {
+		return err
+	}
+	if err := checkShape("biases", biases, f.OutCount); err != nil {
+		return err
+	}
+	assignVar(&f.Weights, weights.Vector)
+	assignVar(&f.Biases, biases.Vector)
+	return nil
+}
+
+// AssignConv sets the filters and biases of a Conv layer.
+//
+// The filters are converted from the given layout to the
+// row-major depth-minor order used by anyconv.
+// The biases must have shape [out depth].
+//
+// If the layer was not yet initialized, its Conver is
+// set as well.
+func AssignConv(c *anyconv.Conv, layout FilterLayout, filters, biases *Array) (err error) {
+	defer essentials.AddCtxTo("assign Conv", &err)
+	out, in, h, w := c.FilterCount, c.InputDepth, c.FilterHeight, c.FilterWidth
+	var shape []int
+	switch layout {
+	case OIHW:
+		shape = []int{out, in, h, w}
+	case HWIO:
+		shape = []int{h, w, in, out}
+	case OHWI:
+		shape = []int{out, h, w, in}
+	default:
+		return fmt.Errorf("unknown filter layout: %d", layout)
+	}
+	if err := checkShape("filters", filters, shape...); err != nil {
+		return err
+	}
+	if err := checkShape("biases", biases, out); err != nil {
+		return err
+	}
+
+	data := arrayData(filters)
+	converted := make([]float64, len(data))
+	var dest int
+	for o := 0; o < out; o++ {
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				for z := 0; z < in; z++ {
+					var src int
+					switch layout {
+					case OIHW:
+						src = ((o*in+z)*h+y)*w + x
+					case HWIO:
+						src = ((y*w+x)*in+z)*out + o
+					case OHWI:
+						src = dest
+					}
+					converted[dest] = data[src]
+					dest++
+				}
+			}
+		}
+	}
+	cr := filters.Vector.Creator()
+	needsConver := c.Filters == nil || c.Conver == nil
+	assignVar(&c.Filters, cr.MakeVectorData(cr.MakeNumericList(converted)))
+	assignVar(&c.Biases, biases.Vector)
+	if needsConver {
+		c.Conver = anyconv.CurrentConverMaker()(*c)
+	}
+	return nil
+}
+
+// AssignLSTMGate sets the weights and biases of an LSTM
+// gate.
+//
+// The input weights must have shape [state, in], and the
+// state weights must have shape [state, state].
+// Each of the biases must have shape [state].
+// If more than one bias array is passed, they are added
+// together, which is useful for frameworks like PyTorch
+// that have separate input and state biases.
+//
+// The peephole weights are left unchanged, since most
+// frameworks do not use peephole connections.
+//
+// Frameworks typically store the weights of all the
+// gates in one array, which can be divided with
+// SplitRows.
+func AssignLSTMGate(g *anyrnn.LSTMGate, inWeights, stateWeights *Array,
+	biases ...*Array) (err error) {
+	defer essentials.AddCtxTo("assign LSTMGate", &err)
+	if inWeights == nil || stateWeights == nil {
+		return errors.New("missing weights")
+	} else if len(inWeights.Shape) != 2 {
+		return fmt.Errorf("input weights: expected 2 dimensions but got shape %v",
+			inWeights.Shape)
+	}
+	stateSize, inSize := inWeights.Shape[0], inWeights.Shape[1]
+	if g.Biases != nil && g.Biases.Vector.Len() != stateSize {
+		return fmt.Errorf("input weights: expected %d rows but got shape %v",
+			g.Biases.Vector.Len(), inWeights.Shape)
+	}
+	if err := checkShape("state weights", stateWeights, stateSize, stateSize); err != nil {
+		return err
+	}
+	if len(biases) == 0 {
+		return errors.New("missing biases")
+	}
+	biasSum := biases[0].Vector.Copy()
+	for i, b := range biases {
+		if err := checkShape("biases", b, stateSize); err != nil {
+			return err
+		}
+		if i > 0 {
+			biasSum.Add(b.Vector)
+		}
+	}
+	if g.InputWeights != nil && g.InputWeights.Vector.Len() != stateSize*inSize {
+		return fmt.Errorf("input weights: expected %d elements but got shape %v",
+			g.InputWeights.Vector.Len(), inWeights.Shape)
+	}
+	assignVar(&g.InputWeights, inWeights.Vector)
+	assignVar(&g.StateWeights, stateWeights.Vector)
+	assignVar(&g.Biases, biasSum)
+	if g.Peephole == nil {
+		g.Peephole = anydiff.NewVar(biasSum.Creator().MakeVector(stateSize))
+	}
+	return nil
+}
+
+// AssignBatchNorm sets the scalers and biases of a
+// BatchNorm layer.
+// Both must have shape [InputCount].
+//
+// In PyTorch and Keras, these are called "weight" (or
+// "gamma") and "bias" (or "beta").
+//
+// The running mean and variance are not imported, since
+// anyconv.BatchNorm always normalizes with the statistics
+// of the current batch.
+// To reproduce the inference outputs of a Python model,
+// replace the layer with the result of FoldBatchNorm.
+func AssignBatchNorm(b *anyconv.BatchNorm, scalers, biases *Array) (err error) {
+	defer essentials.AddCtxTo("assign BatchNorm", &err)
+	if err := checkShape("scalers", scalers, b.InputCount); err != nil {
+		return err
+	}
+	if err := checkShape("biases", biases, b.InputCount); err != nil {
+		return err
+	}
+	assignVar(&b.Scalers, scalers.Vector)
+	assignVar(&b.Biases, biases.Vector)
+	return nil
+}
+
+// FoldBatchNorm creates an anynet.Affine layer which
+// applies a trained batch normalization layer in
+// inference mode, using its running statistics.
+// This is the same kind of layer that is produced by
+// anyconv.PostTrainer.
+//
+// Every array must have shape [n], where n is the number
+// of normalized components.
+// The eps argument is added to the variances, and should
+// match the layer's setting (typically 1e-5 in PyTorch
+// and 1e-3 in Keras).
+func FoldBatchNorm(scalers, biases, mean, variance *Array, eps float64) (a *anynet.Affine,
+	err error) {
+	defer essentials.AddCtxTo("fold BatchNorm", &err)
+	if scalers == nil || len(scalers.Shape) != 1 {
+		return nil, errors.New("scalers: expected 1 dimension")
+	}
+	n := scalers.Shape[0]
+	arrays := []*Array{biases, mean, variance}
+	for i, name := range []string{"biases", "mean", "variance"} {
+		if err := checkShape(name, arrays[i], n); err != nil {
+			return nil, err
+		}
+	}
+	scaleData := arrayData(scalers)
+	biasData := arrayData(biases)
+	meanData := arrayData(mean)
+	varData := arrayData(variance)
+	newScales := make([]float64, n)
+	newBiases := make([]float64, n)
+	for i := range newScales {
+		newScales[i] = scaleData[i] / math.Sqrt(varData[i]+eps)
+		newBiases[i] = biasData[i] - meanData[i]*newScales[i]
+	}
+	c := scalers.Vector.Creator()
+	return &anynet.Affine{
+		Scalers: anydiff.NewVar(c.MakeVectorData(c.MakeNumericList(newScales))),
+		Biases:  anydiff.NewVar(c.MakeVectorData(c.MakeNumericList(newBiases))),
+	}, nil
+}
+
+// Transpose transposes a 2-D array.
+func Transpose(a *Array) (*Array, error) {
+	if len(a.Shape) != 2 {
+		return nil, fmt.Errorf("transpose: expected 2 dimensions but got shape %v", a.Shape)
+	}
+	rows, cols := a.Shape[0], a.Shape[1]
+	data := arrayData(a)
+	res := make([]float64, len(data))
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			res[j*rows+i] = data[i*cols+j]
+		}
+	}
+	c := a.Vector.Creator()
+	return &Array{
+		Shape:  []int{cols, rows},
+		Vector: c.MakeVectorData(c.MakeNumericList(res)),
+	}, nil
+}
+
+// SplitRows splits an array into n equally-sized arrays
+// along its first dimension.
+//
+// For example, this can split PyTorch's LSTM weights,
+// which stack the gates in the order input, forget,
+// cell, and output.
+func SplitRows(a *Array, n int) ([]*Array, error) {
+	if len(a.Shape) == 0 || a.Shape[0]%n != 0 {
+		return nil, fmt.Errorf("split rows: cannot split shape %v into %d parts", a.Shape, n)
+	}
+	shape := append([]int{a.Shape[0] / n}, a.Shape[1:]...)
+	size := a.Vector.Len() / n
+	res := make([]*Array, n)
+	for i := range res {
+		res[i] = &Array{
+			Shape:  shape,
+			Vector: a.Vector.Slice(i*size, (i+1)*size),
+		}
+	}
+	return res, nil
+}
+
+func checkShape(name string, a *Array, shape ...int) error {
+	if a == nil {
+		return fmt.Errorf("%s: missing array", name)
+	}
+	if len(a.Shape) != len(shape) {
+		return fmt.Errorf("%s: expected shape %v but got %v", name, shape, a.Shape)
+	}
+	for i, x := range shape {
+		if a.Shape[i] != x {
+			return fmt.Errorf("%s: expected shape %v but got %v", name, shape, a.Shape)
+		}
+	}
+	return nil
+}
+
+// assignVar copies a vector into a variable, creating the
+// variable if necessary.
+func assignVar(v **anydiff.Var, vec anyvec.Vector) {
+	if *v == nil {
+		*v = anydiff.NewVar(vec.Copy())
+	} else {
+		(*v).Vector.Set(vec)
+	}
+}
+
+func arrayData(a *Array) []float64 {
+	switch data := a.Vector.Data().(type) {
+	case []float32:
+		res := make([]float64, len(data))
+		for i, x := range data {
+			res[i] = float64(x)
+		}
+		return res
+	case []float64:
+		return data
+	default:
+		panic(fmt.Sprintf("unsupported numeric type: %T", data))
+	}
+}
@@ -0,0 +1,225 @@
+package anynpy
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyconv"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestAssignFC(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	fc := anynet.NewFC(c, 3, 2)
+	weights := testArray([]float64{1, 2, 3, 4, 5, 6}, 2, 3)
+	biases := testArray([]float64{-1, 1}, 2)
+	if err := AssignFC(fc, weights, biases); err != nil {
+		t.Fatal(err)
+	}
+	if actual := fc.Weights.Vector.Data().([]float64); !reflect.DeepEqual(actual,
+		[]float64{1, 2, 3, 4, 5, 6}) {
+		t.Errorf("unexpected weights: %v", actual)
+	}
+	if actual := fc.Biases.Vector.Data().([]float64); !reflect.DeepEqual(actual,
+		[]float64{-1, 1}) {
+		t.Errorf("unexpected biases: %v", actual)
+	}
+
+	transposed, err := Transpose(testArray([]float64{1, 4, 2, 5, 3, 6}, 3, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(transposed.Shape, []int{2, 3}) ||
+		!reflect.DeepEqual(transposed.Vector.Data(), weights.Vector.Data()) {
+		t.Errorf("unexpected transpose: %v %v", transposed.Shape, transposed.Vector.Data())
+	}
+
+	if err := AssignFC(fc, testArray(make([]float64, 6), 3, 2), biases); err == nil {
+		t.Error("expected error for transposed weights")
+	}
+	if err := AssignFC(fc, weights, nil); err == nil {
+		t.Error("expected error for missing biases")
+	}
+}
+
+func TestAssignConv(t *testing.T) {
+	const out, in, h, w = 3, 2, 2, 4
+
+	// Create filters in each layout from a function of the
+	// filter coordinates.
+	value := func(o, z, y, x int) float64 {
+		return float64(o*1000 + z*100 + y*10 + x)
+	}
+	var expected, oihw, hwio []float64
+	for o := 0; o < out; o++ {
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				for z := 0; z < in; z++ {
+					expected = append(expected, value(o, z, y, x))
+				}
+			}
+		}
+	}
+	for o := 0; o < out; o++ {
+		for z := 0; z < in; z++ {
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					oihw = append(oihw, value(o, z, y, x))
+				}
+			}
+		}
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			for z := 0; z < in; z++ {
+				for o := 0; o < out; o++ {
+					hwio = append(hwio, value(o, z, y, x))
+				}
+			}
+		}
+	}
+
+	arrays := map[FilterLayout]*Array{
+		OIHW: testArray(oihw, out, in, h, w),
+		HWIO: testArray(hwio, h, w, in, out),
+		OHWI: testArray(expected, out, h, w, in),
+	}
+	for layout, filters := range arrays {
+		conv := &anyconv.Conv{
+			FilterCount:  out,
+			FilterWidth:  w,
+			FilterHeight: h,
+			StrideX:      1,
+			StrideY:      1,
+			InputWidth:   5,
+			InputHeight:  5,
+			InputDepth:   in,
+		}
+		if err := AssignConv(conv, layout, filters, testArray([]float64{1, 2, 3}, 3)); err != nil {
+			t.Errorf("layout %d: %s", layout, err)
+			continue
+		}
+		if actual := conv.Filters.Vector.Data().([]float64); !reflect.DeepEqual(actual,
+			expected) {
+			t.Errorf("layout %d: unexpected filters %v", layout, actual)
+		}
+		if conv.Conver == nil {
+			t.Errorf("layout %d: Conver was not set", layout)
+		}
+	}
+
+	conv := &anyconv.Conv{FilterCount: out, FilterWidth: w, FilterHeight: h,
+		InputDepth: in}
+	err := AssignConv(conv, OIHW, arrays[HWIO], testArray([]float64{1, 2, 3}, 3))
+	if err == nil {
+		t.Error("expected error for wrong layout")
+	}
+}
+
+func TestAssignLSTMGate(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	lstm := anyrnn.NewLSTM(c, 2, 1)
+
+	// PyTorch stacks the gates as input, forget, cell,
+	// and output.
+	inWeights, err := SplitRows(testArray([]float64{1, 2, 3, 4, 5, 6, 7, 8}, 4, 2), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stateWeights, err := SplitRows(testArray([]float64{-1, -2, -3, -4}, 4, 1), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inBiases, err := SplitRows(testArray([]float64{0.5, 1, 1.5, 2}, 4), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stateBiases, err := SplitRows(testArray([]float64{1, 1, 1, 1}, 4), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gates := []*anyrnn.LSTMGate{lstm.In, lstm.Remember, lstm.InValue, lstm.Output}
+	for i, gate := range gates {
+		err := AssignLSTMGate(gate, inWeights[i], stateWeights[i], inBiases[i],
+			stateBiases[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, gate := range gates {
+		expected := []float64{float64(2*i + 1), float64(2*i + 2)}
+		if actual := gate.InputWeights.Vector.Data().([]float64); !reflect.DeepEqual(actual,
+			expected) {
+			t.Errorf("gate %d: expected input weights %v but got %v", i, expected, actual)
+		}
+		expected = []float64{-float64(i + 1)}
+		if actual := gate.StateWeights.Vector.Data().([]float64); !reflect.DeepEqual(actual,
+			expected) {
+			t.Errorf("gate %d: expected state weights %v but got %v", i, expected, actual)
+		}
+		expected = []float64{1.5 + 0.5*float64(i)}
+		if actual := gate.Biases.Vector.Data().([]float64); !reflect.DeepEqual(actual,
+			expected) {
+			t.Errorf("gate %d: expected biases %v but got %v", i, expected, actual)
+		}
+	}
+
+	err = AssignLSTMGate(lstm.In, testArray(make([]float64, 3), 1, 3), stateWeights[0],
+		inBiases[0])
+	if err == nil {
+		t.Error("expected error for wrong input size")
+	}
+}
+
+func TestAssignBatchNorm(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	bn := anyconv.NewBatchNorm(c, 3)
+	scalers := testArray([]float64{1, 2, 3}, 3)
+	biases := testArray([]float64{4, 5, 6}, 3)
+	if err := AssignBatchNorm(bn, scalers, biases); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(bn.Scalers.Vector.Data(), scalers.Vector.Data()) ||
+		!reflect.DeepEqual(bn.Biases.Vector.Data(), biases.Vector.Data()) {
+		t.Error("unexpected parameters")
+	}
+	if err := AssignBatchNorm(bn, testArray([]float64{1, 2}, 2), biases); err == nil {
+		t.Error("expected error for wrong size")
+	}
+}
+
+func TestFoldBatchNorm(t *testing.T) {
+	scalers := testArray([]float64{1, 2}, 2)
+	biases := testArray([]float64{0.5, -1}, 2)
+	mean := testArray([]float64{3, -2}, 2)
+	variance := testArray([]float64{4, 0.25}, 2)
+	affine, err := FoldBatchNorm(scalers, biases, mean, variance, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expScales := []float64{1.0 / 2, 2 / 0.5}
+	expBiases := []float64{0.5 - 3.0/2, -1 + 2*4}
+	actualScales := affine.Scalers.Vector.Data().([]float64)
+	actualBiases := affine.Biases.Vector.Data().([]float64)
+	for i := range expScales {
+		if math.Abs(actualScales[i]-expScales[i]) > 1e-8 ||
+			math.Abs(actualBiases[i]-expBiases[i]) > 1e-8 {
+			t.Fatalf("expected scales %v and biases %v but got %v and %v", expScales,
+				expBiases, actualScales, actualBiases)
+		}
+	}
+
+	if _, err := FoldBatchNorm(scalers, biases, mean, testArray([]float64{1}, 1),
+		1e-5); err == nil {
+		t.Error("expected error for wrong size")
+	}
+}
+
+func testArray(data []float64, shape ...int) *Array {
+	c := anyvec64.CurrentCreator()
+	return &Array{Shape: shape, Vector: c.MakeVectorData(data)}
+}
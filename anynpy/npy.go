@@ -0,0 +1,295 @@
+// Package anynpy loads arrays from NumPy .npy and .npz
+// files and assigns them to the parameters of anynet
+// layers.
+//
+// This makes it possible to prototype a model in Python,
+// save its weights with numpy.save or numpy.savez, and
+// then load the weights into an equivalent anynet model.
+package anynpy
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+)
+
+const npyMagic = "\x93NUMPY"
+
+var (
+	descrExpr   = regexp.MustCompile(`['"]descr['"]\s*:\s*['"]([^'"]*)['"]`)
+	fortranExpr = regexp.MustCompile(`['"]fortran_order['"]\s*:\s*(True|False)`)
+	shapeExpr   = regexp.MustCompile(`['"]shape['"]\s*:\s*\(([^)]*)\)`)
+)
+
+// An Array is a numeric array loaded from a NumPy file.
+//
+// The elements of Vector are stored in row-major (C)
+// order, regardless of the order used in the file.
+type Array struct {
+	Shape  []int
+	Vector anyvec.Vector
+}
+
+// ReadNPY reads an array in the .npy format.
+//
+// Floating-point, integer, and boolean arrays are
+// supported.
+// All of them are converted to vectors from c.
+func ReadNPY(r io.Reader, c anyvec.Creator) (arr *Array, err error) {
+	defer essentials.AddCtxTo("read npy", &err)
+
+	var prefix [8]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, err
+	}
+	if string(prefix[:6]) != npyMagic {
+		return nil, errors.New("bad magic number")
+	}
+	var headerLen int
+	switch prefix[6] {
+	case 1:
+		var size uint16
+		if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+			return nil, err
+		}
+		headerLen = int(size)
+	case 2, 3:
+		var size uint32
+		if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+			return nil, err
+		}
+		headerLen = int(size)
+	default:
+		return nil, fmt.Errorf("unsupported version: %d.%d", prefix[6], prefix[7])
+	}
+	header := make([]byte, headerLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	dtype, fortran, shape, err := parseHeader(string(header))
+	if err != nil {
+		return nil, err
+	}
+	count := 1
+	for _, s := range shape {
+		count *= s
+	}
+	raw := make([]byte, count*dtype.Size)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return nil, err
+	}
+	data := make([]float64, count)
+	for i := range data {
+		data[i] = dtype.Decode(raw[i*dtype.Size:])
+	}
+	if fortran {
+		data = fortranToC(data, shape)
+	}
+	return &Array{
+		Shape:  shape,
+		Vector: c.MakeVectorData(c.MakeNumericList(data)),
+	}, nil
+}
+
+// LoadNPY reads a .npy file.
+func LoadNPY(path string, c anyvec.Creator) (*Array, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadNPY(bufio.NewReader(f), c)
+}
+
+// ReadNPZ reads the arrays from a .npz archive, which is
+// a zip file of .npy files.
+//
+// The resulting map is keyed by array name, which is the
+// file name without the .npy extension.
+// Both compressed and uncompressed archives are
+// supported.
+func ReadNPZ(r io.ReaderAt, size int64, c anyvec.Creator) (arrs map[string]*Array,
+	err error) {
+	defer essentials.AddCtxTo("read npz", &err)
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	arrs = map[string]*Array{}
+	for _, file := range archive.File {
+		if !strings.HasSuffix(file.Name, ".npy") {
+			continue
+		}
+		name := strings.TrimSuffix(file.Name, ".npy")
+		arr, err := readZipArray(file, c)
+		if err != nil {
+			return nil, essentials.AddCtx(name, err)
+		}
+		arrs[name] = arr
+	}
+	return arrs, nil
+}
+
+// LoadNPZ reads a .npz file.
+func LoadNPZ(path string, c anyvec.Creator) (map[string]*Array, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ReadNPZ(bytes.NewReader(data), int64(len(data)), c)
+}
+
+func readZipArray(file *zip.File, c anyvec.Creator) (*Array, error) {
+	r, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ReadNPY(r, c)
+}
+
+// npyType is an element type from a .npy header.
+type npyType struct {
+	Kind  byte
+	Size  int
+	Order binary.ByteOrder
+}
+
+// Decode decodes the element at the start of data.
+func (n *npyType) Decode(data []byte) float64 {
+	var bits uint64
+	switch n.Size {
+	case 1:
+		bits = uint64(data[0])
+	case 2:
+		bits = uint64(n.Order.Uint16(data))
+	case 4:
+		bits = uint64(n.Order.Uint32(data))
+	case 8:
+		bits = n.Order.Uint64(data)
+	}
+	switch n.Kind {
+	case 'f':
+		switch n.Size {
+		case 2:
+			return halfToFloat(uint16(bits))
+		case 4:
+			return float64(math.Float32frombits(uint32(bits)))
+		default:
+			return math.Float64frombits(bits)
+		}
+	case 'i':
+		shift := uint(64 - 8*n.Size)
+		return float64(int64(bits<<shift) >> shift)
+	default:
+		return float64(bits)
+	}
+}
+
+func parseHeader(header string) (dtype *npyType, fortran bool, shape []int, err error) {
+	descr := descrExpr.FindStringSubmatch(header)
+	order := fortranExpr.FindStringSubmatch(header)
+	shapeStr := shapeExpr.FindStringSubmatch(header)
+	if descr == nil || order == nil || shapeStr == nil {
+		return nil, false, nil, fmt.Errorf("invalid header: %s", header)
+	}
+	dtype, err = parseDescr(descr[1])
+	if err != nil {
+		return nil, false, nil, err
+	}
+	for _, dim := range strings.Split(shapeStr[1], ",") {
+		dim = strings.TrimSpace(dim)
+		if dim == "" {
+			continue
+		}
+		size, err := strconv.Atoi(strings.TrimSuffix(dim, "L"))
+		if err != nil || size < 0 {
+			return nil, false, nil, fmt.Errorf("invalid shape: (%s)", shapeStr[1])
+		}
+		shape = append(shape, size)
+	}
+	return dtype, order[1] == "True", shape, nil
+}
+
+func parseDescr(descr string) (*npyType, error) {
+	if len(descr) != 3 {
+		return nil, fmt.Errorf("unsupported dtype: %s", descr)
+	}
+	res := &npyType{Kind: descr[1], Size: int(descr[2] - '0')}
+	switch descr[0] {
+	case '<', '|', '=':
+		res.Order = binary.LittleEndian
+	case '>':
+		res.Order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("unsupported dtype: %s", descr)
+	}
+	switch descr[1:] {
+	case "f2", "f4", "f8", "i1", "i2", "i4", "i8", "u1", "u2", "u4", "u8", "b1":
+		return res, nil
+	default:
+		return nil, fmt.Errorf("unsupported dtype: %s", descr)
+	}
+}
+
+// halfToFloat converts an IEEE 754 half-precision number
+// to a float64.
+func halfToFloat(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1
+	}
+	exp := int(h>>10) & 0x1f
+	frac := float64(h & 0x3ff)
+	switch exp {
+	case 0:
+		return sign * math.Ldexp(frac, -24)
+	case 0x1f:
+		if frac != 0 {
+			return math.NaN()
+		}
+		return math.Inf(int(sign))
+	default:
+		return sign * math.Ldexp(1+frac/1024, exp-15)
+	}
+}
+
+// fortranToC converts column-major data to row-major
+// data.
+func fortranToC(data []float64, shape []int) []float64 {
+	res := make([]float64, len(data))
+	idx := make([]int, len(shape))
+	for i := range res {
+		var offset int
+		stride := 1
+		for j, x := range idx {
+			offset += x * stride
+			stride *= shape[j]
+		}
+		res[i] = data[offset]
+
+		// Increment the row-major index.
+		for j := len(idx) - 1; j >= 0; j-- {
+			idx[j]++
+			if idx[j] < shape[j] {
+				break
+			}
+			idx[j] = 0
+		}
+	}
+	return res
+}
@@ -0,0 +1,130 @@
+package anynpy
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestReadNPY(t *testing.T) {
+	tests := []struct {
+		Descr    string
+		Fortran  bool
+		Shape    string
+		Order    binary.ByteOrder
+		Data     interface{}
+		ExpShape []int
+		Expected []float64
+	}{
+		{"<f4", false, "(2, 3)", binary.LittleEndian, []float32{1, 2, 3, 4, 5, 6.5},
+			[]int{2, 3}, []float64{1, 2, 3, 4, 5, 6.5}},
+		{">f8", true, "(2, 3)", binary.BigEndian, []float64{1, 4, 2, 5, 3, 6},
+			[]int{2, 3}, []float64{1, 2, 3, 4, 5, 6}},
+		{"<i2", false, "(3,)", binary.LittleEndian, []int16{-1, 7, -300},
+			[]int{3}, []float64{-1, 7, -300}},
+		{"|u1", false, "(2, 1, 2)", binary.LittleEndian, []uint8{0, 255, 3, 4},
+			[]int{2, 1, 2}, []float64{0, 255, 3, 4}},
+		{"<f2", false, "(3,)", binary.LittleEndian, []uint16{0x3c00, 0xc000, 0x3800},
+			[]int{3}, []float64{1, -2, 0.5}},
+		{"<f8", false, "()", binary.LittleEndian, []float64{3.5},
+			nil, []float64{3.5}},
+	}
+	for i, test := range tests {
+		var raw bytes.Buffer
+		binary.Write(&raw, test.Order, test.Data)
+		data := encodeNPY(test.Descr, test.Fortran, test.Shape, raw.Bytes())
+		arr, err := ReadNPY(bytes.NewReader(data), anyvec64.CurrentCreator())
+		if err != nil {
+			t.Errorf("test %d: %s", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(arr.Shape, test.ExpShape) {
+			t.Errorf("test %d: expected shape %v but got %v", i, test.ExpShape, arr.Shape)
+		}
+		actual := arr.Vector.Data().([]float64)
+		if !reflect.DeepEqual(actual, test.Expected) {
+			t.Errorf("test %d: expected %v but got %v", i, test.Expected, actual)
+		}
+	}
+}
+
+func TestReadNPYErrors(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	inputs := [][]byte{
+		[]byte("not a numpy file"),
+		encodeNPY("<c8", false, "(1,)", make([]byte, 8)),
+		encodeNPY("<f4", false, "(2,)", make([]byte, 4)),
+	}
+	for i, data := range inputs {
+		if _, err := ReadNPY(bytes.NewReader(data), c); err == nil {
+			t.Errorf("input %d: expected error", i)
+		}
+	}
+}
+
+func TestReadNPZ(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for i, name := range []string{"fc.weight", "fc.bias"} {
+		header := &zip.FileHeader{Name: name + ".npy", Method: zip.Store}
+		if i == 1 {
+			header.Method = zip.Deflate
+		}
+		f, err := w.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var raw bytes.Buffer
+		binary.Write(&raw, binary.LittleEndian, []float64{float64(i), math.Pi})
+		f.Write(encodeNPY("<f8", false, "(2,)", raw.Bytes()))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	arrs, err := ReadNPZ(bytes.NewReader(data), int64(len(data)), anyvec64.CurrentCreator())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(arrs) != 2 {
+		t.Fatalf("expected 2 arrays but got %d", len(arrs))
+	}
+	for i, name := range []string{"fc.weight", "fc.bias"} {
+		arr, ok := arrs[name]
+		if !ok {
+			t.Errorf("missing array: %s", name)
+			continue
+		}
+		expected := []float64{float64(i), math.Pi}
+		if actual := arr.Vector.Data().([]float64); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("array %s: expected %v but got %v", name, expected, actual)
+		}
+	}
+}
+
+// encodeNPY creates a version 1.0 .npy file.
+func encodeNPY(descr string, fortran bool, shape string, raw []byte) []byte {
+	order := "False"
+	if fortran {
+		order = "True"
+	}
+	header := "{'descr': '" + descr + "', 'fortran_order': " + order +
+		", 'shape': " + shape + ", }"
+	padding := 64 - (10+len(header)+1)%64
+	header += strings.Repeat(" ", padding) + "\n"
+
+	var buf bytes.Buffer
+	buf.WriteString(npyMagic)
+	buf.Write([]byte{1, 0})
+	binary.Write(&buf, binary.LittleEndian, uint16(len(header)))
+	buf.WriteString(header)
+	buf.Write(raw)
+	return buf.Bytes()
+}
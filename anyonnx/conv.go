@@ -0,0 +1,199 @@
+package anyonnx
+
+import (
+	"fmt"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyconv"
+	"github.com/unixpickle/essentials"
+)
+
+// bnDefaultStabilizer is the stabilizer which anyconv
+// uses for a BatchNorm whose Stabilizer is 0.
+const bnDefaultStabilizer = 1e-3
+
+// convLayer converts the layers from anyconv.
+func (g *graphBuilder) convLayer(layer anynet.Layer, in *value) (*value, error) {
+	switch layer := layer.(type) {
+	case *anyconv.Conv:
+		return g.conv(layer, in)
+	case *anyconv.MaxPool:
+		return g.pool("MaxPool", layer, in, layer.SpanX, layer.SpanY, layer.StrideX,
+			layer.StrideY, layer.InputWidth, layer.InputHeight, layer.InputDepth)
+	case *anyconv.MeanPool:
+		return g.pool("AveragePool", layer, in, layer.SpanX, layer.SpanY, layer.StrideX,
+			layer.StrideY, layer.InputWidth, layer.InputHeight, layer.InputDepth)
+	case *anyconv.Padding:
+		return g.padding(layer, in)
+	case *anyconv.Resize:
+		return g.resize(layer, in)
+	case *anyconv.BatchNorm:
+		return g.batchNorm(layer, in)
+	case *anyconv.Residual:
+		return g.residual(layer, in)
+	default:
+		return nil, fmt.Errorf("unsupported layer: %T", layer)
+	}
+}
+
+func (g *graphBuilder) conv(c *anyconv.Conv, in *value) (*value, error) {
+	in, err := g.ToImage(in, c.InputWidth, c.InputHeight, c.InputDepth)
+	if err != nil {
+		return nil, essentials.AddCtx(fmt.Sprintf("layer %T", c), err)
+	}
+	filters := g.FloatConst("filters", []int64{
+		int64(c.FilterCount),
+		int64(c.InputDepth),
+		int64(c.FilterHeight),
+		int64(c.FilterWidth),
+	}, convFilters(c))
+	biases := g.FloatConst("biases", []int64{int64(c.FilterCount)},
+		vectorData(c.Biases.Vector))
+	out := g.Node("Conv", []string{in.Name, filters, biases},
+		intsAttr("kernel_shape", int64(c.FilterHeight), int64(c.FilterWidth)),
+		intsAttr("strides", int64(c.StrideY), int64(c.StrideX)))
+	return imageValue(out, c.OutputWidth(), c.OutputHeight(), c.OutputDepth()), nil
+}
+
+func (g *graphBuilder) pool(op string, layer anynet.Layer, in *value, spanX, spanY,
+	strideX, strideY, width, height, depth int) (*value, error) {
+	in, err := g.ToImage(in, width, height, depth)
+	if err != nil {
+		return nil, essentials.AddCtx(fmt.Sprintf("layer %T", layer), err)
+	}
+	out := g.Node(op, []string{in.Name},
+		intsAttr("kernel_shape", int64(spanY), int64(spanX)),
+		intsAttr("strides", int64(strideY), int64(strideX)))
+	outWidth := 1 + (width-spanX)/strideX
+	outHeight := 1 + (height-spanY)/strideY
+	return imageValue(out, outWidth, outHeight, depth), nil
+}
+
+func (g *graphBuilder) padding(p *anyconv.Padding, in *value) (*value, error) {
+	in, err := g.ToImage(in, p.InputWidth, p.InputHeight, p.InputDepth)
+	if err != nil {
+		return nil, essentials.AddCtx(fmt.Sprintf("layer %T", p), err)
+	}
+	pads := g.IntsConst("pads", 0, 0, int64(p.PaddingTop), int64(p.PaddingLeft),
+		0, 0, int64(p.PaddingBottom), int64(p.PaddingRight))
+	out := g.Node("Pad", []string{in.Name, pads}, stringAttr("mode", "constant"))
+	return imageValue(out, p.InputWidth+p.PaddingLeft+p.PaddingRight,
+		p.InputHeight+p.PaddingTop+p.PaddingBottom, p.InputDepth), nil
+}
+
+func (g *graphBuilder) resize(r *anyconv.Resize, in *value) (*value, error) {
+	in, err := g.ToImage(in, r.InputWidth, r.InputHeight, r.Depth)
+	if err != nil {
+		return nil, essentials.AddCtx(fmt.Sprintf("layer %T", r), err)
+	}
+
+	// The output size must include the batch size, which
+	// is only known at runtime.
+	shape := g.Node("Shape", []string{in.Name})
+	batchAndDepth := g.Node("Slice", []string{shape, g.IntsConst("starts", 0),
+		g.IntsConst("ends", 2)})
+	sizes := g.Node("Concat", []string{batchAndDepth,
+		g.IntsConst("sizes", int64(r.OutputHeight), int64(r.OutputWidth))},
+		intAttr("axis", 0))
+
+	out := g.Node("Resize", []string{in.Name, "", "", sizes},
+		stringAttr("mode", "linear"),
+		stringAttr("coordinate_transformation_mode", "align_corners"))
+	return imageValue(out, r.OutputWidth, r.OutputHeight, r.Depth), nil
+}
+
+// batchNorm converts a BatchNorm layer.
+//
+// Like the layer itself, the converted layer normalizes
+// using the statistics of the current batch.
+// For inference on single samples, the layer should be
+// replaced using an anyconv.PostTrainer before export.
+func (g *graphBuilder) batchNorm(b *anyconv.BatchNorm, in *value) (*value, error) {
+	count := b.InputCount
+	if count == 0 || in.Size%count != 0 {
+		return nil, fmt.Errorf("layer %T: input count must divide input size", b)
+	}
+
+	var x string
+	var axes, paramDims []int64
+	isImage := in.Image != nil && in.Image.Depth == count
+	if isImage {
+		x = in.Name
+		axes = []int64{0, 2, 3}
+		paramDims = channelDims(count)
+	} else {
+		in = g.Flatten(in)
+		x = g.Reshape(in.Name, -1, int64(count))
+		axes = []int64{0}
+		paramDims = []int64{int64(count)}
+	}
+
+	stabilizer := b.Stabilizer
+	if stabilizer == 0 {
+		stabilizer = bnDefaultStabilizer
+	}
+	mean := g.Node("ReduceMean", []string{x}, intsAttr("axes", axes...))
+	centered := g.Node("Sub", []string{x, mean})
+	squares := g.Node("Mul", []string{centered, centered})
+	variance := g.Node("ReduceMean", []string{squares}, intsAttr("axes", axes...))
+	stddev := g.Node("Sqrt", []string{
+		g.Node("Add", []string{variance, g.ScalarConst(stabilizer)}),
+	})
+	normalized := g.Node("Div", []string{centered, stddev})
+	scalers := g.FloatConst("scalers", paramDims, vectorData(b.Scalers.Vector))
+	biases := g.FloatConst("biases", paramDims, vectorData(b.Biases.Vector))
+	out := g.Node("Add", []string{g.Node("Mul", []string{normalized, scalers}), biases})
+
+	if isImage {
+		return &value{Name: out, Size: in.Size, Image: in.Image}, nil
+	}
+	return &value{Name: g.Reshape(out, -1, int64(in.Size)), Size: in.Size}, nil
+}
+
+func (g *graphBuilder) residual(r *anyconv.Residual, in *value) (*value, error) {
+	mainOut, err := g.Layer(r.Layer, in)
+	if err != nil {
+		return nil, err
+	}
+	orig := in
+	if r.Projection != nil {
+		orig, err = g.Layer(r.Projection, in)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if mainOut.Size != orig.Size {
+		return nil, fmt.Errorf("layer %T: output size %d does not match input size %d",
+			r, mainOut.Size, orig.Size)
+	}
+	if mainOut.Image == nil || orig.Image == nil || *mainOut.Image != *orig.Image {
+		mainOut = g.Flatten(mainOut)
+		orig = g.Flatten(orig)
+	}
+	return &value{
+		Name:  g.Node("Add", []string{orig.Name, mainOut.Name}),
+		Size:  orig.Size,
+		Image: orig.Image,
+	}, nil
+}
+
+// convFilters converts a Conv's filters from row-major
+// depth-minor order to the [filter, depth, row, column]
+// order used by ONNX.
+func convFilters(c *anyconv.Conv) []float32 {
+	data := vectorData(c.Filters.Vector)
+	res := make([]float32, len(data))
+	var idx int
+	for f := 0; f < c.FilterCount; f++ {
+		for y := 0; y < c.FilterHeight; y++ {
+			for x := 0; x < c.FilterWidth; x++ {
+				for z := 0; z < c.InputDepth; z++ {
+					dest := ((f*c.InputDepth+z)*c.FilterHeight+y)*c.FilterWidth + x
+					res[dest] = data[idx]
+					idx++
+				}
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,480 @@
+package anyonnx
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// evalTensor is a tensor used by the reference evaluator.
+//
+// Integer tensors are stored as float64 as well.
+type evalTensor struct {
+	Shape []int
+	Data  []float64
+}
+
+func newEvalTensor(shape ...int) *evalTensor {
+	return &evalTensor{Shape: shape, Data: make([]float64, shapeSize(shape))}
+}
+
+// evalModel evaluates a model with a naive reference
+// implementation of the ONNX operators.
+func evalModel(m *Model, inputs map[string]*evalTensor) (map[string]*evalTensor, error) {
+	env := map[string]*evalTensor{}
+	for name, t := range inputs {
+		env[name] = t
+	}
+	if err := evalGraph(m.Graph, env); err != nil {
+		return nil, err
+	}
+	res := map[string]*evalTensor{}
+	for _, out := range m.Graph.Outputs {
+		res[out.Name] = env[out.Name]
+	}
+	return res, nil
+}
+
+func evalGraph(g *Graph, env map[string]*evalTensor) error {
+	for _, t := range g.Initializers {
+		et := &evalTensor{}
+		for _, d := range t.Dims {
+			et.Shape = append(et.Shape, int(d))
+		}
+		for _, x := range t.FloatData {
+			et.Data = append(et.Data, float64(x))
+		}
+		for _, x := range t.Int64Data {
+			et.Data = append(et.Data, float64(x))
+		}
+		if len(et.Data) != shapeSize(et.Shape) {
+			return fmt.Errorf("initializer %s: bad size", t.Name)
+		}
+		env[t.Name] = et
+	}
+	for _, node := range g.Nodes {
+		var inputs []*evalTensor
+		for _, name := range node.Inputs {
+			if name == "" {
+				inputs = append(inputs, nil)
+				continue
+			}
+			t, ok := env[name]
+			if !ok {
+				return fmt.Errorf("node %s: missing input %s", node.Name, name)
+			}
+			inputs = append(inputs, t)
+		}
+		outputs, err := evalNode(node, inputs, env)
+		if err != nil {
+			return fmt.Errorf("node %s (%s): %s", node.Name, node.OpType, err)
+		}
+		if len(outputs) != len(node.Outputs) {
+			return fmt.Errorf("node %s: wrong number of outputs", node.Name)
+		}
+		for i, name := range node.Outputs {
+			env[name] = outputs[i]
+		}
+	}
+	return nil
+}
+
+func evalNode(n *Node, in []*evalTensor, env map[string]*evalTensor) ([]*evalTensor,
+	error) {
+	unary := map[string]func(x float64) float64{
+		"Identity": func(x float64) float64 { return x },
+		"Sqrt":     math.Sqrt,
+		"Tanh":     math.Tanh,
+		"Sin":      math.Sin,
+		"Exp":      math.Exp,
+		"Sigmoid":  func(x float64) float64 { return 1 / (1 + math.Exp(-x)) },
+		"Relu":     func(x float64) float64 { return math.Max(0, x) },
+	}
+	binary := map[string]func(x, y float64) float64{
+		"Add": func(x, y float64) float64 { return x + y },
+		"Sub": func(x, y float64) float64 { return x - y },
+		"Mul": func(x, y float64) float64 { return x * y },
+		"Div": func(x, y float64) float64 { return x / y },
+	}
+	if f, ok := unary[n.OpType]; ok {
+		res := &evalTensor{Shape: in[0].Shape, Data: make([]float64, len(in[0].Data))}
+		for i, x := range in[0].Data {
+			res.Data[i] = f(x)
+		}
+		return []*evalTensor{res}, nil
+	} else if f, ok := binary[n.OpType]; ok {
+		return []*evalTensor{evalBroadcast(in[0], in[1], f)}, nil
+	}
+
+	var res *evalTensor
+	var err error
+	switch n.OpType {
+	case "MatMul":
+		res, err = evalMatMul(in[0], in[1])
+	case "LogSoftmax":
+		res = evalLogSoftmax(in[0])
+	case "Reshape":
+		res, err = evalReshape(in[0], in[1])
+	case "Transpose":
+		res = evalTranspose(in[0], intsAttrValue(n, "perm"))
+	case "Conv":
+		res = evalConv(n, in[0], in[1], in[2])
+	case "MaxPool", "AveragePool":
+		res = evalPool(n, in[0])
+	case "Pad":
+		res = evalPad(in[0], in[1])
+	case "Resize":
+		res = evalResize(in[0], in[3])
+	case "ReduceMean":
+		res = evalReduceMean(in[0], intsAttrValue(n, "axes"))
+	case "Shape":
+		res = newEvalTensor(len(in[0].Shape))
+		for i, x := range in[0].Shape {
+			res.Data[i] = float64(x)
+		}
+	case "Gather":
+		res = newEvalTensor(len(in[1].Data))
+		for i, idx := range in[1].Data {
+			res.Data[i] = in[0].Data[int(idx)]
+		}
+	case "Slice":
+		start, end := int(in[1].Data[0]), int(in[2].Data[0])
+		res = &evalTensor{Shape: []int{end - start}, Data: in[0].Data[start:end]}
+	case "Concat":
+		res = &evalTensor{}
+		for _, t := range in {
+			res.Data = append(res.Data, t.Data...)
+		}
+		res.Shape = []int{len(res.Data)}
+	case "Expand":
+		var shape []int
+		for _, x := range in[1].Data {
+			shape = append(shape, int(x))
+		}
+		res = evalBroadcast(in[0], newEvalTensor(shape...), func(x, y float64) float64 {
+			return x
+		})
+	case "Scan":
+		return evalScan(n, in, env)
+	default:
+		err = errors.New("unsupported operator")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []*evalTensor{res}, nil
+}
+
+func evalBroadcast(t1, t2 *evalTensor, f func(x, y float64) float64) *evalTensor {
+	rank := len(t1.Shape)
+	if len(t2.Shape) > rank {
+		rank = len(t2.Shape)
+	}
+	s1 := padShape(t1.Shape, rank)
+	s2 := padShape(t2.Shape, rank)
+	outShape := make([]int, rank)
+	for i := range outShape {
+		outShape[i] = s1[i]
+		if s2[i] != 1 {
+			outShape[i] = s2[i]
+		}
+	}
+	res := newEvalTensor(outShape...)
+	for i := range res.Data {
+		idx := unravelIndex(i, outShape)
+		res.Data[i] = f(t1.Data[broadcastIndex(idx, s1)], t2.Data[broadcastIndex(idx, s2)])
+	}
+	return res
+}
+
+func evalMatMul(t1, t2 *evalTensor) (*evalTensor, error) {
+	if len(t1.Shape) != 2 || len(t2.Shape) != 2 || t1.Shape[1] != t2.Shape[0] {
+		return nil, fmt.Errorf("bad shapes %v and %v", t1.Shape, t2.Shape)
+	}
+	rows, inner, cols := t1.Shape[0], t1.Shape[1], t2.Shape[1]
+	res := newEvalTensor(rows, cols)
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			var sum float64
+			for k := 0; k < inner; k++ {
+				sum += t1.Data[i*inner+k] * t2.Data[k*cols+j]
+			}
+			res.Data[i*cols+j] = sum
+		}
+	}
+	return res, nil
+}
+
+func evalLogSoftmax(t *evalTensor) *evalTensor {
+	cols := t.Shape[len(t.Shape)-1]
+	res := newEvalTensor(t.Shape...)
+	for i := 0; i < len(t.Data); i += cols {
+		row := t.Data[i : i+cols]
+		max := math.Inf(-1)
+		for _, x := range row {
+			max = math.Max(max, x)
+		}
+		var sum float64
+		for _, x := range row {
+			sum += math.Exp(x - max)
+		}
+		for j, x := range row {
+			res.Data[i+j] = x - max - math.Log(sum)
+		}
+	}
+	return res
+}
+
+func evalReshape(t, shapeTensor *evalTensor) (*evalTensor, error) {
+	shape := make([]int, len(shapeTensor.Data))
+	unknown := -1
+	known := 1
+	for i, x := range shapeTensor.Data {
+		shape[i] = int(x)
+		if shape[i] == -1 {
+			unknown = i
+		} else {
+			known *= shape[i]
+		}
+	}
+	if unknown >= 0 {
+		shape[unknown] = len(t.Data) / known
+	}
+	if shapeSize(shape) != len(t.Data) {
+		return nil, fmt.Errorf("cannot reshape %v to %v", t.Shape, shape)
+	}
+	return &evalTensor{Shape: shape, Data: t.Data}, nil
+}
+
+func evalTranspose(t *evalTensor, perm []int) *evalTensor {
+	outShape := make([]int, len(perm))
+	for i, p := range perm {
+		outShape[i] = t.Shape[p]
+	}
+	res := newEvalTensor(outShape...)
+	inIdx := make([]int, len(perm))
+	for i := range res.Data {
+		outIdx := unravelIndex(i, outShape)
+		for j, p := range perm {
+			inIdx[p] = outIdx[j]
+		}
+		res.Data[i] = t.Data[ravelIndex(inIdx, t.Shape)]
+	}
+	return res
+}
+
+func evalConv(n *Node, x, w, b *evalTensor) *evalTensor {
+	strides := intsAttrValue(n, "strides")
+	batch, inDepth, inHeight, inWidth := x.Shape[0], x.Shape[1], x.Shape[2], x.Shape[3]
+	outDepth, kHeight, kWidth := w.Shape[0], w.Shape[2], w.Shape[3]
+	outHeight := 1 + (inHeight-kHeight)/strides[0]
+	outWidth := 1 + (inWidth-kWidth)/strides[1]
+	res := newEvalTensor(batch, outDepth, outHeight, outWidth)
+	for i := range res.Data {
+		idx := unravelIndex(i, res.Shape)
+		sum := b.Data[idx[1]]
+		for z := 0; z < inDepth; z++ {
+			for ky := 0; ky < kHeight; ky++ {
+				for kx := 0; kx < kWidth; kx++ {
+					y := idx[2]*strides[0] + ky
+					x1 := idx[3]*strides[1] + kx
+					sum += x.Data[ravelIndex([]int{idx[0], z, y, x1}, x.Shape)] *
+						w.Data[ravelIndex([]int{idx[1], z, ky, kx}, w.Shape)]
+				}
+			}
+		}
+		res.Data[i] = sum
+	}
+	return res
+}
+
+func evalPool(n *Node, x *evalTensor) *evalTensor {
+	kernel := intsAttrValue(n, "kernel_shape")
+	strides := intsAttrValue(n, "strides")
+	outHeight := 1 + (x.Shape[2]-kernel[0])/strides[0]
+	outWidth := 1 + (x.Shape[3]-kernel[1])/strides[1]
+	res := newEvalTensor(x.Shape[0], x.Shape[1], outHeight, outWidth)
+	for i := range res.Data {
+		idx := unravelIndex(i, res.Shape)
+		max := math.Inf(-1)
+		var sum float64
+		for ky := 0; ky < kernel[0]; ky++ {
+			for kx := 0; kx < kernel[1]; kx++ {
+				val := x.Data[ravelIndex([]int{idx[0], idx[1], idx[2]*strides[0] + ky,
+					idx[3]*strides[1] + kx}, x.Shape)]
+				max = math.Max(max, val)
+				sum += val
+			}
+		}
+		if n.OpType == "MaxPool" {
+			res.Data[i] = max
+		} else {
+			res.Data[i] = sum / float64(kernel[0]*kernel[1])
+		}
+	}
+	return res
+}
+
+func evalPad(x, pads *evalTensor) *evalTensor {
+	rank := len(x.Shape)
+	outShape := make([]int, rank)
+	for i, s := range x.Shape {
+		outShape[i] = s + int(pads.Data[i]) + int(pads.Data[i+rank])
+	}
+	res := newEvalTensor(outShape...)
+	outIdx := make([]int, rank)
+	for i, val := range x.Data {
+		inIdx := unravelIndex(i, x.Shape)
+		for j, k := range inIdx {
+			outIdx[j] = k + int(pads.Data[j])
+		}
+		res.Data[ravelIndex(outIdx, outShape)] = val
+	}
+	return res
+}
+
+// evalResize performs bilinear resizing with the
+// align_corners coordinate transformation.
+func evalResize(x, sizes *evalTensor) *evalTensor {
+	inHeight, inWidth := x.Shape[2], x.Shape[3]
+	outHeight, outWidth := int(sizes.Data[2]), int(sizes.Data[3])
+	res := newEvalTensor(x.Shape[0], x.Shape[1], outHeight, outWidth)
+	source := func(dst, inSize, outSize int) (int, int, float64) {
+		if outSize == 1 {
+			return 0, 0, 0
+		}
+		pos := float64(dst) * float64(inSize-1) / float64(outSize-1)
+		low := int(math.Floor(pos))
+		high := low + 1
+		if high >= inSize {
+			high = inSize - 1
+		}
+		return low, high, pos - float64(low)
+	}
+	for i := range res.Data {
+		idx := unravelIndex(i, res.Shape)
+		y1, y2, fy := source(idx[2], inHeight, outHeight)
+		x1, x2, fx := source(idx[3], inWidth, outWidth)
+		at := func(row, col int) float64 {
+			return x.Data[ravelIndex([]int{idx[0], idx[1], row, col}, x.Shape)]
+		}
+		res.Data[i] = (1-fy)*((1-fx)*at(y1, x1)+fx*at(y1, x2)) +
+			fy*((1-fx)*at(y2, x1)+fx*at(y2, x2))
+	}
+	return res
+}
+
+func evalReduceMean(x *evalTensor, axes []int) *evalTensor {
+	outShape := append([]int{}, x.Shape...)
+	for _, a := range axes {
+		outShape[a] = 1
+	}
+	res := newEvalTensor(outShape...)
+	count := float64(len(x.Data) / len(res.Data))
+	for i, val := range x.Data {
+		idx := unravelIndex(i, x.Shape)
+		for _, a := range axes {
+			idx[a] = 0
+		}
+		res.Data[ravelIndex(idx, outShape)] += val / count
+	}
+	return res
+}
+
+// evalScan evaluates a Scan node with one scan input,
+// scanning over the first axis.
+func evalScan(n *Node, in []*evalTensor, env map[string]*evalTensor) ([]*evalTensor,
+	error) {
+	body := n.Attr("body").G
+	numStates := len(in) - int(n.Attr("num_scan_inputs").I)
+	if numStates != len(in)-1 {
+		return nil, errors.New("only one scan input is supported")
+	}
+	states := in[:numStates]
+	seq := in[numStates]
+	stepShape := seq.Shape[1:]
+	stepSize := shapeSize(stepShape)
+
+	var outputs []*evalTensor
+	for t := 0; t < seq.Shape[0]; t++ {
+		bodyEnv := map[string]*evalTensor{}
+		for name, val := range env {
+			bodyEnv[name] = val
+		}
+		for i, s := range states {
+			bodyEnv[body.Inputs[i].Name] = s
+		}
+		bodyEnv[body.Inputs[numStates].Name] = &evalTensor{
+			Shape: stepShape,
+			Data:  seq.Data[t*stepSize : (t+1)*stepSize],
+		}
+		if err := evalGraph(body, bodyEnv); err != nil {
+			return nil, err
+		}
+		states = nil
+		for _, out := range body.Outputs[:numStates] {
+			states = append(states, bodyEnv[out.Name])
+		}
+		outputs = append(outputs, bodyEnv[body.Outputs[numStates].Name])
+	}
+
+	stacked := &evalTensor{Shape: append([]int{len(outputs)}, outputs[0].Shape...)}
+	for _, out := range outputs {
+		stacked.Data = append(stacked.Data, out.Data...)
+	}
+	return append(states, stacked), nil
+}
+
+func intsAttrValue(n *Node, name string) []int {
+	var res []int
+	for _, x := range n.Attr(name).Ints {
+		res = append(res, int(x))
+	}
+	return res
+}
+
+func shapeSize(shape []int) int {
+	res := 1
+	for _, s := range shape {
+		res *= s
+	}
+	return res
+}
+
+func padShape(shape []int, rank int) []int {
+	res := make([]int, rank-len(shape), rank)
+	for i := range res {
+		res[i] = 1
+	}
+	return append(res, shape...)
+}
+
+func unravelIndex(idx int, shape []int) []int {
+	res := make([]int, len(shape))
+	for i := len(shape) - 1; i >= 0; i-- {
+		res[i] = idx % shape[i]
+		idx /= shape[i]
+	}
+	return res
+}
+
+func ravelIndex(idx, shape []int) int {
+	var res int
+	for i, x := range idx {
+		res = res*shape[i] + x
+	}
+	return res
+}
+
+// broadcastIndex finds the index into a tensor which is
+// broadcast to a larger shape.
+func broadcastIndex(idx, shape []int) int {
+	var res int
+	for i, x := range idx {
+		if shape[i] == 1 {
+			x = 0
+		}
+		res = res*shape[i] + x
+	}
+	return res
+}
@@ -0,0 +1,400 @@
+// Package anyonnx exports anynet models in the ONNX
+// format.
+//
+// Feed-forward networks are exported with ExportNet, and
+// recurrent blocks are exported with ExportBlock.
+// Both produce a Model, which can be saved as a .onnx
+// file.
+//
+// Tensors are laid out exactly as they are in anynet:
+// each row of an input or output tensor stores one
+// sample, and images are flattened in row-major
+// depth-minor order.
+// Internally, the exported graph transposes images to the
+// NCHW layout used by ONNX convolutions.
+package anyonnx
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+)
+
+const (
+	irVersion    = 7
+	opsetVersion = 13
+)
+
+// ExportNet converts a feed-forward network into an ONNX
+// model.
+//
+// The model has one input, named "input", with shape
+// [batch, inSize], and one output, named "output", with
+// shape [batch, outSize].
+//
+// An error is returned if the network contains a layer
+// which cannot be converted.
+func ExportNet(net anynet.Net, inSize int) (m *Model, err error) {
+	defer essentials.AddCtxTo("export ONNX", &err)
+	g := newGraphBuilder()
+	out, err := g.Layer(net, &value{Name: "input", Size: inSize})
+	if err != nil {
+		return nil, err
+	}
+	out = g.Flatten(out)
+	g.AddNode("Identity", []string{out.Name}, []string{"output"})
+	g.Graph.Inputs = []*ValueInfo{floatInfo("input", symbolicDim("batch"), fixedDim(inSize))}
+	g.Graph.Outputs = []*ValueInfo{
+		floatInfo("output", symbolicDim("batch"), fixedDim(out.Size)),
+	}
+	return newModel(g.Graph), nil
+}
+
+// Load reads a model from an ONNX file.
+func Load(path string) (*Model, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Model
+	if err := m.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Save writes the model to an ONNX file.
+func (m *Model) Save(path string) error {
+	data, err := m.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+func newModel(g *Graph) *Model {
+	return &Model{
+		IRVersion:    irVersion,
+		OpsetVersion: opsetVersion,
+		ProducerName: "anynet",
+		Graph:        g,
+	}
+}
+
+// A value is a tensor in the graph being built.
+//
+// If Image is nil, the tensor is a batch of flat vectors.
+// Otherwise, it is a batch of images in NCHW order.
+type value struct {
+	Name  string
+	Size  int
+	Image *imageShape
+}
+
+type imageShape struct {
+	Width  int
+	Height int
+	Depth  int
+}
+
+func imageValue(name string, width, height, depth int) *value {
+	return &value{
+		Name:  name,
+		Size:  width * height * depth,
+		Image: &imageShape{Width: width, Height: height, Depth: depth},
+	}
+}
+
+// graphBuilder adds nodes to a graph.
+//
+// Constants are always stored as initializers in the
+// root graph, even when nodes are added to a subgraph.
+type graphBuilder struct {
+	Graph *Graph
+	Root  *Graph
+
+	counter *int
+}
+
+func newGraphBuilder() *graphBuilder {
+	g := &Graph{Name: "anynet"}
+	return &graphBuilder{Graph: g, Root: g, counter: new(int)}
+}
+
+// Subgraph creates a builder for a new subgraph which
+// shares the root graph and the namespace of g.
+func (g *graphBuilder) Subgraph(name string) *graphBuilder {
+	return &graphBuilder{
+		Graph:   &Graph{Name: g.Name(name)},
+		Root:    g.Root,
+		counter: g.counter,
+	}
+}
+
+// Name generates a unique name.
+func (g *graphBuilder) Name(prefix string) string {
+	*g.counter++
+	return fmt.Sprintf("%s_%d", prefix, *g.counter)
+}
+
+// AddNode adds a node with the given outputs.
+func (g *graphBuilder) AddNode(op string, inputs, outputs []string, attrs ...*Attribute) {
+	g.Graph.Nodes = append(g.Graph.Nodes, &Node{
+		Name:       g.Name("node"),
+		OpType:     op,
+		Inputs:     inputs,
+		Outputs:    outputs,
+		Attributes: attrs,
+	})
+}
+
+// Node adds a node with a single output and returns the
+// name of the output.
+func (g *graphBuilder) Node(op string, inputs []string, attrs ...*Attribute) string {
+	out := g.Name(strings.ToLower(op))
+	g.AddNode(op, inputs, []string{out}, attrs...)
+	return out
+}
+
+// FloatConst adds a float initializer.
+func (g *graphBuilder) FloatConst(prefix string, dims []int64, data []float32) string {
+	name := g.Name(prefix)
+	g.Root.Initializers = append(g.Root.Initializers, &Tensor{
+		Name:      name,
+		Dims:      dims,
+		DataType:  Float,
+		FloatData: data,
+	})
+	return name
+}
+
+// ScalarConst adds a scalar float initializer.
+func (g *graphBuilder) ScalarConst(x float64) string {
+	return g.FloatConst("scalar", nil, []float32{float32(x)})
+}
+
+// IntsConst adds a 1-D int64 initializer.
+func (g *graphBuilder) IntsConst(prefix string, data ...int64) string {
+	name := g.Name(prefix)
+	g.Root.Initializers = append(g.Root.Initializers, &Tensor{
+		Name:      name,
+		Dims:      []int64{int64(len(data))},
+		DataType:  Int64,
+		Int64Data: data,
+	})
+	return name
+}
+
+// Reshape reshapes a tensor.
+func (g *graphBuilder) Reshape(name string, shape ...int64) string {
+	return g.Node("Reshape", []string{name, g.IntsConst("shape", shape...)})
+}
+
+// Scale multiplies a tensor by a scalar.
+func (g *graphBuilder) Scale(name string, scaler float64) string {
+	return g.Node("Mul", []string{name, g.ScalarConst(scaler)})
+}
+
+// MatMul multiplies a batch of row vectors by the
+// transpose of a row-major out x in weight matrix, as
+// anynet does for fully-connected layers.
+func (g *graphBuilder) MatMul(name string, weights anyvec.Vector, in, out int) string {
+	data := transposeMatrix(vectorData(weights), out, in)
+	w := g.FloatConst("weights", []int64{int64(in), int64(out)}, data)
+	return g.Node("MatMul", []string{name, w})
+}
+
+// Flatten converts a value to a batch of flat vectors.
+func (g *graphBuilder) Flatten(v *value) *value {
+	if v.Image == nil {
+		return v
+	}
+	transposed := g.Node("Transpose", []string{v.Name}, intsAttr("perm", 0, 2, 3, 1))
+	return &value{Name: g.Reshape(transposed, -1, int64(v.Size)), Size: v.Size}
+}
+
+// ToImage converts a value to a batch of NCHW images.
+func (g *graphBuilder) ToImage(v *value, width, height, depth int) (*value, error) {
+	res := imageValue("", width, height, depth)
+	if v.Size != res.Size {
+		return nil, fmt.Errorf("input size %d does not match %dx%dx%d image", v.Size,
+			width, height, depth)
+	}
+	if v.Image != nil && *v.Image == *res.Image {
+		return v, nil
+	}
+	v = g.Flatten(v)
+	reshaped := g.Reshape(v.Name, -1, int64(height), int64(width), int64(depth))
+	res.Name = g.Node("Transpose", []string{reshaped}, intsAttr("perm", 0, 3, 1, 2))
+	return res, nil
+}
+
+// Layer converts a layer and applies it to a value.
+func (g *graphBuilder) Layer(layer anynet.Layer, in *value) (*value, error) {
+	switch layer := layer.(type) {
+	case anynet.Net:
+		for _, l := range layer {
+			var err error
+			in, err = g.Layer(l, in)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return in, nil
+	case *anynet.FC:
+		return g.fc(layer, in)
+	case *anynet.Affine:
+		return g.affine(layer, in)
+	case anynet.Activation:
+		return g.activation(layer, in)
+	case *anynet.Dropout:
+		if layer.Enabled {
+			return nil, fmt.Errorf("layer %T must be disabled", layer)
+		}
+		return &value{Name: g.Scale(in.Name, layer.KeepProb), Size: in.Size,
+			Image: in.Image}, nil
+	default:
+		return g.convLayer(layer, in)
+	}
+}
+
+func (g *graphBuilder) fc(f *anynet.FC, in *value) (*value, error) {
+	if in.Size != f.InCount {
+		return nil, sizeError(f, f.InCount, in.Size)
+	}
+	in = g.Flatten(in)
+	product := g.MatMul(in.Name, f.Weights.Vector, f.InCount, f.OutCount)
+	biases := g.FloatConst("biases", []int64{int64(f.OutCount)}, vectorData(f.Biases.Vector))
+	return &value{Name: g.Node("Add", []string{product, biases}), Size: f.OutCount}, nil
+}
+
+func (g *graphBuilder) affine(a *anynet.Affine, in *value) (*value, error) {
+	scalers := vectorData(a.Scalers.Vector)
+	biases := vectorData(a.Biases.Vector)
+	if len(scalers) == 0 || len(biases) == 0 || in.Size%len(scalers) != 0 ||
+		in.Size%len(biases) != 0 {
+		return nil, fmt.Errorf("layer %T: scaler and bias counts must divide input size",
+			a)
+	}
+	var scalerName, biasName string
+	if in.Image != nil && isChannelwise(len(scalers), in.Image) &&
+		isChannelwise(len(biases), in.Image) {
+		scalerName = g.FloatConst("scalers", channelDims(len(scalers)), scalers)
+		biasName = g.FloatConst("biases", channelDims(len(biases)), biases)
+	} else {
+		in = g.Flatten(in)
+		size := []int64{int64(in.Size)}
+		scalerName = g.FloatConst("scalers", size, tileData(scalers, in.Size))
+		biasName = g.FloatConst("biases", size, tileData(biases, in.Size))
+	}
+	scaled := g.Node("Mul", []string{in.Name, scalerName})
+	return &value{Name: g.Node("Add", []string{scaled, biasName}), Size: in.Size,
+		Image: in.Image}, nil
+}
+
+func (g *graphBuilder) activation(a anynet.Activation, in *value) (*value, error) {
+	var op string
+	switch a {
+	case anynet.Tanh:
+		op = "Tanh"
+	case anynet.Sigmoid:
+		op = "Sigmoid"
+	case anynet.ReLU:
+		op = "Relu"
+	case anynet.Sin:
+		op = "Sin"
+	case anynet.Exp:
+		op = "Exp"
+	case anynet.LogSoftmax:
+		in = g.Flatten(in)
+		out := g.Node("LogSoftmax", []string{in.Name}, intAttr("axis", -1))
+		return &value{Name: out, Size: in.Size}, nil
+	default:
+		return nil, fmt.Errorf("unsupported activation: %d", a)
+	}
+	return &value{Name: g.Node(op, []string{in.Name}), Size: in.Size, Image: in.Image}, nil
+}
+
+func sizeError(layer interface{}, expected, actual int) error {
+	return fmt.Errorf("layer %T: expected input size %d but got %d", layer, expected, actual)
+}
+
+func floatInfo(name string, shape ...Dimension) *ValueInfo {
+	return &ValueInfo{Name: name, ElemType: Float, Shape: shape}
+}
+
+func fixedDim(size int) Dimension {
+	return Dimension{Value: int64(size)}
+}
+
+func symbolicDim(name string) Dimension {
+	return Dimension{Param: name}
+}
+
+func intAttr(name string, x int64) *Attribute {
+	return &Attribute{Name: name, Type: AttrInt, I: x}
+}
+
+func intsAttr(name string, x ...int64) *Attribute {
+	return &Attribute{Name: name, Type: AttrInts, Ints: x}
+}
+
+func stringAttr(name, s string) *Attribute {
+	return &Attribute{Name: name, Type: AttrString, S: s}
+}
+
+func graphAttr(name string, g *Graph) *Attribute {
+	return &Attribute{Name: name, Type: AttrGraph, G: g}
+}
+
+// isChannelwise checks if a repeated parameter with the
+// given length applies uniformly to each channel.
+func isChannelwise(count int, shape *imageShape) bool {
+	return count == 1 || count == shape.Depth
+}
+
+// channelDims gets the dimensions of a channelwise
+// parameter that broadcasts against NCHW images.
+func channelDims(count int) []int64 {
+	if count == 1 {
+		return []int64{1}
+	}
+	return []int64{int64(count), 1, 1}
+}
+
+func tileData(data []float32, size int) []float32 {
+	res := make([]float32, size)
+	for i := range res {
+		res[i] = data[i%len(data)]
+	}
+	return res
+}
+
+func transposeMatrix(data []float32, rows, cols int) []float32 {
+	res := make([]float32, len(data))
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			res[j*rows+i] = data[i*cols+j]
+		}
+	}
+	return res
+}
+
+func vectorData(v anyvec.Vector) []float32 {
+	switch data := v.Data().(type) {
+	case []float32:
+		return data
+	case []float64:
+		res := make([]float32, len(data))
+		for i, x := range data {
+			res[i] = float32(x)
+		}
+		return res
+	default:
+		panic(fmt.Sprintf("unsupported numeric type: %T", data))
+	}
+}
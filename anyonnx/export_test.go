@@ -0,0 +1,264 @@
+package anyonnx
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyconv"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestExportNetDense(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	net := anynet.Net{
+		anynet.NewFC(c, 3, 4),
+		anynet.Tanh,
+		&anynet.Affine{
+			Scalers: anydiff.NewVar(c.MakeVectorData([]float64{2, -1})),
+			Biases:  anydiff.NewVar(c.MakeVectorData([]float64{0.5, 1, -1, 0.25})),
+		},
+		anynet.Sigmoid,
+		&anynet.Dropout{KeepProb: 0.5},
+		anynet.NewFC(c, 4, 5),
+		anynet.ReLU,
+		anynet.NewFC(c, 5, 3),
+		anynet.LogSoftmax,
+	}
+	testExportNet(t, net, 3, 2)
+}
+
+func TestExportNetConv(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	conv := &anyconv.Conv{
+		FilterCount:  3,
+		FilterWidth:  3,
+		FilterHeight: 3,
+		StrideX:      2,
+		StrideY:      1,
+		InputWidth:   7,
+		InputHeight:  6,
+		InputDepth:   2,
+	}
+	conv.InitRand(c)
+	resConv := &anyconv.Conv{
+		FilterCount:  3,
+		FilterWidth:  3,
+		FilterHeight: 3,
+		StrideX:      1,
+		StrideY:      1,
+		InputWidth:   6,
+		InputHeight:  5,
+		InputDepth:   3,
+	}
+	resConv.InitRand(c)
+	net := anynet.Net{
+		&anyconv.Padding{
+			InputWidth:    5,
+			InputHeight:   4,
+			InputDepth:    2,
+			PaddingTop:    1,
+			PaddingRight:  1,
+			PaddingBottom: 1,
+			PaddingLeft:   1,
+		},
+		conv,
+		anynet.ReLU,
+		anyconv.NewBatchNorm(c, 3),
+		&anyconv.MaxPool{
+			SpanX:       2,
+			SpanY:       2,
+			StrideX:     1,
+			StrideY:     2,
+			InputWidth:  3,
+			InputHeight: 4,
+			InputDepth:  3,
+		},
+		&anyconv.Resize{
+			Depth:        3,
+			InputWidth:   2,
+			InputHeight:  2,
+			OutputWidth:  4,
+			OutputHeight: 3,
+		},
+		&anyconv.Residual{
+			Layer: anynet.Net{
+				&anyconv.Padding{
+					InputWidth:    4,
+					InputHeight:   3,
+					InputDepth:    3,
+					PaddingTop:    1,
+					PaddingRight:  1,
+					PaddingBottom: 1,
+					PaddingLeft:   1,
+				},
+				resConv,
+			},
+		},
+		&anyconv.MeanPool{
+			SpanX:       2,
+			SpanY:       1,
+			StrideX:     2,
+			StrideY:     1,
+			InputWidth:  4,
+			InputHeight: 3,
+			InputDepth:  3,
+		},
+		&anynet.Affine{
+			Scalers: anydiff.NewVar(c.MakeVectorData([]float64{1, -2, 0.5})),
+			Biases:  anydiff.NewVar(c.MakeVectorData([]float64{0.1})),
+		},
+		&anynet.Affine{
+			Scalers: anydiff.NewVar(c.MakeVectorData([]float64{1.5, -0.5})),
+			Biases:  anydiff.NewVar(c.MakeVectorData([]float64{0.1, 0.2, 0.3})),
+		},
+		anyconv.NewBatchNorm(c, 6),
+		anynet.NewFC(c, 18, 4),
+	}
+	for _, p := range anynet.AllParameters(net) {
+		anyvec.Rand(p.Vector, anyvec.Normal, nil)
+	}
+	testExportNet(t, net, 40, 3)
+}
+
+func TestExportBlock(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	lstm := anyrnn.NewLSTM(c, 3, 4)
+	lstm.Dropout = anyrnn.RecurrentDropout{InKeepProb: 0.8, StateKeepProb: 0.5}
+	lstm.Zoneout = anyrnn.Zoneout{CellProb: 0.2, HiddenProb: 0.1}
+	block := anyrnn.Stack{
+		lstm,
+		&anyrnn.LayerBlock{Layer: anynet.Net{anynet.NewFC(c, 4, 4), anynet.Sin}},
+		anyrnn.NewVanilla(c, 4, 2, anynet.Tanh),
+	}
+	for _, p := range block.Parameters() {
+		anyvec.Rand(p.Vector, anyvec.Normal, nil)
+	}
+
+	const inSize, numSteps, batch = 3, 5, 2
+	var seqs [][]anyvec.Vector
+	for i := 0; i < batch; i++ {
+		var seq []anyvec.Vector
+		for j := 0; j < numSteps; j++ {
+			vec := c.MakeVector(inSize)
+			anyvec.Rand(vec, anyvec.Normal, nil)
+			seq = append(seq, vec)
+		}
+		seqs = append(seqs, seq)
+	}
+
+	model, err := ExportBlock(block, inSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	model = roundTripModel(t, model)
+
+	input := newEvalTensor(numSteps, batch, inSize)
+	for i, seq := range seqs {
+		for j, vec := range seq {
+			copy(input.Data[(j*batch+i)*inSize:], vec.Data().([]float64))
+		}
+	}
+	outputs, err := evalModel(model, map[string]*evalTensor{"input": input})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := outputs["output"]
+
+	var expected []float64
+	for _, b := range anyrnn.Map(anyseq.ConstSeqList(c, seqs), block).Output() {
+		expected = append(expected, b.Packed.Data().([]float64)...)
+	}
+	checkOutput(t, actual, []int{numSteps, batch, 2}, expected)
+}
+
+func TestExportUnsupported(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	_, err := ExportNet(anynet.Net{anynet.NewFC(c, 3, 3), unsupportedLayer{}}, 3)
+	if err == nil || !strings.Contains(err.Error(), "unsupported layer") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	_, err = ExportNet(anynet.Net{&anynet.Dropout{Enabled: true, KeepProb: 0.5}}, 3)
+	if err == nil {
+		t.Error("expected error for enabled dropout")
+	}
+	_, err = ExportNet(anynet.Net{anynet.NewFC(c, 3, 3)}, 4)
+	if err == nil {
+		t.Error("expected error for size mismatch")
+	}
+	block := anyrnn.Stack{anyrnn.NewLSTM(c, 3, 3), unsupportedBlock{}}
+	_, err = ExportBlock(block, 3)
+	if err == nil || !strings.Contains(err.Error(), "unsupported block") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func testExportNet(t *testing.T, net anynet.Net, inSize, batch int) {
+	c := anyvec64.CurrentCreator()
+	model, err := ExportNet(net, inSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	model = roundTripModel(t, model)
+
+	inVec := c.MakeVector(inSize * batch)
+	anyvec.Rand(inVec, anyvec.Normal, nil)
+	input := &evalTensor{Shape: []int{batch, inSize}, Data: inVec.Data().([]float64)}
+	outputs, err := evalModel(model, map[string]*evalTensor{"input": input})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := outputs["output"]
+
+	expected := net.Apply(anydiff.NewConst(inVec), batch).Output().Data().([]float64)
+	checkOutput(t, actual, []int{batch, len(expected) / batch}, expected)
+}
+
+func roundTripModel(t *testing.T, m *Model) *Model {
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res Model
+	if err := res.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if res.IRVersion != irVersion || res.OpsetVersion != opsetVersion {
+		t.Errorf("unexpected versions: IR %d, opset %d", res.IRVersion, res.OpsetVersion)
+	}
+	return &res
+}
+
+func checkOutput(t *testing.T, actual *evalTensor, shape []int, expected []float64) {
+	if actual == nil {
+		t.Fatal("missing output")
+	}
+	if len(actual.Shape) != len(shape) {
+		t.Fatalf("expected shape %v but got %v", shape, actual.Shape)
+	}
+	for i, x := range shape {
+		if actual.Shape[i] != x {
+			t.Fatalf("expected shape %v but got %v", shape, actual.Shape)
+		}
+	}
+	for i, x := range expected {
+		if math.IsNaN(actual.Data[i]) || math.Abs(actual.Data[i]-x) > 1e-4 {
+			t.Fatalf("output %d: expected %f but got %f", i, x, actual.Data[i])
+		}
+	}
+}
+
+type unsupportedLayer struct{}
+
+func (u unsupportedLayer) Apply(in anydiff.Res, n int) anydiff.Res {
+	return in
+}
+
+type unsupportedBlock struct {
+	anyrnn.Block
+}
@@ -0,0 +1,599 @@
+package anyonnx
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// These are the ONNX tensor element types used by this
+// package.
+const (
+	Float = 1
+	Int64 = 7
+)
+
+// These are the ONNX attribute types used by this package.
+const (
+	AttrFloat  = 1
+	AttrInt    = 2
+	AttrString = 3
+	AttrTensor = 4
+	AttrGraph  = 5
+	AttrFloats = 6
+	AttrInts   = 7
+)
+
+// A Model is an ONNX ModelProto.
+//
+// Only the fields needed to represent anynet models are
+// supported.
+// Unknown fields are skipped when decoding.
+type Model struct {
+	IRVersion    int64
+	OpsetVersion int64
+	ProducerName string
+	Graph        *Graph
+}
+
+// A Graph is an ONNX GraphProto.
+type Graph struct {
+	Name         string
+	Nodes        []*Node
+	Initializers []*Tensor
+	Inputs       []*ValueInfo
+	Outputs      []*ValueInfo
+}
+
+// A Node is an ONNX NodeProto.
+//
+// An empty input name indicates a missing optional input.
+type Node struct {
+	Name       string
+	OpType     string
+	Inputs     []string
+	Outputs    []string
+	Attributes []*Attribute
+}
+
+// Attr finds the attribute with the given name.
+// It returns nil if no such attribute exists.
+func (n *Node) Attr(name string) *Attribute {
+	for _, a := range n.Attributes {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// An Attribute is an ONNX AttributeProto.
+//
+// The Type field determines which value field is used.
+type Attribute struct {
+	Name string
+	Type int
+
+	F      float32
+	I      int64
+	S      string
+	T      *Tensor
+	G      *Graph
+	Floats []float32
+	Ints   []int64
+}
+
+// A Tensor is an ONNX TensorProto.
+//
+// The DataType field determines which data field is used.
+type Tensor struct {
+	Name      string
+	Dims      []int64
+	DataType  int
+	FloatData []float32
+	Int64Data []int64
+}
+
+// A ValueInfo is an ONNX ValueInfoProto describing a
+// tensor.
+//
+// A nil Shape means that the shape is unknown.
+type ValueInfo struct {
+	Name     string
+	ElemType int
+	Shape    []Dimension
+}
+
+// A Dimension is one dimension of a tensor shape.
+// If Param is non-empty, the dimension is symbolic.
+type Dimension struct {
+	Value int64
+	Param string
+}
+
+// MarshalBinary encodes the model in the protobuf format
+// used by ONNX files.
+func (m *Model) MarshalBinary() ([]byte, error) {
+	if m.Graph == nil {
+		return nil, errors.New("marshal Model: missing graph")
+	}
+	var e protoEncoder
+	e.Varint(1, uint64(m.IRVersion))
+	e.String(2, m.ProducerName)
+	e.Message(7, m.Graph.encode())
+	var opset protoEncoder
+	opset.String(1, "")
+	opset.Varint(2, uint64(m.OpsetVersion))
+	e.Message(8, opset.Bytes())
+	return e.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a model from the protobuf
+// format used by ONNX files.
+func (m *Model) UnmarshalBinary(data []byte) error {
+	*m = Model{}
+	err := decodeFields(data, func(f *protoField) error {
+		switch f.Num {
+		case 1:
+			m.IRVersion = int64(f.Varint)
+		case 2:
+			m.ProducerName = string(f.Bytes)
+		case 7:
+			m.Graph = &Graph{}
+			return m.Graph.decode(f.Bytes)
+		case 8:
+			return decodeFields(f.Bytes, func(f *protoField) error {
+				if f.Num == 2 {
+					m.OpsetVersion = int64(f.Varint)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unmarshal Model: %s", err)
+	}
+	return nil
+}
+
+func (g *Graph) encode() []byte {
+	var e protoEncoder
+	for _, n := range g.Nodes {
+		e.Message(1, n.encode())
+	}
+	e.String(2, g.Name)
+	for _, t := range g.Initializers {
+		e.Message(5, t.encode())
+	}
+	for _, v := range g.Inputs {
+		e.Message(11, v.encode())
+	}
+	for _, v := range g.Outputs {
+		e.Message(12, v.encode())
+	}
+	return e.Bytes()
+}
+
+func (g *Graph) decode(data []byte) error {
+	return decodeFields(data, func(f *protoField) error {
+		switch f.Num {
+		case 1:
+			n := &Node{}
+			g.Nodes = append(g.Nodes, n)
+			return n.decode(f.Bytes)
+		case 2:
+			g.Name = string(f.Bytes)
+		case 5:
+			t := &Tensor{}
+			g.Initializers = append(g.Initializers, t)
+			return t.decode(f.Bytes)
+		case 11, 12:
+			v := &ValueInfo{}
+			if f.Num == 11 {
+				g.Inputs = append(g.Inputs, v)
+			} else {
+				g.Outputs = append(g.Outputs, v)
+			}
+			return v.decode(f.Bytes)
+		}
+		return nil
+	})
+}
+
+func (n *Node) encode() []byte {
+	var e protoEncoder
+	for _, in := range n.Inputs {
+		e.RepeatedString(1, in)
+	}
+	for _, out := range n.Outputs {
+		e.RepeatedString(2, out)
+	}
+	e.String(3, n.Name)
+	e.String(4, n.OpType)
+	for _, a := range n.Attributes {
+		e.Message(5, a.encode())
+	}
+	return e.Bytes()
+}
+
+func (n *Node) decode(data []byte) error {
+	return decodeFields(data, func(f *protoField) error {
+		switch f.Num {
+		case 1:
+			n.Inputs = append(n.Inputs, string(f.Bytes))
+		case 2:
+			n.Outputs = append(n.Outputs, string(f.Bytes))
+		case 3:
+			n.Name = string(f.Bytes)
+		case 4:
+			n.OpType = string(f.Bytes)
+		case 5:
+			a := &Attribute{}
+			n.Attributes = append(n.Attributes, a)
+			return a.decode(f.Bytes)
+		}
+		return nil
+	})
+}
+
+func (a *Attribute) encode() []byte {
+	var e protoEncoder
+	e.String(1, a.Name)
+	switch a.Type {
+	case AttrFloat:
+		e.Fixed32(2, math.Float32bits(a.F))
+	case AttrInt:
+		e.Varint(3, uint64(a.I))
+	case AttrString:
+		e.Message(4, []byte(a.S))
+	case AttrTensor:
+		e.Message(5, a.T.encode())
+	case AttrGraph:
+		e.Message(6, a.G.encode())
+	case AttrFloats:
+		for _, x := range a.Floats {
+			e.Fixed32(7, math.Float32bits(x))
+		}
+	case AttrInts:
+		for _, x := range a.Ints {
+			e.Varint(8, uint64(x))
+		}
+	}
+	e.Varint(20, uint64(a.Type))
+	return e.Bytes()
+}
+
+func (a *Attribute) decode(data []byte) error {
+	return decodeFields(data, func(f *protoField) error {
+		switch f.Num {
+		case 1:
+			a.Name = string(f.Bytes)
+		case 2:
+			a.F = math.Float32frombits(uint32(f.Varint))
+		case 3:
+			a.I = int64(f.Varint)
+		case 4:
+			a.S = string(f.Bytes)
+		case 5:
+			a.T = &Tensor{}
+			return a.T.decode(f.Bytes)
+		case 6:
+			a.G = &Graph{}
+			return a.G.decode(f.Bytes)
+		case 7:
+			return f.EachFixed32(func(x uint32) {
+				a.Floats = append(a.Floats, math.Float32frombits(x))
+			})
+		case 8:
+			return f.EachVarint(func(x uint64) {
+				a.Ints = append(a.Ints, int64(x))
+			})
+		case 20:
+			a.Type = int(f.Varint)
+		}
+		return nil
+	})
+}
+
+func (t *Tensor) encode() []byte {
+	var e protoEncoder
+	for _, d := range t.Dims {
+		e.Varint(1, uint64(d))
+	}
+	e.Varint(2, uint64(t.DataType))
+	if len(t.FloatData) > 0 {
+		packed := make([]byte, 4*len(t.FloatData))
+		for i, x := range t.FloatData {
+			binary.LittleEndian.PutUint32(packed[4*i:], math.Float32bits(x))
+		}
+		e.Message(4, packed)
+	}
+	if len(t.Int64Data) > 0 {
+		var packed []byte
+		for _, x := range t.Int64Data {
+			packed = appendVarint(packed, uint64(x))
+		}
+		e.Message(7, packed)
+	}
+	e.String(8, t.Name)
+	return e.Bytes()
+}
+
+func (t *Tensor) decode(data []byte) error {
+	return decodeFields(data, func(f *protoField) error {
+		switch f.Num {
+		case 1:
+			return f.EachVarint(func(x uint64) {
+				t.Dims = append(t.Dims, int64(x))
+			})
+		case 2:
+			t.DataType = int(f.Varint)
+		case 4:
+			return f.EachFixed32(func(x uint32) {
+				t.FloatData = append(t.FloatData, math.Float32frombits(x))
+			})
+		case 7:
+			return f.EachVarint(func(x uint64) {
+				t.Int64Data = append(t.Int64Data, int64(x))
+			})
+		case 8:
+			t.Name = string(f.Bytes)
+		case 9:
+			return t.decodeRaw(f.Bytes)
+		}
+		return nil
+	})
+}
+
+// decodeRaw decodes the raw_data field, which some
+// exporters use instead of the typed data fields.
+func (t *Tensor) decodeRaw(data []byte) error {
+	switch t.DataType {
+	case Float:
+		if len(data)%4 != 0 {
+			return errors.New("invalid raw float data")
+		}
+		for i := 0; i < len(data); i += 4 {
+			x := binary.LittleEndian.Uint32(data[i:])
+			t.FloatData = append(t.FloatData, math.Float32frombits(x))
+		}
+	case Int64:
+		if len(data)%8 != 0 {
+			return errors.New("invalid raw int64 data")
+		}
+		for i := 0; i < len(data); i += 8 {
+			t.Int64Data = append(t.Int64Data, int64(binary.LittleEndian.Uint64(data[i:])))
+		}
+	default:
+		return fmt.Errorf("unsupported raw data type: %d", t.DataType)
+	}
+	return nil
+}
+
+func (v *ValueInfo) encode() []byte {
+	var shape protoEncoder
+	for _, d := range v.Shape {
+		var dim protoEncoder
+		if d.Param != "" {
+			dim.String(2, d.Param)
+		} else {
+			dim.Varint(1, uint64(d.Value))
+		}
+		shape.Message(1, dim.Bytes())
+	}
+	var tensorType protoEncoder
+	tensorType.Varint(1, uint64(v.ElemType))
+	if v.Shape != nil {
+		tensorType.Message(2, shape.Bytes())
+	}
+	var typeProto protoEncoder
+	typeProto.Message(1, tensorType.Bytes())
+
+	var e protoEncoder
+	e.String(1, v.Name)
+	e.Message(2, typeProto.Bytes())
+	return e.Bytes()
+}
+
+func (v *ValueInfo) decode(data []byte) error {
+	return decodeFields(data, func(f *protoField) error {
+		switch f.Num {
+		case 1:
+			v.Name = string(f.Bytes)
+		case 2:
+			return decodeFields(f.Bytes, func(f *protoField) error {
+				if f.Num != 1 {
+					return nil
+				}
+				return v.decodeTensorType(f.Bytes)
+			})
+		}
+		return nil
+	})
+}
+
+func (v *ValueInfo) decodeTensorType(data []byte) error {
+	return decodeFields(data, func(f *protoField) error {
+		switch f.Num {
+		case 1:
+			v.ElemType = int(f.Varint)
+		case 2:
+			v.Shape = []Dimension{}
+			return decodeFields(f.Bytes, func(f *protoField) error {
+				if f.Num != 1 {
+					return nil
+				}
+				var d Dimension
+				v.Shape = append(v.Shape, d)
+				dim := &v.Shape[len(v.Shape)-1]
+				return decodeFields(f.Bytes, func(f *protoField) error {
+					switch f.Num {
+					case 1:
+						dim.Value = int64(f.Varint)
+					case 2:
+						dim.Param = string(f.Bytes)
+					}
+					return nil
+				})
+			})
+		}
+		return nil
+	})
+}
+
+// These are protobuf wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// protoEncoder builds a protobuf message.
+type protoEncoder struct {
+	buf []byte
+}
+
+func (p *protoEncoder) Bytes() []byte {
+	return p.buf
+}
+
+func (p *protoEncoder) Varint(field int, x uint64) {
+	p.tag(field, wireVarint)
+	p.buf = appendVarint(p.buf, x)
+}
+
+func (p *protoEncoder) Fixed32(field int, x uint32) {
+	p.tag(field, wireFixed32)
+	var data [4]byte
+	binary.LittleEndian.PutUint32(data[:], x)
+	p.buf = append(p.buf, data[:]...)
+}
+
+func (p *protoEncoder) Message(field int, data []byte) {
+	p.tag(field, wireBytes)
+	p.buf = appendVarint(p.buf, uint64(len(data)))
+	p.buf = append(p.buf, data...)
+}
+
+// String encodes an optional string, omitting it if it is
+// empty.
+func (p *protoEncoder) String(field int, s string) {
+	if s != "" {
+		p.Message(field, []byte(s))
+	}
+}
+
+// RepeatedString encodes an element of a repeated string
+// field, which may be empty.
+func (p *protoEncoder) RepeatedString(field int, s string) {
+	p.Message(field, []byte(s))
+}
+
+func (p *protoEncoder) tag(field, wireType int) {
+	p.buf = appendVarint(p.buf, uint64(field<<3|wireType))
+}
+
+func appendVarint(buf []byte, x uint64) []byte {
+	for x >= 0x80 {
+		buf = append(buf, byte(x)|0x80)
+		x >>= 7
+	}
+	return append(buf, byte(x))
+}
+
+// protoField is a decoded protobuf field.
+//
+// For varint and fixed-size fields, the value is stored
+// in Varint.
+// For length-delimited fields, the value is stored in
+// Bytes.
+type protoField struct {
+	Num      int
+	WireType int
+	Varint   uint64
+	Bytes    []byte
+}
+
+// EachVarint calls f for every value of a repeated varint
+// field, which may or may not be packed.
+func (p *protoField) EachVarint(f func(x uint64)) error {
+	if p.WireType != wireBytes {
+		f(p.Varint)
+		return nil
+	}
+	data := p.Bytes
+	for len(data) > 0 {
+		x, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errors.New("invalid packed varint")
+		}
+		f(x)
+		data = data[n:]
+	}
+	return nil
+}
+
+// EachFixed32 calls f for every value of a repeated
+// fixed32 field, which may or may not be packed.
+func (p *protoField) EachFixed32(f func(x uint32)) error {
+	if p.WireType != wireBytes {
+		f(uint32(p.Varint))
+		return nil
+	}
+	if len(p.Bytes)%4 != 0 {
+		return errors.New("invalid packed fixed32")
+	}
+	for i := 0; i < len(p.Bytes); i += 4 {
+		f(binary.LittleEndian.Uint32(p.Bytes[i:]))
+	}
+	return nil
+}
+
+// decodeFields calls f for every field in a protobuf
+// message.
+func decodeFields(data []byte, f func(p *protoField) error) error {
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errors.New("invalid field tag")
+		}
+		data = data[n:]
+		field := &protoField{Num: int(tag >> 3), WireType: int(tag & 7)}
+		switch field.WireType {
+		case wireVarint:
+			field.Varint, n = binary.Uvarint(data)
+			if n <= 0 {
+				return errors.New("invalid varint")
+			}
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return errors.New("unexpected end of message")
+			}
+			field.Varint = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case wireFixed32:
+			if len(data) < 4 {
+				return errors.New("unexpected end of message")
+			}
+			field.Varint = uint64(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+		case wireBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				return errors.New("invalid length-delimited field")
+			}
+			field.Bytes = data[n : n+int(size)]
+			data = data[n+int(size):]
+		default:
+			return fmt.Errorf("unsupported wire type: %d", field.WireType)
+		}
+		if err := f(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package anyonnx
+
+import (
+	"fmt"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+)
+
+// ExportBlock converts a recurrent block into an ONNX
+// model.
+//
+// The model has one input, named "input", with shape
+// [time, batch, inSize], and one output, named "output",
+// with shape [time, batch, outSize].
+// All of the sequences in a batch must have the same
+// length.
+//
+// The block is converted to a Scan node whose body
+// computes a single timestep.
+// Unlike the built-in ONNX LSTM operator, this preserves
+// arbitrary gate activations and peephole connections.
+//
+// Recurrent dropout and zoneout are supported as long as
+// they are disabled.
+func ExportBlock(block anyrnn.Block, inSize int) (m *Model, err error) {
+	defer essentials.AddCtxTo("export ONNX", &err)
+	g := newGraphBuilder()
+	shape := g.Node("Shape", []string{"input"})
+	b := &blockConverter{
+		Outer: g,
+		Body:  g.Subgraph("step"),
+		Batch: g.Node("Gather", []string{shape, g.IntsConst("index", 1)},
+			intAttr("axis", 0)),
+	}
+
+	stepIn := b.Body.Name("step_input")
+	out, states, err := b.Block(block, &value{Name: stepIn, Size: inSize})
+	if err != nil {
+		return nil, err
+	}
+	out = b.Body.Flatten(out)
+	stepOut := b.Body.Node("Identity", []string{out.Name})
+
+	var scanInputs, scanOutputs []string
+	body := b.Body.Graph
+	for _, s := range states {
+		body.Inputs = append(body.Inputs, floatInfo(s.In, symbolicDim("batch"),
+			fixedDim(s.Size)))
+		body.Outputs = append(body.Outputs, floatInfo(s.Out, symbolicDim("batch"),
+			fixedDim(s.Size)))
+		scanInputs = append(scanInputs, s.Init)
+		scanOutputs = append(scanOutputs, g.Name("final_state"))
+	}
+	body.Inputs = append(body.Inputs, floatInfo(stepIn, symbolicDim("batch"),
+		fixedDim(inSize)))
+	body.Outputs = append(body.Outputs, floatInfo(stepOut, symbolicDim("batch"),
+		fixedDim(out.Size)))
+	g.AddNode("Scan", append(scanInputs, "input"), append(scanOutputs, "output"),
+		graphAttr("body", body), intAttr("num_scan_inputs", 1))
+
+	g.Graph.Inputs = []*ValueInfo{
+		floatInfo("input", symbolicDim("time"), symbolicDim("batch"), fixedDim(inSize)),
+	}
+	g.Graph.Outputs = []*ValueInfo{
+		floatInfo("output", symbolicDim("time"), symbolicDim("batch"), fixedDim(out.Size)),
+	}
+	return newModel(g.Graph), nil
+}
+
+// scanState is a recurrent state carried between the
+// iterations of a Scan.
+type scanState struct {
+	Size int
+
+	// Init is the initial state in the outer graph.
+	Init string
+
+	// In and Out are the state before and after a step,
+	// in the body graph.
+	In  string
+	Out string
+}
+
+// blockConverter converts blocks into the body of a Scan.
+type blockConverter struct {
+	Outer *graphBuilder
+	Body  *graphBuilder
+
+	// Batch is a 1-D tensor in the outer graph containing
+	// the batch size.
+	Batch string
+}
+
+// Block converts a block and applies it to the input of
+// a single timestep.
+func (b *blockConverter) Block(block anyrnn.Block, in *value) (*value,
+	[]*scanState, error) {
+	switch block := block.(type) {
+	case anyrnn.Stack:
+		var states []*scanState
+		for _, sub := range block {
+			var subStates []*scanState
+			var err error
+			in, subStates, err = b.Block(sub, in)
+			if err != nil {
+				return nil, nil, err
+			}
+			states = append(states, subStates...)
+		}
+		return in, states, nil
+	case *anyrnn.LayerBlock:
+		out, err := b.Body.Layer(block.Layer, in)
+		return out, nil, err
+	case *anyrnn.Vanilla:
+		return b.vanilla(block, in)
+	case *anyrnn.LSTM:
+		return b.lstm(block, in)
+	default:
+		return nil, nil, fmt.Errorf("unsupported block: %T", block)
+	}
+}
+
+func (b *blockConverter) vanilla(v *anyrnn.Vanilla, in *value) (*value, []*scanState,
+	error) {
+	if in.Size != v.InCount {
+		return nil, nil, sizeError(v, v.InCount, in.Size)
+	}
+	if err := checkDropout(v, &v.Dropout); err != nil {
+		return nil, nil, err
+	}
+	state := b.state(v.StartState.Vector)
+	x := b.dropout(b.Body.Flatten(in).Name, v.Dropout.InKeepProb)
+	lastOut := b.dropout(state.In, v.Dropout.StateKeepProb)
+	sum := b.Body.Node("Add", []string{
+		b.Body.MatMul(lastOut, v.StateWeights.Vector, v.OutCount, v.OutCount),
+		b.Body.MatMul(x, v.InputWeights.Vector, v.InCount, v.OutCount),
+	})
+	biases := b.Body.FloatConst("biases", []int64{int64(v.OutCount)},
+		vectorData(v.Biases.Vector))
+	out, err := b.activation(v.Activation, b.Body.Node("Add", []string{sum, biases}),
+		v.OutCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	state.Out = out
+	return &value{Name: out, Size: v.OutCount}, []*scanState{state}, nil
+}
+
+func (b *blockConverter) lstm(l *anyrnn.LSTM, in *value) (*value, []*scanState, error) {
+	stateSize := l.InitLastOut.Vector.Len()
+	inSize := l.In.InputWeights.Vector.Len() / stateSize
+	if in.Size != inSize {
+		return nil, nil, sizeError(l, inSize, in.Size)
+	}
+	if err := checkDropout(l, &l.Dropout); err != nil {
+		return nil, nil, err
+	}
+	if l.Zoneout.Enabled && (l.Zoneout.CellProb != 0 || l.Zoneout.HiddenProb != 0) {
+		return nil, nil, fmt.Errorf("block %T: zoneout must be disabled", l)
+	}
+
+	lastOut := b.state(l.InitLastOut.Vector)
+	lastInternal := b.state(l.InitInternal.Vector)
+	x := b.dropout(b.Body.Flatten(in).Name, l.Dropout.InKeepProb)
+	gateLastOut := b.dropout(lastOut.In, l.Dropout.StateKeepProb)
+
+	gate := func(gate *anyrnn.LSTMGate, internal string) (string, error) {
+		sum := b.Body.Node("Add", []string{
+			b.Body.MatMul(gateLastOut, gate.StateWeights.Vector, stateSize, stateSize),
+			b.Body.MatMul(x, gate.InputWeights.Vector, inSize, stateSize),
+		})
+		size := []int64{int64(stateSize)}
+		peephole := b.Body.FloatConst("peephole", size, vectorData(gate.Peephole.Vector))
+		biases := b.Body.FloatConst("biases", size, vectorData(gate.Biases.Vector))
+		peep := b.Body.Node("Mul", []string{internal, peephole})
+		sum = b.Body.Node("Add", []string{sum, b.Body.Node("Add", []string{peep, biases})})
+		return b.activation(gate.Activation, sum, stateSize)
+	}
+
+	var gates [3]string
+	for i, g := range []*anyrnn.LSTMGate{l.InValue, l.In, l.Remember} {
+		var err error
+		gates[i], err = gate(g, lastInternal.In)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	internal := b.Body.Node("Add", []string{
+		b.Body.Node("Mul", []string{gates[0], gates[1]}),
+		b.Body.Node("Mul", []string{lastInternal.In, gates[2]}),
+	})
+	internal = b.zoneout(lastInternal.In, internal, l.Zoneout.CellProb)
+
+	outGate, err := gate(l.Output, internal)
+	if err != nil {
+		return nil, nil, err
+	}
+	squashed, err := b.activation(l.OutSquash, internal, stateSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	out := b.Body.Node("Mul", []string{outGate, squashed})
+	out = b.zoneout(lastOut.In, out, l.Zoneout.HiddenProb)
+
+	lastOut.Out = out
+	lastInternal.Out = internal
+	return &value{Name: out, Size: stateSize}, []*scanState{lastOut, lastInternal}, nil
+}
+
+// state creates a recurrent state whose initial value is
+// repeated for every sequence in the batch.
+func (b *blockConverter) state(init anyvec.Vector) *scanState {
+	size := init.Len()
+	initVec := b.Outer.FloatConst("init_state", []int64{int64(size)}, vectorData(init))
+	shape := b.Outer.Node("Concat", []string{b.Batch,
+		b.Outer.IntsConst("state_size", int64(size))}, intAttr("axis", 0))
+	return &scanState{
+		Size: size,
+		Init: b.Outer.Node("Expand", []string{initVec, shape}),
+		In:   b.Body.Name("state"),
+	}
+}
+
+// activation applies an activation layer to a batch of
+// vectors in the body graph.
+func (b *blockConverter) activation(layer anynet.Layer, name string, size int) (string,
+	error) {
+	out, err := b.Body.Layer(layer, &value{Name: name, Size: size})
+	if err != nil {
+		return "", err
+	}
+	if out.Size != size {
+		return "", fmt.Errorf("layer %T: output size %d should be %d", layer, out.Size,
+			size)
+	}
+	return b.Body.Flatten(out).Name, nil
+}
+
+// dropout applies the expected scale of a disabled
+// RecurrentDropout.
+func (b *blockConverter) dropout(name string, keepProb float64) string {
+	if keepProb == 0 || keepProb == 1 {
+		return name
+	}
+	return b.Body.Scale(name, keepProb)
+}
+
+// zoneout applies the expected value of a disabled
+// Zoneout.
+func (b *blockConverter) zoneout(old, newVal string, prob float64) string {
+	if prob == 0 {
+		return newVal
+	}
+	return b.Body.Node("Add", []string{
+		b.Body.Scale(old, prob),
+		b.Body.Scale(newVal, 1-prob),
+	})
+}
+
+func checkDropout(block anyrnn.Block, d *anyrnn.RecurrentDropout) error {
+	if !d.Enabled {
+		return nil
+	}
+	for _, p := range []float64{d.InKeepProb, d.StateKeepProb} {
+		if p != 0 && p != 1 {
+			return fmt.Errorf("block %T: recurrent dropout must be disabled", block)
+		}
+	}
+	return nil
+}
@@ -1,9 +1,13 @@
 package anyrnn
 
 import (
+	"fmt"
+	"math/rand"
+
 	"github.com/unixpickle/anydiff"
 	"github.com/unixpickle/anydiff/anyseq"
 	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
 	"github.com/unixpickle/essentials"
 	"github.com/unixpickle/serializer"
 )
@@ -30,6 +34,21 @@ type Bidir struct {
 	Mixer    anynet.Mixer
 }
 
+// NewBidirLSTM creates a Bidir with an LSTM in each
+// direction.
+// Both LSTMs have hiddenSize outputs, which are combined
+// using a Mixer created by mix.
+//
+// The output size of the result is
+// mix.OutSize(hiddenSize).
+func NewBidirLSTM(c anyvec.Creator, inSize, hiddenSize int, mix BidirMix) *Bidir {
+	return &Bidir{
+		Forward:  NewLSTM(c, inSize, hiddenSize),
+		Backward: NewLSTM(c, inSize, hiddenSize),
+		Mixer:    mix.Mixer(c, hiddenSize),
+	}
+}
+
 // DeserializeBidir deserializes a Bidir.
 func DeserializeBidir(d []byte) (*Bidir, error) {
 	var res Bidir
@@ -57,6 +76,12 @@ func (b *Bidir) Parameters() []*anydiff.Var {
 	return anynet.AllParameters(b.Forward, b.Backward, b.Mixer)
 }
 
+// SetRand sets the source of randomness for the blocks
+// and Mixer if they implement anynet.RandSetter.
+func (b *Bidir) SetRand(r *rand.Rand) {
+	anynet.SetAllRand(r, b.Forward, b.Backward, b.Mixer)
+}
+
 // SerializerType returns the unique ID used to serialize
 // a Bidir with the serializer package.
 func (b *Bidir) SerializerType() string {
@@ -67,3 +92,48 @@ func (b *Bidir) SerializerType() string {
 func (b *Bidir) Serialize() ([]byte, error) {
 	return serializer.SerializeAny(b.Forward, b.Backward, b.Mixer)
 }
+
+// BidirMix specifies how to combine the outputs of the
+// two directions of a Bidir.
+type BidirMix int
+
+const (
+	// BidirSum adds the forward and backward outputs.
+	BidirSum BidirMix = iota
+
+	// BidirConcat concatenates the forward and backward
+	// outputs.
+	BidirConcat
+
+	// BidirLearned applies a fully-connected layer to each
+	// output, adds the results, and applies tanh.
+	BidirLearned
+)
+
+// Mixer creates a Mixer for forward and backward outputs
+// of size hiddenSize.
+func (b BidirMix) Mixer(c anyvec.Creator, hiddenSize int) anynet.Mixer {
+	switch b {
+	case BidirSum:
+		return &anynet.AddMixer{In1: anynet.Net{}, In2: anynet.Net{}, Out: anynet.Net{}}
+	case BidirConcat:
+		return anynet.ConcatMixer{}
+	case BidirLearned:
+		return &anynet.AddMixer{
+			In1: anynet.NewFC(c, hiddenSize, hiddenSize),
+			In2: anynet.NewFC(c, hiddenSize, hiddenSize),
+			Out: anynet.Tanh,
+		}
+	default:
+		panic(fmt.Sprintf("unknown BidirMix: %d", b))
+	}
+}
+
+// OutSize returns the size of the mixed output vectors
+// for forward and backward outputs of size hiddenSize.
+func (b BidirMix) OutSize(hiddenSize int) int {
+	if b == BidirConcat {
+		return hiddenSize * 2
+	}
+	return hiddenSize
+}
@@ -0,0 +1,69 @@
+package anyrnn
+
+import (
+	"testing"
+
+	"github.com/unixpickle/anydiff/anydifftest"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestBidirStackOutSize(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	inSeq, _ := randomTestSequence(c, 3)
+	stack := NewBidirStack(c, 3, []int{2, 3, 4},
+		[]BidirMix{BidirConcat, BidirSum, BidirLearned})
+	for _, out := range stack.Apply(inSeq).Output() {
+		if out.Packed.Len() != out.NumPresent()*4 {
+			t.Fatalf("expected output size 4 but got %d",
+				out.Packed.Len()/out.NumPresent())
+		}
+	}
+}
+
+func TestBidirStackProp(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	inSeq, inVars := randomTestSequence(c, 3)
+	stack := NewBidirStack(c, 3, []int{2, 2, 2},
+		[]BidirMix{BidirConcat, BidirSum, BidirLearned})
+	checker := &anydifftest.SeqChecker{
+		F: func() anyseq.Seq {
+			return stack.Apply(inSeq)
+		},
+		V: append(inVars, stack.Parameters()...),
+	}
+	checker.FullCheck(t)
+}
+
+func TestGroupObjects(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	b1 := NewLSTM(c, 3, 2)
+	b2 := NewLSTM(c, 2, 2)
+	bidir := NewBidirLSTM(c, 2, 2, BidirSum)
+
+	res, err := groupObjects([]interface{}{b1, b2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stack, ok := res.(Stack); !ok || len(stack) != 2 {
+		t.Errorf("unexpected result: %v", res)
+	}
+
+	res, err = groupObjects([]interface{}{b1, bidir, b2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seqStack, ok := res.(SeqStack)
+	if !ok || len(seqStack) != 3 {
+		t.Fatalf("unexpected result: %v", res)
+	}
+	if m, ok := seqStack[0].(*MapBlock); !ok || len(m.Block.(Stack)) != 1 {
+		t.Errorf("unexpected first layer: %v", seqStack[0])
+	}
+	if seqStack[1] != bidir {
+		t.Errorf("unexpected second layer: %v", seqStack[1])
+	}
+	if m, ok := seqStack[2].(*MapBlock); !ok || m.Block.(Stack)[0] != b2 {
+		t.Errorf("unexpected last layer: %v", seqStack[2])
+	}
+}
@@ -0,0 +1,273 @@
+package anyrnn
+
+import (
+	"math/rand"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+)
+
+func init() {
+	var r RecurrentDropout
+	serializer.RegisterTypedDeserializer(r.SerializerType(), DeserializeRecurrentDropout)
+	var z Zoneout
+	serializer.RegisterTypedDeserializer(z.SerializerType(), DeserializeZoneout)
+}
+
+// RecurrentDropout configures variational dropout for the
+// inputs and recurrent connections of a Block.
+//
+// Unlike anynet.Dropout, which draws a new mask every time
+// it is applied, RecurrentDropout draws one mask for each
+// sequence when the start state is created, and then uses
+// that mask at every timestep.
+// For details, see https://arxiv.org/abs/1512.05287.
+//
+// When disabled, the inputs and recurrent states are
+// scaled by their keep probabilities to compute the
+// "expected output".
+//
+// A keep probability of 0 is treated like a probability
+// of 1, so the zero value of RecurrentDropout has no
+// effect.
+type RecurrentDropout struct {
+	Enabled bool
+
+	// InKeepProb is the probability of keeping any given
+	// input component.
+	InKeepProb float64
+
+	// StateKeepProb is the probability of keeping any
+	// given component of the recurrent state.
+	StateKeepProb float64
+
+	// Rand, if non-nil, is used to generate masks.
+	// Otherwise, the global source from math/rand is used.
+	Rand *rand.Rand
+}
+
+// DeserializeRecurrentDropout deserializes a
+// RecurrentDropout.
+func DeserializeRecurrentDropout(d []byte) (*RecurrentDropout, error) {
+	var res RecurrentDropout
+	err := serializer.DeserializeAny(d, &res.Enabled, &res.InKeepProb, &res.StateKeepProb)
+	if err != nil {
+		return nil, essentials.AddCtx("deserialize RecurrentDropout", err)
+	}
+	return &res, nil
+}
+
+// Masks creates masks for a batch of n sequences.
+//
+// The result is nil if no masking is necessary.
+func (r *RecurrentDropout) Masks(c anyvec.Creator, n, inSize, stateSize int) *DropoutMasks {
+	inMask := dropoutMask(c, r.Rand, r.Enabled, r.InKeepProb, n*inSize)
+	stateMask := dropoutMask(c, r.Rand, r.Enabled, r.StateKeepProb, n*stateSize)
+	if inMask == nil && stateMask == nil {
+		return nil
+	}
+	present := make(PresentMap, n)
+	for i := range present {
+		present[i] = true
+	}
+	res := &DropoutMasks{}
+	if inMask != nil {
+		res.In = &VecState{Vector: inMask, PresentMap: present}
+	}
+	if stateMask != nil {
+		res.State = &VecState{Vector: stateMask, PresentMap: present}
+	}
+	return res
+}
+
+// SerializerType returns the unique ID used to serialize
+// a RecurrentDropout with the serializer package.
+func (r *RecurrentDropout) SerializerType() string {
+	return "github.com/unixpickle/anynet/anyrnn.RecurrentDropout"
+}
+
+// Serialize serializes the RecurrentDropout.
+func (r *RecurrentDropout) Serialize() ([]byte, error) {
+	return serializer.SerializeAny(r.Enabled, r.InKeepProb, r.StateKeepProb)
+}
+
+// DropoutMasks stores the masks which a RecurrentDropout
+// applies to a batch of sequences.
+//
+// A nil mask leaves the corresponding vector untouched.
+// All methods of DropoutMasks may be called on a nil
+// *DropoutMasks, in which case nothing is masked.
+type DropoutMasks struct {
+	In    *VecState
+	State *VecState
+}
+
+// Reduce produces masks for a subset of the sequences.
+func (d *DropoutMasks) Reduce(p PresentMap) *DropoutMasks {
+	if d == nil {
+		return nil
+	}
+	res := &DropoutMasks{}
+	if d.In != nil {
+		res.In = d.In.Reduce(p).(*VecState)
+	}
+	if d.State != nil {
+		res.State = d.State.Reduce(p).(*VecState)
+	}
+	return res
+}
+
+// MaskIn applies the input mask to a batch of inputs.
+func (d *DropoutMasks) MaskIn(in anydiff.Res) anydiff.Res {
+	if d == nil || d.In == nil {
+		return in
+	}
+	return anydiff.Mul(in, anydiff.NewConst(d.In.Vector))
+}
+
+// MaskState applies the state mask to a batch of
+// recurrent states.
+func (d *DropoutMasks) MaskState(state anydiff.Res) anydiff.Res {
+	if d == nil || d.State == nil {
+		return state
+	}
+	return anydiff.Mul(state, anydiff.NewConst(d.State.Vector))
+}
+
+// DropoutState is the State type used by blocks with a
+// RecurrentDropout.
+// It wraps the block's regular State and keeps track of
+// the masks for each sequence.
+//
+// The masks are constants, so the corresponding StateGrad
+// is simply the StateGrad of the regular State.
+type DropoutState struct {
+	State State
+	Masks *DropoutMasks
+}
+
+// Present returns the present map of the wrapped State.
+func (d *DropoutState) Present() PresentMap {
+	return d.State.Present()
+}
+
+// Reduce reduces the wrapped State and the masks.
+func (d *DropoutState) Reduce(p PresentMap) State {
+	return &DropoutState{
+		State: d.State.Reduce(p),
+		Masks: d.Masks.Reduce(p),
+	}
+}
+
+// Zoneout configures zoneout regularization for the cell
+// and hidden states of an LSTM.
+//
+// With zoneout, each state component randomly keeps its
+// previous value at each timestep instead of being
+// updated.
+// For details, see https://arxiv.org/abs/1606.01305.
+//
+// When disabled, each state component is set to the
+// "expected value" p*old + (1-p)*new, where p is the
+// corresponding zoneout probability.
+//
+// The zero value of Zoneout has no effect.
+type Zoneout struct {
+	Enabled bool
+
+	// CellProb is the probability that any given component
+	// of the cell (internal) state keeps its old value.
+	CellProb float64
+
+	// HiddenProb is the probability that any given
+	// component of the hidden state (i.e. the output)
+	// keeps its old value.
+	HiddenProb float64
+
+	// Rand, if non-nil, is used to decide which components
+	// keep their old values.
+	// Otherwise, the global source from math/rand is used.
+	Rand *rand.Rand
+}
+
+// DeserializeZoneout deserializes a Zoneout.
+func DeserializeZoneout(d []byte) (*Zoneout, error) {
+	var res Zoneout
+	err := serializer.DeserializeAny(d, &res.Enabled, &res.CellProb, &res.HiddenProb)
+	if err != nil {
+		return nil, essentials.AddCtx("deserialize Zoneout", err)
+	}
+	return &res, nil
+}
+
+// SerializerType returns the unique ID used to serialize
+// a Zoneout with the serializer package.
+func (z *Zoneout) SerializerType() string {
+	return "github.com/unixpickle/anynet/anyrnn.Zoneout"
+}
+
+// Serialize serializes the Zoneout.
+func (z *Zoneout) Serialize() ([]byte, error) {
+	return serializer.SerializeAny(z.Enabled, z.CellProb, z.HiddenProb)
+}
+
+func (z *Zoneout) cell(old, newVal anydiff.Res) anydiff.Res {
+	return zoneoutMix(z.Rand, z.Enabled, z.CellProb, old, newVal)
+}
+
+func (z *Zoneout) hidden(old, newVal anydiff.Res) anydiff.Res {
+	return zoneoutMix(z.Rand, z.Enabled, z.HiddenProb, old, newVal)
+}
+
+func zoneoutMix(r *rand.Rand, enabled bool, prob float64, old, newVal anydiff.Res) anydiff.Res {
+	if prob == 0 {
+		return newVal
+	}
+	c := newVal.Output().Creator()
+	keepMask := c.MakeVector(newVal.Output().Len())
+	if enabled {
+		anyvec.Rand(keepMask, anyvec.Uniform, r)
+		anyvec.LessThan(keepMask, c.MakeNumeric(prob))
+	} else {
+		keepMask.AddScalar(c.MakeNumeric(prob))
+	}
+	updateMask := keepMask.Copy()
+	anyvec.Complement(updateMask)
+	return anydiff.Add(
+		anydiff.Mul(old, anydiff.NewConst(keepMask)),
+		anydiff.Mul(newVal, anydiff.NewConst(updateMask)),
+	)
+}
+
+// dropoutMask creates a mask of the given size, or nil if
+// nothing would be dropped.
+func dropoutMask(c anyvec.Creator, r *rand.Rand, enabled bool, keepProb float64,
+	size int) anyvec.Vector {
+	if keepProb == 0 || keepProb == 1 {
+		return nil
+	}
+	mask := c.MakeVector(size)
+	if enabled {
+		anyvec.Rand(mask, anyvec.Uniform, r)
+		anyvec.LessThan(mask, c.MakeNumeric(keepProb))
+	} else {
+		mask.AddScalar(c.MakeNumeric(keepProb))
+	}
+	return mask
+}
+
+func wrapDropoutState(s State, m *DropoutMasks) State {
+	if m == nil {
+		return s
+	}
+	return &DropoutState{State: s, Masks: m}
+}
+
+func unwrapDropoutState(s State) (State, *DropoutMasks) {
+	if ds, ok := s.(*DropoutState); ok {
+		return ds.State, ds.Masks
+	}
+	return s, nil
+}
@@ -0,0 +1,97 @@
+package anyrnn
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anydifftest"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestRecurrentDropoutRand(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	var masks []*DropoutMasks
+	for i := 0; i < 2; i++ {
+		dropout := &RecurrentDropout{
+			Enabled:       true,
+			InKeepProb:    0.5,
+			StateKeepProb: 0.5,
+			Rand:          rand.New(rand.NewSource(1337)),
+		}
+		masks = append(masks, dropout.Masks(c, 3, 10, 10))
+	}
+	if !reflect.DeepEqual(masks[0].In.Vector.Data(), masks[1].In.Vector.Data()) ||
+		!reflect.DeepEqual(masks[0].State.Vector.Data(), masks[1].State.Vector.Data()) {
+		t.Error("masks should be equal for equal seeds")
+	}
+}
+
+func TestRecurrentDropoutMasks(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	block := NewVanillaZero(c, 3, 3, anynet.Net{})
+	block.InputWeights.Vector.SetData([]float64{
+		1, 0, 0,
+		0, 1, 0,
+		0, 0, 1,
+	})
+	block.Dropout = RecurrentDropout{Enabled: true, InKeepProb: 0.5}
+
+	var inSeqs [][]anyvec.Vector
+	for _, length := range []int{3, 2, 4} {
+		var seq []anyvec.Vector
+		for i := 0; i < length; i++ {
+			seq = append(seq, c.MakeVectorData([]float64{1, 1, 1}))
+		}
+		inSeqs = append(inSeqs, seq)
+	}
+
+	outSeqs := anyseq.SeparateSeqs(Map(anyseq.ConstSeqList(c, inSeqs), block).Output())
+	for seqIdx, seq := range outSeqs {
+		first := seq[0].Data().([]float64)
+		for _, x := range first {
+			if x != 0 && x != 1 {
+				t.Fatalf("sequence %d: invalid mask %v", seqIdx, first)
+			}
+		}
+		for step, vec := range seq[1:] {
+			data := vec.Data().([]float64)
+			for i, x := range data {
+				if x != first[i] {
+					t.Errorf("sequence %d, step %d: expected %v but got %v",
+						seqIdx, step+1, first, data)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestRecurrentDropoutProp(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	inSeq, inVars := randomTestSequence(c, 3)
+	dropout := RecurrentDropout{InKeepProb: 0.7, StateKeepProb: 0.6}
+
+	lstm := NewLSTM(c, 3, 2)
+	lstm.Dropout = dropout
+	lstm.Zoneout = Zoneout{CellProb: 0.3, HiddenProb: 0.2}
+
+	vanilla := NewVanilla(c, 3, 2, anynet.Tanh)
+	vanilla.Dropout = dropout
+
+	for _, block := range []Block{lstm, vanilla} {
+		block := block
+		checker := &anydifftest.SeqChecker{
+			F: func() anyseq.Seq {
+				return Map(inSeq, block)
+			},
+			V: append(append([]*anydiff.Var{}, inVars...),
+				block.(anynet.Parameterizer).Parameters()...),
+		}
+		checker.FullCheck(t)
+	}
+}
@@ -1,6 +1,8 @@
 package anyrnn
 
 import (
+	"math/rand"
+
 	"github.com/unixpickle/anydiff"
 	"github.com/unixpickle/anynet"
 	"github.com/unixpickle/anyvec"
@@ -89,6 +91,12 @@ func (f *Feedback) Parameters() []*anydiff.Var {
 	return append(anynet.AllParameters(f.Mixer, f.Block), f.InitOut)
 }
 
+// SetRand sets the source of randomness for the Mixer and
+// the Block if they implement anynet.RandSetter.
+func (f *Feedback) SetRand(r *rand.Rand) {
+	anynet.SetAllRand(r, f.Mixer, f.Block)
+}
+
 // SerializerType returns the unique ID used to serialize
 // a Feedback block with the serializer package.
 func (f *Feedback) SerializerType() string {
@@ -0,0 +1,138 @@
+package anyrnn
+
+import (
+	"math/rand"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+)
+
+// highwayGateBias is the initial bias for the gates of a
+// Highway created with NewHighway.
+//
+// A negative bias makes the block favor its input at the
+// start of training.
+const highwayGateBias = -1
+
+func init() {
+	var h Highway
+	serializer.RegisterTypedDeserializer(h.SerializerType(), DeserializeHighway)
+}
+
+// Highway is a Block which uses a learned gate to mix the
+// output of a Block with the Block's input.
+//
+// At each timestep, the output is computed as
+//
+//     t*block(x) + (1-t)*x
+//
+// where t is the output of Gate for the input x.
+// For details, see https://arxiv.org/abs/1505.00387.
+type Highway struct {
+	// Block is the transformation applied to the input.
+	Block Block
+
+	// Gate computes the transform gate from the input.
+	// Its outputs should be in the range [0, 1].
+	Gate anynet.Layer
+
+	// If non-nil, Projection is applied to the input before
+	// it is mixed with the output of Block.
+	//
+	// This can be used when Block changes the size of its
+	// input vectors.
+	Projection anynet.Layer
+}
+
+// NewHighway creates a Highway around a Block.
+//
+// The gate is a sigmoid layer with a negative initial
+// bias.
+// If inSize and outSize differ, a fully-connected
+// Projection is created as well.
+func NewHighway(c anyvec.Creator, inSize, outSize int, block Block) *Highway {
+	gateFC := anynet.NewFC(c, inSize, outSize)
+	gateFC.Biases.Vector.AddScalar(c.MakeNumeric(highwayGateBias))
+	res := &Highway{
+		Block: block,
+		Gate:  anynet.Net{gateFC, anynet.Sigmoid},
+	}
+	if inSize != outSize {
+		res.Projection = anynet.NewFC(c, inSize, outSize)
+	}
+	return res
+}
+
+// DeserializeHighway deserializes a Highway.
+func DeserializeHighway(d []byte) (*Highway, error) {
+	var res Highway
+	var proj anynet.Net
+	err := serializer.DeserializeAny(d, &res.Block, &res.Gate, &proj)
+	if err != nil {
+		return nil, essentials.AddCtx("deserialize Highway", err)
+	}
+	if len(proj) == 1 {
+		res.Projection = proj[0]
+	}
+	return &res, nil
+}
+
+// Start returns the start state of the Block.
+func (h *Highway) Start(n int) State {
+	return h.Block.Start(n)
+}
+
+// PropagateStart propagates through the start state of
+// the Block.
+func (h *Highway) PropagateStart(s StateGrad, g anydiff.Grad) {
+	h.Block.PropagateStart(s, g)
+}
+
+// Step applies the Block and mixes its output with the
+// (possibly projected) input.
+func (h *Highway) Step(s State, in anyvec.Vector) Res {
+	return newShortcutRes(h.Block, s, in, func(in, out anydiff.Res, n int) anydiff.Res {
+		carry := in
+		if h.Projection != nil {
+			carry = h.Projection.Apply(in, n)
+		}
+		return anydiff.Pool(h.Gate.Apply(in, n), func(gate anydiff.Res) anydiff.Res {
+			return anydiff.Add(
+				anydiff.Mul(gate, out),
+				anydiff.Mul(anydiff.Complement(gate), carry),
+			)
+		})
+	})
+}
+
+// Parameters returns the parameters of the Block, the
+// Gate, and the Projection, if they implement
+// anynet.Parameterizer.
+func (h *Highway) Parameters() []*anydiff.Var {
+	return anynet.AllParameters(h.Block, h.Gate, h.Projection)
+}
+
+// SetRand sets the source of randomness for the Block,
+// the Gate, and the Projection if they implement
+// anynet.RandSetter.
+func (h *Highway) SetRand(r *rand.Rand) {
+	anynet.SetAllRand(r, h.Block, h.Gate, h.Projection)
+}
+
+// SerializerType returns the unique ID used to serialize
+// a Highway with the serializer package.
+func (h *Highway) SerializerType() string {
+	return "github.com/unixpickle/anynet/anyrnn.Highway"
+}
+
+// Serialize serializes the Highway.
+func (h *Highway) Serialize() ([]byte, error) {
+	var projLayer anynet.Net
+	if h.Projection != nil {
+		projLayer = anynet.Net{h.Projection}
+	}
+	return serializer.SerializeAny(h.Block, h.Gate, projLayer)
+}
@@ -2,6 +2,7 @@ package anyrnn
 
 import (
 	"errors"
+	"math/rand"
 
 	"github.com/unixpickle/anydiff"
 	"github.com/unixpickle/anynet"
@@ -68,6 +69,12 @@ func (l *LayerBlock) Parameters() []*anydiff.Var {
 	return anynet.AllParameters(l.Layer)
 }
 
+// SetRand sets the source of randomness for the layer if
+// it is an anynet.RandSetter.
+func (l *LayerBlock) SetRand(r *rand.Rand) {
+	anynet.SetAllRand(r, l.Layer)
+}
+
 // SerializerType returns the unique ID used to serialize
 // a LayerBlock with the serializer package.
 func (l *LayerBlock) SerializerType() string {
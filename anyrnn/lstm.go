@@ -1,6 +1,8 @@
 package anyrnn
 
 import (
+	"math/rand"
+
 	"github.com/unixpickle/anydiff"
 	"github.com/unixpickle/anynet"
 	"github.com/unixpickle/anyvec"
@@ -27,6 +29,14 @@ type LSTM struct {
 	OutSquash    anynet.Layer
 	InitLastOut  *anydiff.Var
 	InitInternal *anydiff.Var
+
+	// Dropout applies variational dropout to the inputs
+	// and to the previous outputs fed into the gates.
+	Dropout RecurrentDropout
+
+	// Zoneout applies zoneout to the internal state and
+	// the output.
+	Zoneout Zoneout
 }
 
 // DeserializeLSTM deserializes an LSTM.
@@ -34,10 +44,18 @@ func DeserializeLSTM(d []byte) (*LSTM, error) {
 	var inVal, in, rem, out *LSTMGate
 	var outSquash anynet.Layer
 	var initLast, initInt *anyvecsave.S
+	var dropout *RecurrentDropout
+	var zoneout *Zoneout
 	err := serializer.DeserializeAny(d, &inVal, &in, &rem, &out, &outSquash,
-		&initLast, &initInt)
+		&initLast, &initInt, &dropout, &zoneout)
 	if err != nil {
-		return nil, essentials.AddCtx("deserialize LSTM", err)
+		// Legacy format did not store dropout or zoneout.
+		dropout, zoneout = &RecurrentDropout{}, &Zoneout{}
+		err = serializer.DeserializeAny(d, &inVal, &in, &rem, &out, &outSquash,
+			&initLast, &initInt)
+		if err != nil {
+			return nil, essentials.AddCtx("deserialize LSTM", err)
+		}
 	}
 	return &LSTM{
 		InValue:      inVal,
@@ -47,6 +65,8 @@ func DeserializeLSTM(d []byte) (*LSTM, error) {
 		OutSquash:    outSquash,
 		InitLastOut:  anydiff.NewVar(initLast.Vector),
 		InitInternal: anydiff.NewVar(initInt.Vector),
+		Dropout:      *dropout,
+		Zoneout:      *zoneout,
 	}, nil
 }
 
@@ -55,11 +75,18 @@ func DeserializeLSTM(d []byte) (*LSTM, error) {
 // The remember gates of the LSTM are initially biased to
 // remember things.
 func NewLSTM(c anyvec.Creator, in, state int) *LSTM {
+	return NewLSTMRand(c, in, state, nil)
+}
+
+// NewLSTMRand is like NewLSTM, but it uses the given
+// source of randomness.
+// If r is nil, the global source from math/rand is used.
+func NewLSTMRand(c anyvec.Creator, in, state int, r *rand.Rand) *LSTM {
 	res := &LSTM{
-		InValue:      NewLSTMGate(c, in, state, anynet.Tanh),
-		In:           NewLSTMGate(c, in, state, anynet.Sigmoid),
-		Remember:     NewLSTMGate(c, in, state, anynet.Sigmoid),
-		Output:       NewLSTMGate(c, in, state, anynet.Sigmoid),
+		InValue:      NewLSTMGateRand(c, in, state, anynet.Tanh, r),
+		In:           NewLSTMGateRand(c, in, state, anynet.Sigmoid, r),
+		Remember:     NewLSTMGateRand(c, in, state, anynet.Sigmoid, r),
+		Output:       NewLSTMGateRand(c, in, state, anynet.Sigmoid, r),
 		OutSquash:    anynet.Tanh,
 		InitLastOut:  anydiff.NewVar(c.MakeVector(state)),
 		InitInternal: anydiff.NewVar(c.MakeVector(state)),
@@ -99,14 +126,24 @@ func (l *LSTM) ScaleInWeights(scaler anyvec.Numeric) *LSTM {
 }
 
 // Start returns the start state for the RNN.
+//
+// If l.Dropout masks anything, the result is a
+// *DropoutState wrapping an *LSTMState.
+// Otherwise, it is an *LSTMState.
 func (l *LSTM) Start(n int) State {
-	return &LSTMState{
+	res := &LSTMState{
 		LastOut:  NewVecState(l.InitLastOut.Output(), n),
 		Internal: NewVecState(l.InitInternal.Output(), n),
 	}
+	stateSize := l.InitLastOut.Vector.Len()
+	inSize := l.In.InputWeights.Vector.Len() / stateSize
+	masks := l.Dropout.Masks(l.InitLastOut.Vector.Creator(), n, inSize, stateSize)
+	return wrapDropoutState(res, masks)
 }
 
 // PropagateStart propagates through the start state.
+//
+// The StateGrad is always an *LSTMState.
 func (l *LSTM) PropagateStart(s StateGrad, g anydiff.Grad) {
 	ls := s.(*LSTMState)
 	ls.LastOut.PropagateStart(l.InitLastOut, g)
@@ -115,30 +152,35 @@ func (l *LSTM) PropagateStart(s StateGrad, g anydiff.Grad) {
 
 // Step performs one timestep.
 func (l *LSTM) Step(s State, in anyvec.Vector) Res {
-	ls := s.(*LSTMState)
+	st, masks := unwrapDropoutState(s)
+	ls := st.(*LSTMState)
 
 	res := &lstmRes{
 		V:                anydiff.NewVarSet(l.Parameters()...),
+		Masks:            masks,
 		InPool:           anydiff.NewVar(in),
 		LastOutPool:      anydiff.NewVar(ls.LastOut.Vector),
 		LastInternalPool: anydiff.NewVar(ls.Internal.Vector),
 	}
 
-	inVal := l.InValue.Apply(res.LastOutPool, res.InPool, res.LastInternalPool)
-	inGate := l.In.Apply(res.LastOutPool, res.InPool, res.LastInternalPool)
-	remGate := l.Remember.Apply(res.LastOutPool, res.InPool, res.LastInternalPool)
+	gateIn := masks.MaskIn(res.InPool)
+	gateLastOut := masks.MaskState(res.LastOutPool)
 
-	res.InternalRes = anydiff.Add(
+	inVal := l.InValue.Apply(gateLastOut, gateIn, res.LastInternalPool)
+	inGate := l.In.Apply(gateLastOut, gateIn, res.LastInternalPool)
+	remGate := l.Remember.Apply(gateLastOut, gateIn, res.LastInternalPool)
+
+	res.InternalRes = l.Zoneout.cell(res.LastInternalPool, anydiff.Add(
 		anydiff.Mul(inVal, inGate),
 		anydiff.Mul(res.LastInternalPool, remGate),
-	)
+	))
 	res.InternalPool = anydiff.NewVar(res.InternalRes.Output())
 
 	// Peephole of output gate gets to see the new internals.
-	outGate := l.Output.Apply(res.LastOutPool, res.InPool, res.InternalPool)
+	outGate := l.Output.Apply(gateLastOut, gateIn, res.InternalPool)
 	squashedOut := l.OutSquash.Apply(res.InternalPool, s.Present().NumPresent())
 
-	res.OutputRes = anydiff.Mul(outGate, squashedOut)
+	res.OutputRes = l.Zoneout.hidden(res.LastOutPool, anydiff.Mul(outGate, squashedOut))
 	res.OutState = &LSTMState{
 		LastOut: &VecState{
 			Vector:     res.OutputRes.Output(),
@@ -163,6 +205,13 @@ func (l *LSTM) Parameters() []*anydiff.Var {
 	return res
 }
 
+// SetRand sets the source of randomness for dropout and
+// zoneout.
+func (l *LSTM) SetRand(r *rand.Rand) {
+	l.Dropout.Rand = r
+	l.Zoneout.Rand = r
+}
+
 // SerializerType returns the unique ID used to serialize
 // an LSTM with the serializer package.
 func (l *LSTM) SerializerType() string {
@@ -173,7 +222,8 @@ func (l *LSTM) SerializerType() string {
 func (l *LSTM) Serialize() ([]byte, error) {
 	return serializer.SerializeAny(l.InValue, l.In, l.Remember, l.Output, l.OutSquash,
 		&anyvecsave.S{Vector: l.InitLastOut.Vector},
-		&anyvecsave.S{Vector: l.InitInternal.Vector})
+		&anyvecsave.S{Vector: l.InitInternal.Vector},
+		&l.Dropout, &l.Zoneout)
 }
 
 // An LSTMGate computes a value based on the previous
@@ -204,8 +254,15 @@ func DeserializeLSTMGate(d []byte) (*LSTMGate, error) {
 
 // NewLSTMGate creates a randomized LSTM gate.
 func NewLSTMGate(c anyvec.Creator, in, state int, activation anynet.Layer) *LSTMGate {
+	return NewLSTMGateRand(c, in, state, activation, nil)
+}
+
+// NewLSTMGateRand is like NewLSTMGate, but it uses the
+// given source of randomness.
+func NewLSTMGateRand(c anyvec.Creator, in, state int, activation anynet.Layer,
+	r *rand.Rand) *LSTMGate {
 	// Hijack the vanilla randomization code.
-	vn := NewVanilla(c, in, state, activation)
+	vn := NewVanillaRand(c, in, state, activation, r)
 	return &LSTMGate{
 		StateWeights: vn.StateWeights,
 		InputWeights: vn.InputWeights,
@@ -293,6 +350,7 @@ type lstmRes struct {
 	OutState *LSTMState
 	OutVec   anyvec.Vector
 	V        anydiff.VarSet
+	Masks    *DropoutMasks
 
 	InternalRes anydiff.Res
 	OutputRes   anydiff.Res
@@ -304,7 +362,7 @@ type lstmRes struct {
 }
 
 func (l *lstmRes) State() State {
-	return l.OutState
+	return wrapDropoutState(l.OutState, l.Masks)
 }
 
 func (l *lstmRes) Output() anyvec.Vector {
@@ -23,6 +23,31 @@ import (
 // one required attribute, "out", which specifies the
 // block's output size.
 //
+// The Realizer also supports Highway blocks, whose
+// children make up the transformation of a Highway.
+// A Highway has no attributes.
+//
+// Lastly, the Realizer supports Bidir blocks, which
+// contain a Forward block and a Backward block, each of
+// which contains the children of the corresponding
+// direction.
+// By default, the outputs of the two directions are
+// added, in which case they must be the same size.
+// With the attribute "concat=1", the outputs are
+// concatenated instead.
+// With the attribute "out", the outputs are combined by a
+// learned mixer with the given output size.
+// For example:
+//
+//     Bidir(concat=1) {
+//         Forward {
+//             LSTM(out=64)
+//         }
+//         Backward {
+//             LSTM(out=64)
+//         }
+//     }
+//
 // The Realizer is meant to be used in the same chain as a
 // convmarkup.MetaRealizer.
 // For example, you might do:
@@ -35,13 +60,21 @@ import (
 //         }),
 //     }
 //
-// Instantiated objects are either a Block or a type
-// produced by layerChain.
+// Instantiated objects are either a Block, a SeqStack,
+// or a type produced by layerChain.
+// A SeqStack is produced when a file contains a Bidir, in
+// which case the other recurrent blocks are wrapped in
+// MapBlocks.
 //
-// Recurrent blocks may only be present at the top level
-// of a file or within Repeat blocks.
-// It is not valid, for example, to use a Vanilla block
-// inside a Residual block.
+// Recurrent blocks may be present at the top level of a
+// file, within Repeat, Highway, Forward, and Backward
+// blocks, and within the residual part of Residual
+// blocks.
+// A Residual block containing recurrent blocks is
+// realized as a Residual from this package, in which
+// case its projection must be feed-forward.
+// Bidir blocks may only be present at the top level of a
+// file or within Repeat blocks.
 func Realizer(c anyvec.Creator, layerChain convmarkup.RealizerChain) convmarkup.Realizer {
 	return &realizer{
 		creator:    c,
@@ -58,6 +91,11 @@ func MarkupCreators() map[string]convmarkup.Creator {
 	for _, name := range []string{"LSTM", "Vanilla"} {
 		def[name] = markupCreator(name)
 	}
+	def["Highway"] = highwayCreator
+	def["Bidir"] = bidirCreator
+	for _, name := range []string{"Forward", "Backward"} {
+		def[name] = bidirPartCreator(name)
+	}
 	return def
 }
 
@@ -70,9 +108,17 @@ func (r *realizer) Realize(ch convmarkup.RealizerChain, d convmarkup.Dims,
 	b convmarkup.Block) (interface{}, error) {
 	switch b := b.(type) {
 	case *convmarkup.Root:
-		return r.stack(ch, d, b.Children)
+		return r.seqOrStack(ch, d, b.Children)
 	case *convmarkup.Repeat:
 		return r.repeat(ch, d, b)
+	case *convmarkup.Residual:
+		return r.residual(ch, d, b)
+	case *highwayBlock:
+		return r.highway(ch, d, b)
+	case *bidirBlock:
+		return r.bidir(ch, d, b)
+	case *bidirPart:
+		return nil, errors.New(b.Name + " block must be inside a Bidir block")
 	case *markupBlock:
 		return r.block(ch, d, b)
 	default:
@@ -82,7 +128,37 @@ func (r *realizer) Realize(ch convmarkup.RealizerChain, d convmarkup.Dims,
 
 func (r *realizer) stack(ch convmarkup.RealizerChain, d convmarkup.Dims,
 	blocks []convmarkup.Block) (Stack, error) {
+	objs, err := r.objects(ch, d, blocks)
+	if err != nil {
+		return nil, err
+	}
 	var res Stack
+	for _, obj := range objs {
+		if rnn, ok := obj.(Block); ok {
+			res = append(res, rnn)
+		} else {
+			return nil, fmt.Errorf("not an anyrnn.Block: %T", obj)
+		}
+	}
+	return res, nil
+}
+
+// seqOrStack realizes the blocks as a Stack, or as a
+// SeqStack if any of the blocks is not a Block.
+func (r *realizer) seqOrStack(ch convmarkup.RealizerChain, d convmarkup.Dims,
+	blocks []convmarkup.Block) (interface{}, error) {
+	objs, err := r.objects(ch, d, blocks)
+	if err != nil {
+		return nil, err
+	}
+	return groupObjects(objs)
+}
+
+// objects realizes the blocks in order, flattening any
+// nested stacks.
+func (r *realizer) objects(ch convmarkup.RealizerChain, d convmarkup.Dims,
+	blocks []convmarkup.Block) ([]interface{}, error) {
+	var res []interface{}
 	for _, child := range blocks {
 		obj, _, err := ch.Realize(d, child)
 		if err != nil {
@@ -92,11 +168,19 @@ func (r *realizer) stack(ch convmarkup.RealizerChain, d convmarkup.Dims,
 		if obj == nil {
 		} else if stack, ok := obj.(Stack); ok {
 			// Avoid nesting stacks.
-			res = append(res, stack...)
-		} else if rnn, ok := obj.(Block); ok {
-			res = append(res, rnn)
+			for _, x := range stack {
+				res = append(res, x)
+			}
+		} else if stack, ok := obj.(SeqStack); ok {
+			for _, x := range stack {
+				res = append(res, x)
+			}
+		} else if _, ok := obj.(Block); ok {
+			res = append(res, obj)
+		} else if _, ok := obj.(SeqLayer); ok {
+			res = append(res, obj)
 		} else {
-			return nil, fmt.Errorf("not an anyrnn.Block: %T", obj)
+			return nil, fmt.Errorf("not an anyrnn.Block or anyrnn.SeqLayer: %T", obj)
 		}
 	}
 	return res, nil
@@ -104,13 +188,68 @@ func (r *realizer) stack(ch convmarkup.RealizerChain, d convmarkup.Dims,
 
 func (r *realizer) repeat(ch convmarkup.RealizerChain, d convmarkup.Dims,
 	b *convmarkup.Repeat) (interface{}, error) {
-	var res Stack
+	var objs []interface{}
 	for i := 0; i < b.N; i++ {
-		stack, err := r.stack(ch, d, b.Children)
+		repObjs, err := r.objects(ch, d, b.Children)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, repObjs...)
+	}
+	return groupObjects(objs)
+}
+
+func (r *realizer) residual(ch convmarkup.RealizerChain, d convmarkup.Dims,
+	b *convmarkup.Residual) (interface{}, error) {
+	if !hasRecurrentMarkup(b.Residual) {
+		return r.layer(ch, d, b)
+	}
+	stack, err := r.stack(ch, d, b.Residual)
+	if err != nil {
+		return nil, err
+	}
+	res := &Residual{Block: stack}
+	if len(b.Projection) > 0 {
+		res.Projection, err = r.layerNet(d, b.Projection)
 		if err != nil {
 			return nil, err
 		}
-		res = append(res, stack...)
+	}
+	return res, nil
+}
+
+func (r *realizer) highway(ch convmarkup.RealizerChain, d convmarkup.Dims,
+	b *highwayBlock) (interface{}, error) {
+	stack, err := r.stack(ch, d, b.Children)
+	if err != nil {
+		return nil, err
+	}
+	return NewHighway(r.creator, d.Volume(), b.Out.Volume(), stack), nil
+}
+
+func (r *realizer) bidir(ch convmarkup.RealizerChain, d convmarkup.Dims,
+	b *bidirBlock) (interface{}, error) {
+	forward, err := r.stack(ch, d, b.Forward.Children)
+	if err != nil {
+		return nil, err
+	}
+	backward, err := r.stack(ch, d, b.Backward.Children)
+	if err != nil {
+		return nil, err
+	}
+	res := &Bidir{Forward: forward, Backward: backward}
+	forwSize := b.Forward.Out.Volume()
+	backSize := b.Backward.Out.Volume()
+	if b.Concat {
+		res.Mixer = anynet.ConcatMixer{}
+	} else if b.Learned {
+		res.Mixer = &anynet.AddMixer{
+			In1: anynet.NewFC(r.creator, forwSize, b.Out.Volume()),
+			In2: anynet.NewFC(r.creator, backSize, b.Out.Volume()),
+			Out: anynet.Tanh,
+		}
+	} else {
+		res.Mixer = BidirSum.Mixer(r.creator, forwSize)
 	}
 	return res, nil
 }
@@ -145,6 +284,72 @@ func (r *realizer) layer(ch convmarkup.RealizerChain, d convmarkup.Dims,
 	}
 }
 
+func (r *realizer) layerNet(d convmarkup.Dims,
+	blocks []convmarkup.Block) (anynet.Net, error) {
+	var res anynet.Net
+	for _, child := range blocks {
+		obj, _, err := r.layerChain.Realize(d, child)
+		if err != nil {
+			return nil, err
+		}
+		d = child.OutDims()
+		if obj == nil {
+		} else if layer, ok := obj.(anynet.Layer); ok {
+			res = append(res, layer)
+		} else {
+			return nil, fmt.Errorf("not an anynet.Layer: %T", obj)
+		}
+	}
+	return res, nil
+}
+
+// groupObjects produces a Stack if every object is a
+// Block, or a SeqStack otherwise.
+func groupObjects(objs []interface{}) (interface{}, error) {
+	var stack Stack
+	var seqStack SeqStack
+	allBlocks := true
+	for _, obj := range objs {
+		if block, ok := obj.(Block); ok {
+			stack = append(stack, block)
+			continue
+		}
+		allBlocks = false
+		if len(stack) > 0 {
+			seqStack = append(seqStack, &MapBlock{Block: stack})
+			stack = nil
+		}
+		seqStack = append(seqStack, obj.(SeqLayer))
+	}
+	if allBlocks {
+		return stack, nil
+	}
+	if len(stack) > 0 {
+		seqStack = append(seqStack, &MapBlock{Block: stack})
+	}
+	return seqStack, nil
+}
+
+// hasRecurrentMarkup checks if any of the blocks will be
+// realized as something other than a feed-forward layer.
+func hasRecurrentMarkup(blocks []convmarkup.Block) bool {
+	for _, block := range blocks {
+		switch block := block.(type) {
+		case *markupBlock, *highwayBlock, *bidirBlock, *bidirPart:
+			return true
+		case *convmarkup.Repeat:
+			if hasRecurrentMarkup(block.Children) {
+				return true
+			}
+		case *convmarkup.Residual:
+			if hasRecurrentMarkup(block.Residual) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 type markupBlock struct {
 	Out  convmarkup.Dims
 	Name string
@@ -184,3 +389,133 @@ func (m *markupBlock) Type() string {
 func (m *markupBlock) OutDims() convmarkup.Dims {
 	return m.Out
 }
+
+type highwayBlock struct {
+	Out      convmarkup.Dims
+	Children []convmarkup.Block
+}
+
+func highwayCreator(in convmarkup.Dims, attr map[string]float64,
+	children []convmarkup.Block) (convmarkup.Block, error) {
+	for name := range attr {
+		return nil, errors.New("unexpected attribute: " + name)
+	}
+	if len(children) == 0 {
+		return nil, errors.New("missing children for Highway")
+	}
+	return &highwayBlock{
+		Out:      children[len(children)-1].OutDims(),
+		Children: children,
+	}, nil
+}
+
+func (h *highwayBlock) Type() string {
+	return "Highway"
+}
+
+func (h *highwayBlock) OutDims() convmarkup.Dims {
+	return h.Out
+}
+
+type bidirBlock struct {
+	Out      convmarkup.Dims
+	Forward  *bidirPart
+	Backward *bidirPart
+	Concat   bool
+	Learned  bool
+}
+
+func bidirCreator(in convmarkup.Dims, attr map[string]float64,
+	children []convmarkup.Block) (convmarkup.Block, error) {
+	res := &bidirBlock{}
+	for _, child := range children {
+		part, ok := child.(*bidirPart)
+		if !ok {
+			return nil, errors.New("Bidir children must be Forward or Backward blocks")
+		}
+		if part.Name == "Forward" && res.Forward == nil {
+			res.Forward = part
+		} else if part.Name == "Backward" && res.Backward == nil {
+			res.Backward = part
+		} else {
+			return nil, errors.New("duplicate " + part.Name + " block in Bidir")
+		}
+	}
+	if res.Forward == nil || res.Backward == nil {
+		return nil, errors.New("Bidir requires Forward and Backward blocks")
+	}
+	forwSize := res.Forward.Out.Volume()
+	backSize := res.Backward.Out.Volume()
+	for name, val := range attr {
+		switch name {
+		case "concat":
+			res.Concat = val != 0
+		case "out":
+			if float64(int(val)) != val || val <= 0 {
+				return nil, errors.New("invalid value for out attribute")
+			}
+			res.Learned = true
+			res.Out = convmarkup.Dims{Width: 1, Height: 1, Depth: int(val)}
+		default:
+			return nil, errors.New("unexpected attribute: " + name)
+		}
+	}
+	if res.Concat && res.Learned {
+		return nil, errors.New("Bidir cannot have both concat and out attributes")
+	} else if res.Concat {
+		res.Out = convmarkup.Dims{Width: 1, Height: 1, Depth: forwSize + backSize}
+	} else if !res.Learned {
+		if forwSize != backSize {
+			return nil, errors.New("Bidir output size mismatch (use concat or out)")
+		}
+		res.Out = convmarkup.Dims{Width: 1, Height: 1, Depth: forwSize}
+	}
+	return res, nil
+}
+
+func (b *bidirBlock) Type() string {
+	return "Bidir"
+}
+
+func (b *bidirBlock) OutDims() convmarkup.Dims {
+	return b.Out
+}
+
+// bidirPart is a Forward or Backward block.
+//
+// Its output dimensions match its input dimensions, so
+// that the Forward and Backward blocks of a Bidir receive
+// the same input.
+// The real output dimensions are stored in Out.
+type bidirPart struct {
+	Name     string
+	In       convmarkup.Dims
+	Out      convmarkup.Dims
+	Children []convmarkup.Block
+}
+
+func bidirPartCreator(name string) convmarkup.Creator {
+	return func(in convmarkup.Dims, attr map[string]float64,
+		children []convmarkup.Block) (convmarkup.Block, error) {
+		for attrName := range attr {
+			return nil, errors.New("unexpected attribute: " + attrName)
+		}
+		if len(children) == 0 {
+			return nil, errors.New("missing children for " + name)
+		}
+		return &bidirPart{
+			Name:     name,
+			In:       in,
+			Out:      children[len(children)-1].OutDims(),
+			Children: children,
+		}, nil
+	}
+}
+
+func (b *bidirPart) Type() string {
+	return b.Name
+}
+
+func (b *bidirPart) OutDims() convmarkup.Dims {
+	return b.In
+}
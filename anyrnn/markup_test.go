@@ -0,0 +1,297 @@
+package anyrnn
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyconv"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/convmarkup"
+)
+
+func TestRealizerApply(t *testing.T) {
+	tests := []struct {
+		Name    string
+		Code    string
+		OutSize int
+	}{
+		{
+			Name: "Residual",
+			Code: `Residual {
+				Projection {
+					FC(out=2)
+				}
+				LSTM(out=2)
+			}`,
+			OutSize: 2,
+		},
+		{
+			Name: "FeedForwardResidual",
+			Code: `Residual {
+				FC(out=3)
+				Tanh
+			}`,
+			OutSize: 3,
+		},
+		{
+			Name: "Highway",
+			Code: `Highway {
+				Vanilla(out=3)
+			}`,
+			OutSize: 3,
+		},
+		{
+			Name: "Bidir",
+			Code: `Bidir(concat=1) {
+				Forward {
+					LSTM(out=2)
+				}
+				Backward {
+					Vanilla(out=3)
+					FC(out=4)
+				}
+			}`,
+			OutSize: 6,
+		},
+		{
+			Name: "Repeat",
+			Code: `Repeat(n=2) {
+				Residual {
+					Vanilla(out=3)
+				}
+				Highway {
+					LSTM(out=3)
+				}
+			}`,
+			OutSize: 3,
+		},
+		{
+			Name: "RepeatBidir",
+			Code: `Repeat(n=2) {
+				Bidir {
+					Forward {
+						LSTM(out=2)
+					}
+					Backward {
+						LSTM(out=2)
+					}
+				}
+				FC(out=3)
+			}`,
+			OutSize: 3,
+		},
+	}
+	c := anyvec64.CurrentCreator()
+	inSeq, _ := randomTestSequence(c, 3)
+	for _, test := range tests {
+		obj, err := realizeTestMarkup(test.Code)
+		if err != nil {
+			t.Errorf("%s: %s", test.Name, err)
+			continue
+		}
+		var out anyseq.Seq
+		switch obj := obj.(type) {
+		case Block:
+			out = Map(inSeq, obj)
+		case SeqLayer:
+			out = obj.Apply(inSeq)
+		default:
+			t.Errorf("%s: unexpected type %T", test.Name, obj)
+			continue
+		}
+		for _, batch := range out.Output() {
+			if batch.Packed.Len() != batch.NumPresent()*test.OutSize {
+				t.Errorf("%s: expected output size %d but got %d", test.Name,
+					test.OutSize, batch.Packed.Len()/batch.NumPresent())
+				break
+			}
+		}
+	}
+}
+
+func TestRealizerResidual(t *testing.T) {
+	obj, err := realizeTestMarkup("Residual {\nProjection {\nFC(out=2)\n}\nLSTM(out=2)\n}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stack := obj.(Stack)
+	res, ok := stack[0].(*Residual)
+	if len(stack) != 1 || !ok {
+		t.Fatalf("expected one *Residual but got %v", stack)
+	}
+	if _, ok := res.Block.(Stack)[0].(*LSTM); !ok {
+		t.Errorf("unexpected residual block: %v", res.Block)
+	}
+	if _, ok := res.Projection.(anynet.Net)[0].(*anynet.FC); !ok {
+		t.Errorf("unexpected projection: %v", res.Projection)
+	}
+
+	obj, err = realizeTestMarkup("Residual {\nFC(out=3)\n}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer, ok := obj.(Stack)[0].(*LayerBlock)
+	if !ok {
+		t.Fatalf("expected *LayerBlock but got %T", obj.(Stack)[0])
+	}
+	if _, ok := layer.Layer.(*anyconv.Residual); !ok {
+		t.Errorf("expected *anyconv.Residual but got %T", layer.Layer)
+	}
+
+	_, err = realizeTestMarkup("Residual {\nProjection {\nLSTM(out=2)\n}\nLSTM(out=2)\n}")
+	if err == nil {
+		t.Error("expected error for recurrent projection")
+	}
+	if _, err := realizeTestMarkup("Forward {\nLSTM(out=2)\n}"); err == nil {
+		t.Error("expected error for Forward outside of Bidir")
+	}
+}
+
+func TestRealizerBidir(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	inSeq, _ := randomTestSequence(c, 3)
+
+	tests := []struct {
+		Code     string
+		OutSize  int
+		Forward  []string
+		Backward []string
+		Mixer    string
+	}{
+		{
+			Code: `Bidir(concat=1) {
+				Forward {
+					LSTM(out=2)
+				}
+				Backward {
+					Vanilla(out=3)
+				}
+			}`,
+			OutSize:  5,
+			Forward:  []string{"*anyrnn.LSTM"},
+			Backward: []string{"*anyrnn.Vanilla"},
+			Mixer:    "anynet.ConcatMixer",
+		},
+		{
+			Code: `Bidir {
+				Forward {
+					Highway {
+						LSTM(out=3)
+					}
+					Residual {
+						Vanilla(out=3)
+					}
+				}
+				Backward {
+					Repeat(n=2) {
+						LSTM(out=3)
+					}
+				}
+			}`,
+			OutSize:  3,
+			Forward:  []string{"*anyrnn.Highway", "*anyrnn.Residual"},
+			Backward: []string{"*anyrnn.LSTM", "*anyrnn.LSTM"},
+			Mixer:    "*anynet.AddMixer",
+		},
+		{
+			Code: `Bidir(out=4) {
+				Forward {
+					Vanilla(out=2)
+				}
+				Backward {
+					LSTM(out=3)
+					FC(out=5)
+				}
+			}`,
+			OutSize:  4,
+			Forward:  []string{"*anyrnn.Vanilla"},
+			Backward: []string{"*anyrnn.LSTM", "*anyrnn.LayerBlock"},
+			Mixer:    "*anynet.AddMixer",
+		},
+	}
+	for i, test := range tests {
+		obj, err := realizeTestMarkup(test.Code)
+		if err != nil {
+			t.Errorf("test %d: %s", i, err)
+			continue
+		}
+		stack, ok := obj.(SeqStack)
+		if !ok || len(stack) != 1 {
+			t.Errorf("test %d: expected SeqStack with one layer but got %T", i, obj)
+			continue
+		}
+		bidir, ok := stack[0].(*Bidir)
+		if !ok {
+			t.Errorf("test %d: expected *Bidir but got %T", i, stack[0])
+			continue
+		}
+		checkStackTypes(t, fmt.Sprintf("test %d: Forward", i), bidir.Forward, test.Forward)
+		checkStackTypes(t, fmt.Sprintf("test %d: Backward", i), bidir.Backward, test.Backward)
+		if mixer := fmt.Sprintf("%T", bidir.Mixer); mixer != test.Mixer {
+			t.Errorf("test %d: expected mixer %s but got %s", i, test.Mixer, mixer)
+		}
+		for _, batch := range stack.Apply(inSeq).Output() {
+			if batch.Packed.Len() != batch.NumPresent()*test.OutSize {
+				t.Errorf("test %d: expected output size %d but got %d", i,
+					test.OutSize, batch.Packed.Len()/batch.NumPresent())
+				break
+			}
+		}
+	}
+
+	badCodes := []string{
+		"Bidir {\nForward {\nLSTM(out=2)\n}\nBackward {\nLSTM(out=3)\n}\n}",
+		"Bidir(concat=1, out=2) {\nForward {\nLSTM(out=2)\n}\nBackward {\nLSTM(out=2)\n}\n}",
+		"Bidir {\nForward {\nLSTM(out=2)\n}\n}",
+		"Bidir {\nForward {\nLSTM(out=2)\n}\nForward {\nLSTM(out=2)\n}\n}",
+		"Bidir {\nLSTM(out=2)\n}",
+	}
+	for i, code := range badCodes {
+		if _, err := realizeTestMarkup(code); err == nil {
+			t.Errorf("bad markup %d: expected an error", i)
+		}
+	}
+}
+
+// checkStackTypes checks the types of the blocks in one
+// half of a Bidir.
+func checkStackTypes(t *testing.T, prefix string, block Block, expected []string) {
+	stack, ok := block.(Stack)
+	if !ok {
+		t.Errorf("%s: expected Stack but got %T", prefix, block)
+		return
+	}
+	var actual []string
+	for _, b := range stack {
+		actual = append(actual, fmt.Sprintf("%T", b))
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%s: expected %v but got %v", prefix, expected, actual)
+	}
+}
+
+// realizeTestMarkup realizes a markup file which takes a
+// sequence of 3-dimensional vectors.
+func realizeTestMarkup(code string) (interface{}, error) {
+	c := anyvec64.CurrentCreator()
+	parsed, err := convmarkup.Parse("Input(w=1, h=1, d=3)\n" + code)
+	if err != nil {
+		return nil, err
+	}
+	block, err := parsed.Block(convmarkup.Dims{}, MarkupCreators())
+	if err != nil {
+		return nil, err
+	}
+	chain := convmarkup.RealizerChain{
+		convmarkup.MetaRealizer{},
+		Realizer(c, convmarkup.RealizerChain{
+			convmarkup.MetaRealizer{},
+			anyconv.Realizer(c),
+		}),
+	}
+	obj, _, err := chain.Realize(convmarkup.Dims{}, block)
+	return obj, err
+}
@@ -0,0 +1,253 @@
+package anyrnn
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anynet/internal/numeric"
+	"github.com/unixpickle/anyvec"
+)
+
+// ToPadded converts a batch of sequences into a padded
+// tensor and a mask.
+//
+// The padded tensor is of shape [batch, time, features]
+// in row-major order, where batch is the number of
+// sequences and time is the number of timesteps in s.
+// Entries for absent timesteps are 0.
+//
+// The mask is of shape [batch, time], and it contains a 1
+// for every present timestep and a 0 elsewhere.
+//
+// The result is differentiable with respect to s.
+func ToPadded(s anyseq.Seq) (padded anydiff.Res, mask anyvec.Vector) {
+	c := s.Creator()
+	batches := s.Output()
+	if len(batches) == 0 {
+		return anydiff.NewConst(c.MakeVector(0)), c.MakeVector(0)
+	}
+	present := make([][]bool, len(batches))
+	for t, b := range batches {
+		present[t] = b.Present
+	}
+	batch := len(present[0])
+	mapper, features := paddingMapper(c, present, batches)
+	mask = paddingMask(c, present)
+	if mapper == nil {
+		outVec := c.MakeVector(batch * len(batches) * features)
+		return &padRes{In: s, Present: present, OutVec: outVec}, mask
+	}
+	outVec := c.MakeVector(mapper.InSize())
+	mapper.MapTranspose(concatPacked(c, batches), outVec)
+	return &padRes{
+		In:       s,
+		Present:  present,
+		Features: features,
+		Mapper:   mapper,
+		OutVec:   outVec,
+	}, mask
+}
+
+// FromPadded converts a padded tensor and a mask back
+// into a batch of sequences.
+// It is the inverse of ToPadded.
+//
+// The batch argument specifies the number of sequences.
+// The number of timesteps and the number of features are
+// inferred from the sizes of mask and padded.
+// Entries of padded corresponding to zeros in the mask
+// are ignored.
+// Since a sequence cannot resume after it ends, each row
+// of the mask must be a run of ones followed by zeros;
+// otherwise, FromPadded panics.
+// Trailing timesteps with no present sequences are
+// removed.
+//
+// The result is differentiable with respect to padded.
+func FromPadded(padded anydiff.Res, mask anyvec.Vector, batch int) anyseq.Seq {
+	c := padded.Output().Creator()
+	if batch == 0 || mask.Len() == 0 {
+		return anyseq.ConstSeq(c, nil)
+	}
+	if mask.Len()%batch != 0 || padded.Output().Len()%mask.Len() != 0 {
+		panic("padded tensor size does not match mask")
+	}
+	timesteps := mask.Len() / batch
+	features := padded.Output().Len() / mask.Len()
+
+	maskVals := numeric.Float64s(mask.Data())
+	present := make([][]bool, timesteps)
+	numSteps := 0
+	for t := range present {
+		present[t] = make([]bool, batch)
+		for b := range present[t] {
+			if maskVals[b*timesteps+t] != 0 {
+				if t > 0 && !present[t-1][b] {
+					panic("mask rows must be contiguous prefixes")
+				}
+				present[t][b] = true
+				numSteps = t + 1
+			}
+		}
+	}
+	present = present[:numSteps]
+
+	res := &unpadSeq{In: padded, Present: present}
+	if numSteps == 0 {
+		return res
+	}
+	res.Mapper = paddingTableMapper(c, present, features, timesteps)
+	packed := c.MakeVector(res.Mapper.OutSize())
+	res.Mapper.Map(padded.Output(), packed)
+	res.Out = splitPacked(packed, present, features)
+	return res
+}
+
+type padRes struct {
+	In       anyseq.Seq
+	Present  [][]bool
+	Features int
+	Mapper   anyvec.Mapper
+	OutVec   anyvec.Vector
+}
+
+func (p *padRes) Output() anyvec.Vector {
+	return p.OutVec
+}
+
+func (p *padRes) Vars() anydiff.VarSet {
+	return p.In.Vars()
+}
+
+func (p *padRes) Propagate(u anyvec.Vector, g anydiff.Grad) {
+	if p.Mapper == nil {
+		return
+	}
+	packed := u.Creator().MakeVector(p.Mapper.OutSize())
+	p.Mapper.Map(u, packed)
+	p.In.Propagate(splitPacked(packed, p.Present, p.Features), g)
+}
+
+type unpadSeq struct {
+	In      anydiff.Res
+	Present [][]bool
+	Mapper  anyvec.Mapper
+	Out     []*anyseq.Batch
+}
+
+func (u *unpadSeq) Creator() anyvec.Creator {
+	return u.In.Output().Creator()
+}
+
+func (u *unpadSeq) Output() []*anyseq.Batch {
+	return u.Out
+}
+
+func (u *unpadSeq) Vars() anydiff.VarSet {
+	return u.In.Vars()
+}
+
+func (u *unpadSeq) Propagate(upstream []*anyseq.Batch, g anydiff.Grad) {
+	if u.Mapper == nil {
+		return
+	}
+	c := u.Creator()
+	downstream := c.MakeVector(u.Mapper.InSize())
+	u.Mapper.MapTranspose(concatPacked(c, upstream), downstream)
+	u.In.Propagate(downstream, g)
+}
+
+// paddingMapper creates a mapper from padded tensors to
+// concatenated packed batches.
+//
+// If no timesteps are present, the mapper is nil.
+func paddingMapper(c anyvec.Creator, present [][]bool,
+	batches []*anyseq.Batch) (anyvec.Mapper, int) {
+	numPresent := countPresent(present)
+	var packedSize int
+	for _, b := range batches {
+		packedSize += b.Packed.Len()
+	}
+	if numPresent == 0 {
+		return nil, 0
+	}
+	if packedSize%numPresent != 0 {
+		panic("inconsistent feature count")
+	}
+	features := packedSize / numPresent
+	return paddingTableMapper(c, present, features, len(present)), features
+}
+
+// paddingTableMapper creates a mapper from padded tensors
+// with the given number of timesteps to concatenated
+// packed batches with the given present maps.
+func paddingTableMapper(c anyvec.Creator, present [][]bool, features,
+	timesteps int) anyvec.Mapper {
+	batch := len(present[0])
+	var table []int
+	for t, pres := range present {
+		for b, p := range pres {
+			if !p {
+				continue
+			}
+			offset := (b*timesteps + t) * features
+			for i := 0; i < features; i++ {
+				table = append(table, offset+i)
+			}
+		}
+	}
+	return c.MakeMapper(batch*timesteps*features, table)
+}
+
+func paddingMask(c anyvec.Creator, present [][]bool) anyvec.Vector {
+	timesteps := len(present)
+	batch := len(present[0])
+	mask := make([]float64, batch*timesteps)
+	for t, pres := range present {
+		for b, p := range pres {
+			if p {
+				mask[b*timesteps+t] = 1
+			}
+		}
+	}
+	return c.MakeVectorData(c.MakeNumericList(mask))
+}
+
+func concatPacked(c anyvec.Creator, batches []*anyseq.Batch) anyvec.Vector {
+	var vecs []anyvec.Vector
+	for _, b := range batches {
+		vecs = append(vecs, b.Packed)
+	}
+	return c.Concat(vecs...)
+}
+
+func splitPacked(packed anyvec.Vector, present [][]bool,
+	features int) []*anyseq.Batch {
+	var res []*anyseq.Batch
+	var offset int
+	for _, pres := range present {
+		var n int
+		for _, p := range pres {
+			if p {
+				n++
+			}
+		}
+		res = append(res, &anyseq.Batch{
+			Packed:  packed.Slice(offset, offset+n*features),
+			Present: pres,
+		})
+		offset += n * features
+	}
+	return res
+}
+
+func countPresent(present [][]bool) int {
+	var res int
+	for _, pres := range present {
+		for _, p := range pres {
+			if p {
+				res++
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,98 @@
+package anyrnn
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anydifftest"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestPaddedOutput(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	seqs := [][]anyvec.Vector{
+		{
+			c.MakeVectorData([]float64{1, 2}),
+		},
+		{
+			c.MakeVectorData([]float64{3, 4}),
+			c.MakeVectorData([]float64{5, 6}),
+			c.MakeVectorData([]float64{7, 8}),
+		},
+	}
+	inSeq := anyseq.ConstSeqList(c, seqs)
+	padded, mask := ToPadded(inSeq)
+
+	expected := []float64{1, 2, 0, 0, 0, 0, 3, 4, 5, 6, 7, 8}
+	if actual := padded.Output().Data().([]float64); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected padded %v but got %v", expected, actual)
+	}
+	expected = []float64{1, 0, 0, 1, 1, 1}
+	if actual := mask.Data().([]float64); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected mask %v but got %v", expected, actual)
+	}
+
+	outSeqs := anyseq.SeparateSeqs(FromPadded(padded, mask, 2).Output())
+	if len(outSeqs) != len(seqs) {
+		t.Fatalf("expected %d sequences but got %d", len(seqs), len(outSeqs))
+	}
+	for i, seq := range seqs {
+		if len(outSeqs[i]) != len(seq) {
+			t.Errorf("sequence %d: expected length %d but got %d", i, len(seq),
+				len(outSeqs[i]))
+			continue
+		}
+		for j, vec := range seq {
+			if !reflect.DeepEqual(vec.Data(), outSeqs[i][j].Data()) {
+				t.Errorf("sequence %d, step %d: expected %v but got %v", i, j,
+					vec.Data(), outSeqs[i][j].Data())
+			}
+		}
+	}
+}
+
+func TestToPaddedProp(t *testing.T) {
+	inSeq, inVars := randomTestSequence(anyvec64.CurrentCreator(), 3)
+	checker := &anydifftest.ResChecker{
+		F: func() anydiff.Res {
+			padded, _ := ToPadded(inSeq)
+			return padded
+		},
+		V: inVars,
+	}
+	checker.FullCheck(t)
+}
+
+func TestFromPaddedProp(t *testing.T) {
+	inSeq, inVars := randomTestSequence(anyvec64.CurrentCreator(), 3)
+	checker := &anydifftest.SeqChecker{
+		F: func() anyseq.Seq {
+			padded, mask := ToPadded(inSeq)
+			return FromPadded(anydiff.Tanh(padded), mask, 3)
+		},
+		V: inVars,
+	}
+	checker.FullCheck(t)
+}
+
+func TestFromPaddedGaps(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	padded := anydiff.NewConst(c.MakeVector(2 * 3))
+	masks := map[string][]float64{
+		"resumed":    {1, 0, 1, 1, 1, 1},
+		"late start": {1, 1, 1, 0, 1, 1},
+	}
+	for name, mask := range masks {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", name)
+				}
+			}()
+			FromPadded(padded, c.MakeVectorData(mask), 2)
+		}()
+	}
+}
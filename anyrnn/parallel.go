@@ -1,6 +1,8 @@
 package anyrnn
 
 import (
+	"math/rand"
+
 	"github.com/unixpickle/anydiff"
 	"github.com/unixpickle/anynet"
 	"github.com/unixpickle/anyvec"
@@ -67,6 +69,12 @@ func (p *Parallel) Parameters() []*anydiff.Var {
 	return anynet.AllParameters(p.Block1, p.Block2, p.Mixer)
 }
 
+// SetRand sets the source of randomness for both blocks
+// and the mixer if they implement anynet.RandSetter.
+func (p *Parallel) SetRand(r *rand.Rand) {
+	anynet.SetAllRand(r, p.Block1, p.Block2, p.Mixer)
+}
+
 // SerializerType returns the unique ID used to serialize
 // a Parallel with the serializer package.
 func (p *Parallel) SerializerType() string {
@@ -0,0 +1,151 @@
+package anyrnn
+
+import (
+	"math/rand"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+)
+
+func init() {
+	var r Residual
+	serializer.RegisterTypedDeserializer(r.SerializerType(), DeserializeResidual)
+}
+
+// Residual is a Block which adds the input of a Block to
+// its output at every timestep.
+//
+// This is the recurrent analog of anyconv.Residual.
+type Residual struct {
+	// Block is the residual mapping.
+	Block Block
+
+	// If non-nil, Projection is applied to the input before
+	// it is added to the output of Block.
+	//
+	// This can be used when Block changes the size of its
+	// input vectors.
+	Projection anynet.Layer
+}
+
+// DeserializeResidual deserializes a Residual.
+func DeserializeResidual(d []byte) (*Residual, error) {
+	var block Block
+	var proj anynet.Net
+	if err := serializer.DeserializeAny(d, &block, &proj); err != nil {
+		return nil, essentials.AddCtx("deserialize Residual", err)
+	}
+	res := &Residual{Block: block}
+	if len(proj) == 1 {
+		res.Projection = proj[0]
+	}
+	return res, nil
+}
+
+// Start returns the start state of the Block.
+func (r *Residual) Start(n int) State {
+	return r.Block.Start(n)
+}
+
+// PropagateStart propagates through the start state of
+// the Block.
+func (r *Residual) PropagateStart(s StateGrad, g anydiff.Grad) {
+	r.Block.PropagateStart(s, g)
+}
+
+// Step applies the Block and adds the (possibly
+// projected) input to its output.
+func (r *Residual) Step(s State, in anyvec.Vector) Res {
+	return newShortcutRes(r.Block, s, in, func(in, out anydiff.Res, n int) anydiff.Res {
+		if r.Projection != nil {
+			in = r.Projection.Apply(in, n)
+		}
+		return anydiff.Add(in, out)
+	})
+}
+
+// Parameters returns the parameters of the Block and the
+// Projection, if they implement anynet.Parameterizer.
+func (r *Residual) Parameters() []*anydiff.Var {
+	return anynet.AllParameters(r.Block, r.Projection)
+}
+
+// SetRand sets the source of randomness for the Block and
+// the Projection if they implement anynet.RandSetter.
+func (r *Residual) SetRand(rng *rand.Rand) {
+	anynet.SetAllRand(rng, r.Block, r.Projection)
+}
+
+// SerializerType returns the unique ID used to serialize
+// a Residual with the serializer package.
+func (r *Residual) SerializerType() string {
+	return "github.com/unixpickle/anynet/anyrnn.Residual"
+}
+
+// Serialize serializes the Residual.
+func (r *Residual) Serialize() ([]byte, error) {
+	var projLayer anynet.Net
+	if r.Projection != nil {
+		projLayer = anynet.Net{r.Projection}
+	}
+	return serializer.SerializeAny(r.Block, projLayer)
+}
+
+// shortcutRes is the Res for blocks which combine a
+// Block's output with the Block's input.
+type shortcutRes struct {
+	BlockRes Res
+	InPool   *anydiff.Var
+	OutPool  *anydiff.Var
+	Out      anydiff.Res
+	V        anydiff.VarSet
+}
+
+// newShortcutRes steps through b and uses f to combine
+// the input and output of b.
+func newShortcutRes(b Block, s State, in anyvec.Vector,
+	f func(in, out anydiff.Res, n int) anydiff.Res) *shortcutRes {
+	blockRes := b.Step(s, in)
+	inPool := anydiff.NewVar(in)
+	outPool := anydiff.NewVar(blockRes.Output())
+	out := f(inPool, outPool, s.Present().NumPresent())
+	v := anydiff.MergeVarSets(blockRes.Vars(), out.Vars())
+	v.Del(inPool)
+	v.Del(outPool)
+	return &shortcutRes{
+		BlockRes: blockRes,
+		InPool:   inPool,
+		OutPool:  outPool,
+		Out:      out,
+		V:        v,
+	}
+}
+
+func (s *shortcutRes) State() State {
+	return s.BlockRes.State()
+}
+
+func (s *shortcutRes) Output() anyvec.Vector {
+	return s.Out.Output()
+}
+
+func (s *shortcutRes) Vars() anydiff.VarSet {
+	return s.V
+}
+
+func (s *shortcutRes) Propagate(u anyvec.Vector, sg StateGrad,
+	g anydiff.Grad) (anyvec.Vector, StateGrad) {
+	for _, p := range []*anydiff.Var{s.InPool, s.OutPool} {
+		g[p] = p.Vector.Creator().MakeVector(p.Vector.Len())
+		defer func(p *anydiff.Var) {
+			delete(g, p)
+		}(p)
+	}
+	s.Out.Propagate(u, g)
+	inDown, stateDown := s.BlockRes.Propagate(g[s.OutPool], sg, g)
+	inDown.Add(g[s.InPool])
+	return inDown, stateDown
+}
@@ -0,0 +1,49 @@
+package anyrnn
+
+import (
+	"testing"
+
+	"github.com/unixpickle/anydiff/anydifftest"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestResidualProp(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	inSeq, inVars := randomTestSequence(c, 3)
+	blocks := []*Residual{
+		{Block: NewVanilla(c, 3, 3, anynet.Tanh)},
+		{
+			Block:      NewLSTM(c, 3, 2),
+			Projection: anynet.NewFC(c, 3, 2),
+		},
+	}
+	for _, block := range blocks {
+		checker := &anydifftest.SeqChecker{
+			F: func() anyseq.Seq {
+				return Map(inSeq, block)
+			},
+			V: append(inVars, block.Parameters()...),
+		}
+		checker.FullCheck(t)
+	}
+}
+
+func TestHighwayProp(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	inSeq, inVars := randomTestSequence(c, 3)
+	blocks := []*Highway{
+		NewHighway(c, 3, 3, NewVanilla(c, 3, 3, anynet.Tanh)),
+		NewHighway(c, 3, 2, NewLSTM(c, 3, 2)),
+	}
+	for _, block := range blocks {
+		checker := &anydifftest.SeqChecker{
+			F: func() anyseq.Seq {
+				return Map(inSeq, block)
+			},
+			V: append(inVars, block.Parameters()...),
+		}
+		checker.FullCheck(t)
+	}
+}
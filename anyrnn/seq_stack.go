@@ -0,0 +1,161 @@
+package anyrnn
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+)
+
+func init() {
+	var s SeqStack
+	serializer.RegisterTypedDeserializer(s.SerializerType(), DeserializeSeqStack)
+	var m MapBlock
+	serializer.RegisterTypedDeserializer(m.SerializerType(), DeserializeMapBlock)
+}
+
+// A SeqLayer is a layer which operates on entire
+// sequences at once.
+//
+// Unlike a Block, a SeqLayer may look at future
+// timesteps, as is the case with a Bidir.
+type SeqLayer interface {
+	Apply(in anyseq.Seq) anyseq.Seq
+}
+
+// A SeqStack composes SeqLayers.
+// In a SeqStack, the first layer's output is fed as input
+// to the next layer, etc.
+//
+// A SeqStack can be used to build deep bi-directional
+// RNNs, since it can contain Bidirs.
+type SeqStack []SeqLayer
+
+// NewBidirStack creates a SeqStack of bi-directional
+// LSTMs using NewBidirLSTM.
+//
+// The i-th layer has hiddenSizes[i] hidden units in each
+// direction and mixes its outputs according to mixes[i].
+func NewBidirStack(c anyvec.Creator, inSize int, hiddenSizes []int,
+	mixes []BidirMix) SeqStack {
+	if len(hiddenSizes) != len(mixes) {
+		panic("hidden size count must match mix count")
+	}
+	var res SeqStack
+	for i, hidden := range hiddenSizes {
+		res = append(res, NewBidirLSTM(c, inSize, hidden, mixes[i]))
+		inSize = mixes[i].OutSize(hidden)
+	}
+	return res
+}
+
+// DeserializeSeqStack deserializes a SeqStack.
+func DeserializeSeqStack(d []byte) (SeqStack, error) {
+	layerSlice, err := serializer.DeserializeSlice(d)
+	if err != nil {
+		return nil, essentials.AddCtx("deserialize SeqStack", err)
+	}
+	res := make(SeqStack, len(layerSlice))
+	for i, x := range layerSlice {
+		if l, ok := x.(SeqLayer); ok {
+			res[i] = l
+		} else {
+			return nil, fmt.Errorf("deserialize SeqStack: type is not a SeqLayer: %T", x)
+		}
+	}
+	return res, nil
+}
+
+// Apply applies the layers in order.
+func (s SeqStack) Apply(in anyseq.Seq) anyseq.Seq {
+	for _, l := range s {
+		in = l.Apply(in)
+	}
+	return in
+}
+
+// Parameters returns the parameters of every layer which
+// implements anynet.Parameterizer.
+func (s SeqStack) Parameters() []*anydiff.Var {
+	var res []interface{}
+	for _, l := range s {
+		res = append(res, l)
+	}
+	return anynet.AllParameters(res...)
+}
+
+// SetRand sets the source of randomness for every layer
+// which implements anynet.RandSetter.
+func (s SeqStack) SetRand(r *rand.Rand) {
+	for _, l := range s {
+		anynet.SetAllRand(r, l)
+	}
+}
+
+// SerializerType returns the unique ID used to serialize
+// a SeqStack with the serializer package.
+func (s SeqStack) SerializerType() string {
+	return "github.com/unixpickle/anynet/anyrnn.SeqStack"
+}
+
+// Serialize serializes the SeqStack.
+// It only works if every layer is a Serializer.
+func (s SeqStack) Serialize() ([]byte, error) {
+	var res []serializer.Serializer
+	for _, x := range s {
+		if ser, ok := x.(serializer.Serializer); ok {
+			res = append(res, ser)
+		} else {
+			return nil, fmt.Errorf("not a serializer: %T", x)
+		}
+	}
+	return serializer.SerializeSlice(res)
+}
+
+// MapBlock is a SeqLayer which maps a Block over its
+// input sequences.
+type MapBlock struct {
+	Block Block
+}
+
+// DeserializeMapBlock deserializes a MapBlock.
+func DeserializeMapBlock(d []byte) (*MapBlock, error) {
+	var res MapBlock
+	if err := serializer.DeserializeAny(d, &res.Block); err != nil {
+		return nil, essentials.AddCtx("deserialize MapBlock", err)
+	}
+	return &res, nil
+}
+
+// Apply maps the Block over the sequence.
+func (m *MapBlock) Apply(in anyseq.Seq) anyseq.Seq {
+	return Map(in, m.Block)
+}
+
+// Parameters returns the parameters of the Block if it
+// implements anynet.Parameterizer.
+func (m *MapBlock) Parameters() []*anydiff.Var {
+	return anynet.AllParameters(m.Block)
+}
+
+// SetRand sets the source of randomness for the Block if
+// it implements anynet.RandSetter.
+func (m *MapBlock) SetRand(r *rand.Rand) {
+	anynet.SetAllRand(r, m.Block)
+}
+
+// SerializerType returns the unique ID used to serialize
+// a MapBlock with the serializer package.
+func (m *MapBlock) SerializerType() string {
+	return "github.com/unixpickle/anynet/anyrnn.MapBlock"
+}
+
+// Serialize serializes the MapBlock.
+func (m *MapBlock) Serialize() ([]byte, error) {
+	return serializer.SerializeAny(m.Block)
+}
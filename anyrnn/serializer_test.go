@@ -31,6 +31,9 @@ func TestVanillaSerialize(t *testing.T) {
 	v.Biases.Vector.AddScalar(float32(1))
 
 	testSerialize(t, v)
+
+	v.Dropout = RecurrentDropout{Enabled: true, InKeepProb: 0.8, StateKeepProb: 0.7}
+	testSerialize(t, v)
 }
 
 func TestLSTMGateSerialize(t *testing.T) {
@@ -44,6 +47,11 @@ func TestLSTMGateSerialize(t *testing.T) {
 
 func TestLSTMSerialize(t *testing.T) {
 	testSerialize(t, NewLSTM(anyvec32.CurrentCreator(), 3, 2))
+
+	l := NewLSTM(anyvec32.CurrentCreator(), 3, 2)
+	l.Dropout = RecurrentDropout{Enabled: true, InKeepProb: 0.8, StateKeepProb: 0.7}
+	l.Zoneout = Zoneout{Enabled: true, CellProb: 0.1, HiddenProb: 0.05}
+	testSerialize(t, l)
 }
 
 func TestBidirSerialize(t *testing.T) {
@@ -60,6 +68,14 @@ func TestBidirSerialize(t *testing.T) {
 	testSerialize(t, b)
 }
 
+func TestSeqStackSerialize(t *testing.T) {
+	c := anyvec32.CurrentCreator()
+	stack := NewBidirStack(c, 3, []int{2, 2, 2},
+		[]BidirMix{BidirSum, BidirConcat, BidirLearned})
+	stack = append(stack, &MapBlock{Block: NewVanilla(c, 2, 2, anynet.Tanh)})
+	testSerialize(t, stack)
+}
+
 func TestFeedbackSerialize(t *testing.T) {
 	c := anyvec32.CurrentCreator()
 	vec := c.MakeVector(2)
@@ -85,6 +101,21 @@ func TestParallelSerialize(t *testing.T) {
 	testSerialize(t, b)
 }
 
+func TestResidualSerialize(t *testing.T) {
+	c := anyvec32.CurrentCreator()
+	testSerialize(t, &Residual{Block: NewLSTM(c, 3, 3)})
+	testSerialize(t, &Residual{
+		Block:      NewLSTM(c, 3, 2),
+		Projection: anynet.NewFC(c, 3, 2),
+	})
+}
+
+func TestHighwaySerialize(t *testing.T) {
+	c := anyvec32.CurrentCreator()
+	testSerialize(t, NewHighway(c, 3, 3, NewVanilla(c, 3, 3, anynet.Tanh)))
+	testSerialize(t, NewHighway(c, 3, 2, NewLSTM(c, 3, 2)))
+}
+
 func testSerialize(t *testing.T, obj serializer.Serializer) {
 	data, err := serializer.SerializeWithType(obj)
 	if err != nil {
@@ -2,6 +2,7 @@ package anyrnn
 
 import (
 	"fmt"
+	"math/rand"
 
 	"github.com/unixpickle/anydiff"
 	"github.com/unixpickle/anynet"
@@ -83,6 +84,14 @@ func (s Stack) Parameters() []*anydiff.Var {
 	return res
 }
 
+// SetRand sets the source of randomness for all the
+// sub-blocks that implement anynet.RandSetter.
+func (s Stack) SetRand(r *rand.Rand) {
+	for _, x := range s {
+		anynet.SetAllRand(r, x)
+	}
+}
+
 // SerializerType returns the unique ID used to serialize
 // a Stack with the serializer package.
 func (s Stack) SerializerType() string {
@@ -1,6 +1,7 @@
 package anyrnn
 
 import (
+	"math/rand"
 	"testing"
 
 	"github.com/unixpickle/anydiff"
@@ -57,3 +58,41 @@ func TestStackProp(t *testing.T) {
 	}
 	checker.FullCheck(t)
 }
+
+func TestStackSetRand(t *testing.T) {
+	c := anyvec32.CurrentCreator()
+	var dropouts []*anynet.Dropout
+	dropout := func() *anynet.Dropout {
+		d := &anynet.Dropout{Enabled: true, KeepProb: 0.5}
+		dropouts = append(dropouts, d)
+		return d
+	}
+	layerBlock := func() Block {
+		return &LayerBlock{Layer: &anynet.ParamHider{Layer: dropout()}}
+	}
+	stack := Stack{
+		&Residual{Block: layerBlock(), Projection: dropout()},
+		&Highway{Block: layerBlock(), Gate: dropout()},
+		&Parallel{Block1: layerBlock(), Block2: layerBlock(), Mixer: &anynet.ConcatMixer{}},
+		&Feedback{
+			Mixer:   &anynet.AddMixer{In1: dropout(), In2: dropout(), Out: dropout()},
+			Block:   layerBlock(),
+			InitOut: anydiff.NewVar(c.MakeVector(1)),
+		},
+	}
+	seqStack := SeqStack{
+		&MapBlock{Block: stack},
+		&Bidir{
+			Forward:  layerBlock(),
+			Backward: layerBlock(),
+			Mixer:    &anynet.AddMixer{In1: dropout(), In2: dropout(), Out: dropout()},
+		},
+	}
+	r := rand.New(rand.NewSource(1337))
+	seqStack.SetRand(r)
+	for i, d := range dropouts {
+		if d.Rand != r {
+			t.Errorf("dropout %d: source of randomness was not set", i)
+		}
+	}
+}
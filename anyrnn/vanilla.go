@@ -3,6 +3,7 @@ package anyrnn
 import (
 	"errors"
 	"math"
+	"math/rand"
 
 	"github.com/unixpickle/anydiff"
 	"github.com/unixpickle/anynet"
@@ -36,6 +37,10 @@ type Vanilla struct {
 	Biases       *anydiff.Var
 	StartState   *anydiff.Var
 	Activation   anynet.Layer
+
+	// Dropout applies variational dropout to the inputs
+	// and to the previous states.
+	Dropout RecurrentDropout
 }
 
 // DeserializeVanilla deserializes a Vanilla block.
@@ -45,8 +50,15 @@ func DeserializeVanilla(d []byte) (v *Vanilla, err error) {
 	}()
 	var stW, inW, b, start *anyvecsave.S
 	var a anynet.Layer
-	if err := serializer.DeserializeAny(d, &stW, &inW, &b, &start, &a); err != nil {
-		return nil, err
+	var dropout *RecurrentDropout
+	err = serializer.DeserializeAny(d, &stW, &inW, &b, &start, &a, &dropout)
+	if err != nil {
+		// Legacy format did not store dropout.
+		dropout = &RecurrentDropout{}
+		err = serializer.DeserializeAny(d, &stW, &inW, &b, &start, &a)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	outCount := b.Vector.Len()
@@ -70,15 +82,24 @@ func DeserializeVanilla(d []byte) (v *Vanilla, err error) {
 		Biases:       anydiff.NewVar(b.Vector),
 		StartState:   anydiff.NewVar(start.Vector),
 		Activation:   a,
+		Dropout:      *dropout,
 	}, nil
 }
 
 // NewVanilla creates a new, randomized Vanilla block.
 func NewVanilla(c anyvec.Creator, in, out int, activation anynet.Layer) *Vanilla {
+	return NewVanillaRand(c, in, out, activation, nil)
+}
+
+// NewVanillaRand is like NewVanilla, but it uses the
+// given source of randomness.
+// If r is nil, the global source from math/rand is used.
+func NewVanillaRand(c anyvec.Creator, in, out int, activation anynet.Layer,
+	r *rand.Rand) *Vanilla {
 	res := NewVanillaZero(c, in, out, activation)
 
-	anyvec.Rand(res.StateWeights.Vector, anyvec.Normal, nil)
-	anyvec.Rand(res.InputWeights.Vector, anyvec.Normal, nil)
+	anyvec.Rand(res.StateWeights.Vector, anyvec.Normal, r)
+	anyvec.Rand(res.InputWeights.Vector, anyvec.Normal, r)
 	res.StateWeights.Vector.Scale(c.MakeNumeric(1 / math.Sqrt(float64(out))))
 	res.InputWeights.Vector.Scale(c.MakeNumeric(1 / math.Sqrt(float64(in))))
 
@@ -108,29 +129,38 @@ func (v *Vanilla) ScaleInWeights(scaler anyvec.Numeric) *Vanilla {
 }
 
 // Start generates an initial *VecState.
+//
+// If v.Dropout masks anything, the *VecState is wrapped
+// in a *DropoutState.
 func (v *Vanilla) Start(n int) State {
-	return NewVecState(v.StartState.Vector, n)
+	masks := v.Dropout.Masks(v.StartState.Vector.Creator(), n, v.InCount, v.OutCount)
+	return wrapDropoutState(NewVecState(v.StartState.Vector, n), masks)
 }
 
 // PropagateStart propagates through the start state.
+//
+// The StateGrad is always a *VecState.
 func (v *Vanilla) PropagateStart(s StateGrad, g anydiff.Grad) {
 	s.(*VecState).PropagateStart(v.StartState, g)
 }
 
 // Step performs one timestep.
 func (v *Vanilla) Step(s State, in anyvec.Vector) Res {
+	st, masks := unwrapDropoutState(s)
 	res := &vanillaRes{
 		InPool:    anydiff.NewVar(in),
-		StatePool: anydiff.NewVar(s.(*VecState).Vector),
+		StatePool: anydiff.NewVar(st.(*VecState).Vector),
 		V:         anydiff.NewVarSet(v.Parameters()...),
 	}
 
-	wState := applyWeights(v.OutCount, v.OutCount, v.StateWeights, res.StatePool)
-	wInput := applyWeights(v.InCount, v.OutCount, v.InputWeights, res.InPool)
+	wState := applyWeights(v.OutCount, v.OutCount, v.StateWeights,
+		masks.MaskState(res.StatePool))
+	wInput := applyWeights(v.InCount, v.OutCount, v.InputWeights, masks.MaskIn(res.InPool))
 	sum := anydiff.Add(wState, wInput)
 	biased := anydiff.AddRepeated(sum, v.Biases)
 	res.Out = v.Activation.Apply(biased, s.Present().NumPresent())
-	res.OutState = &VecState{Vector: res.Out.Output(), PresentMap: s.Present()}
+	res.OutState = wrapDropoutState(&VecState{Vector: res.Out.Output(),
+		PresentMap: s.Present()}, masks)
 
 	return res
 }
@@ -145,6 +175,13 @@ func (v *Vanilla) Parameters() []*anydiff.Var {
 	return res
 }
 
+// SetRand sets the source of randomness for dropout, as
+// well as for v.Activation if it is an anynet.RandSetter.
+func (v *Vanilla) SetRand(r *rand.Rand) {
+	v.Dropout.Rand = r
+	anynet.SetAllRand(r, v.Activation)
+}
+
 // SerializerType returns the unique ID used to serialize
 // a Vanilla with the serializer package.
 func (v *Vanilla) SerializerType() string {
@@ -157,7 +194,7 @@ func (v *Vanilla) Serialize() ([]byte, error) {
 	inW := &anyvecsave.S{Vector: v.InputWeights.Vector}
 	b := &anyvecsave.S{Vector: v.Biases.Vector}
 	start := &anyvecsave.S{Vector: v.StartState.Vector}
-	return serializer.SerializeAny(stW, inW, b, start, v.Activation)
+	return serializer.SerializeAny(stW, inW, b, start, v.Activation, &v.Dropout)
 }
 
 type vanillaRes struct {
@@ -0,0 +1,41 @@
+package anyrnnt
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anyvec"
+)
+
+// GreedyLabels decodes the encoder outputs by choosing
+// the most likely symbol at every step.
+//
+// At each frame, labels are emitted until the blank
+// symbol is the most likely symbol.
+// The maxSymbols argument limits the number of labels
+// emitted for a single frame, preventing an untrained
+// model from emitting labels forever.
+// It must be positive.
+func (m *Model) GreedyLabels(encoded anyseq.Seq, maxSymbols int) [][]int {
+	if maxSymbols <= 0 {
+		panic("maxSymbols must be positive")
+	}
+	c := encoded.Creator()
+	res := [][]int{}
+	for _, seq := range anyseq.SeparateSeqs(encoded.Output()) {
+		label := []int{}
+		pred := m.Predictor.Step(m.Predictor.Start(1), m.oneHot(c, m.NumLabels))
+		for _, frame := range seq {
+			for i := 0; i < maxSymbols; i++ {
+				in := anydiff.NewConst(c.Concat(frame, pred.Output()))
+				sym := anyvec.MaxIndex(m.Joint.Apply(in, 1).Output())
+				if sym == m.NumLabels {
+					break
+				}
+				label = append(label, sym)
+				pred = m.Predictor.Step(pred.State(), m.oneHot(c, sym))
+			}
+		}
+		res = append(res, label)
+	}
+	return res
+}
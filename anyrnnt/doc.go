@@ -0,0 +1,16 @@
+// Package anyrnnt implements the RNN Transducer (RNN-T).
+// For more information on RNN-T, see this paper:
+// https://arxiv.org/abs/1211.3711.
+//
+// An RNN-T model has three parts.
+// An encoder network processes the input sequence.
+// A prediction network processes the labels emitted so
+// far, much like a language model.
+// A joint network combines an encoder output with a
+// prediction network output to produce a distribution
+// over the next symbol.
+//
+// Unlike CTC, the probability of each symbol depends on
+// the previous symbols, and several symbols may be
+// emitted for a single input timestep.
+package anyrnnt
@@ -0,0 +1,203 @@
+package anyrnnt
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet/internal/numeric"
+	"github.com/unixpickle/anyvec"
+)
+
+// latticeCost computes the negative log likelihood of
+// labels from an output lattice.
+//
+// The lattice contains a log probability distribution for
+// every (frame, label position) pair in every sequence.
+// For each sequence, the distributions are ordered first
+// by frame and then by label position.
+type latticeCost struct {
+	Lattice    anydiff.Res
+	Frames     []int
+	Labels     [][]int
+	NumSymbols int
+
+	// Alphas and Betas store the forward and backward
+	// log probabilities for each sequence.
+	Alphas [][]float64
+	Betas  [][]float64
+
+	LogProbs []float64
+	OutVec   anyvec.Vector
+}
+
+func newLatticeCost(lattice anydiff.Res, frames []int, labels [][]int,
+	numSymbols int) *latticeCost {
+	var expectedSize int
+	for i, label := range labels {
+		expectedSize += frames[i] * (len(label) + 1) * numSymbols
+	}
+	if lattice.Output().Len() != expectedSize {
+		panic(fmt.Sprintf("expected %d joint outputs but got %d", expectedSize,
+			lattice.Output().Len()))
+	}
+
+	// Don't want the result to retain references to these
+	// slices.
+	labels = append([][]int{}, labels...)
+	for i, label := range labels {
+		labels[i] = append([]int{}, label...)
+	}
+
+	res := &latticeCost{
+		Lattice:    lattice,
+		Frames:     append([]int{}, frames...),
+		Labels:     labels,
+		NumSymbols: numSymbols,
+	}
+	data := numeric.Float64s(lattice.Output().Data())
+	costs := make([]float64, len(labels))
+	for i, offset := range res.offsets() {
+		seqData := data[offset : offset+frames[i]*(len(labels[i])+1)*numSymbols]
+		alpha, beta, logProb := res.forwardBackward(seqData, frames[i], labels[i])
+		res.Alphas = append(res.Alphas, alpha)
+		res.Betas = append(res.Betas, beta)
+		res.LogProbs = append(res.LogProbs, logProb)
+		costs[i] = -logProb
+	}
+	c := lattice.Output().Creator()
+	res.OutVec = c.MakeVectorData(c.MakeNumericList(costs))
+	return res
+}
+
+func (l *latticeCost) Output() anyvec.Vector {
+	return l.OutVec
+}
+
+func (l *latticeCost) Vars() anydiff.VarSet {
+	return l.Lattice.Vars()
+}
+
+func (l *latticeCost) Propagate(u anyvec.Vector, g anydiff.Grad) {
+	upstream := numeric.Float64s(u.Data())
+	data := numeric.Float64s(l.Lattice.Output().Data())
+	grad := make([]float64, len(data))
+	for i, offset := range l.offsets() {
+		if math.IsInf(l.LogProbs[i], -1) {
+			continue
+		}
+		label := l.Labels[i]
+		numPos := len(label) + 1
+		alpha, beta := l.Alphas[i], l.Betas[i]
+		for t := 0; t < l.Frames[i]; t++ {
+			for pos := 0; pos < numPos; pos++ {
+				idx := t*numPos + pos
+				dist := offset + idx*l.NumSymbols
+				blankIdx := dist + l.NumSymbols - 1
+				var next float64
+				if t+1 < l.Frames[i] {
+					next = beta[idx+numPos]
+				} else if pos != len(label) {
+					next = math.Inf(-1)
+				}
+				grad[blankIdx] = -upstream[i] *
+					math.Exp(alpha[idx]+data[blankIdx]+next-l.LogProbs[i])
+				if pos < len(label) {
+					labelIdx := dist + label[pos]
+					grad[labelIdx] = -upstream[i] *
+						math.Exp(alpha[idx]+data[labelIdx]+beta[idx+1]-l.LogProbs[i])
+				}
+			}
+		}
+	}
+	c := l.Lattice.Output().Creator()
+	l.Lattice.Propagate(c.MakeVectorData(c.MakeNumericList(grad)), g)
+}
+
+// offsets computes the start of each sequence's outputs
+// in the lattice.
+func (l *latticeCost) offsets() []int {
+	res := make([]int, len(l.Labels))
+	var offset int
+	for i, label := range l.Labels {
+		res[i] = offset
+		offset += l.Frames[i] * (len(label) + 1) * l.NumSymbols
+	}
+	return res
+}
+
+// forwardBackward computes the forward and backward log
+// probabilities for a single sequence, as well as the
+// total log probability of the label.
+//
+// Both alpha and beta are indexed by t*(len(label)+1)+u,
+// where t is a frame and u is the number of emitted
+// labels.
+// The alpha values are the log probabilities of reaching
+// each point in the lattice, and the beta values are the
+// log probabilities of finishing the label from each
+// point (including the emission at that point).
+func (l *latticeCost) forwardBackward(data []float64, frames int,
+	label []int) (alpha, beta []float64, logProb float64) {
+	if frames == 0 {
+		if len(label) == 0 {
+			return nil, nil, 0
+		}
+		return nil, nil, math.Inf(-1)
+	}
+	numPos := len(label) + 1
+	blankProb := func(t, u int) float64 {
+		return data[(t*numPos+u)*l.NumSymbols+l.NumSymbols-1]
+	}
+	labelProb := func(t, u int) float64 {
+		return data[(t*numPos+u)*l.NumSymbols+label[u]]
+	}
+
+	alpha = make([]float64, frames*numPos)
+	for t := 0; t < frames; t++ {
+		for u := 0; u < numPos; u++ {
+			if t == 0 && u == 0 {
+				continue
+			}
+			sum := math.Inf(-1)
+			if t > 0 {
+				sum = alpha[(t-1)*numPos+u] + blankProb(t-1, u)
+			}
+			if u > 0 {
+				sum = addLogs(sum, alpha[t*numPos+u-1]+labelProb(t, u-1))
+			}
+			alpha[t*numPos+u] = sum
+		}
+	}
+
+	beta = make([]float64, frames*numPos)
+	for t := frames - 1; t >= 0; t-- {
+		for u := numPos - 1; u >= 0; u-- {
+			sum := math.Inf(-1)
+			if t+1 < frames {
+				sum = beta[(t+1)*numPos+u] + blankProb(t, u)
+			} else if u == numPos-1 {
+				sum = blankProb(t, u)
+			}
+			if u+1 < numPos {
+				sum = addLogs(sum, beta[t*numPos+u+1]+labelProb(t, u))
+			}
+			beta[t*numPos+u] = sum
+		}
+	}
+
+	return alpha, beta, beta[0]
+}
+
+// addLogs adds two numbers in the log domain.
+func addLogs(a, b float64) float64 {
+	if math.IsInf(a, -1) {
+		return b
+	} else if math.IsInf(b, -1) {
+		return a
+	}
+	normalizer := math.Max(a, b)
+	exp1 := math.Exp(a - normalizer)
+	exp2 := math.Exp(b - normalizer)
+	return math.Log(exp1+exp2) + normalizer
+}
@@ -0,0 +1,181 @@
+package anyrnnt
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+)
+
+func init() {
+	var m Model
+	serializer.RegisterTypedDeserializer(m.SerializerType(), DeserializeModel)
+}
+
+// A Model stores the prediction and joint networks of an
+// RNN-T model.
+// The encoder is not stored, since it may be any function
+// of the input sequence.
+//
+// Labels are bounded between 0 and NumLabels.
+// The blank symbol is represented by the index NumLabels.
+//
+// The Predictor receives one-hot vectors of size
+// NumLabels+1.
+// Its first input is the one-hot vector for the blank
+// symbol, which serves as a start token.
+// Each subsequent input is the one-hot vector for the
+// previous label.
+//
+// The Joint network receives the concatenation of an
+// encoder output and a Predictor output.
+// It must produce NumLabels+1 log probabilities, the last
+// of which corresponds to the blank symbol.
+type Model struct {
+	Predictor anyrnn.Block
+	Joint     anynet.Layer
+	NumLabels int
+}
+
+// NewModel creates a Model with an LSTM prediction network
+// and a joint network with one hidden tanh layer.
+//
+// The encSize argument specifies the size of the encoder
+// outputs.
+func NewModel(c anyvec.Creator, numLabels, encSize, predSize, jointSize int) *Model {
+	return &Model{
+		Predictor: anyrnn.NewLSTM(c, numLabels+1, predSize),
+		Joint: anynet.Net{
+			anynet.NewFC(c, encSize+predSize, jointSize),
+			anynet.Tanh,
+			anynet.NewFC(c, jointSize, numLabels+1),
+			anynet.LogSoftmax,
+		},
+		NumLabels: numLabels,
+	}
+}
+
+// DeserializeModel deserializes a Model.
+func DeserializeModel(d []byte) (*Model, error) {
+	var res Model
+	var numLabels serializer.Int
+	err := serializer.DeserializeAny(d, &res.Predictor, &res.Joint, &numLabels)
+	if err != nil {
+		return nil, essentials.AddCtx("deserialize Model", err)
+	}
+	res.NumLabels = int(numLabels)
+	return &res, nil
+}
+
+// Cost computes the negative log likelihood of each label
+// given the outputs of an encoder.
+// The result is a packed vector with one entry per
+// sequence in the batch.
+//
+// The anyvec.Creator must use an anyvec.NumericList type
+// []float32 or []float64.
+// No other numeric types are supported.
+func (m *Model) Cost(encoded anyseq.Seq, labels [][]int) anydiff.Res {
+	c := encoded.Creator()
+	if len(labels) == 0 {
+		return anydiff.NewConst(c.MakeVector(0))
+	}
+	frames := make([]int, len(labels))
+	var encPadded anydiff.Res
+	var encFeatures, encSteps int
+	if outs := encoded.Output(); len(outs) > 0 {
+		var mask anyvec.Vector
+		encPadded, mask = anyrnn.ToPadded(encoded)
+		encSteps = len(outs)
+		encFeatures = encPadded.Output().Len() / mask.Len()
+		for _, batch := range outs {
+			for i, pres := range batch.Present {
+				if pres {
+					frames[i]++
+				}
+			}
+		}
+	}
+
+	predPadded, predSteps := m.predictions(c, labels)
+	predFeatures := predPadded.Output().Len() / (len(labels) * predSteps)
+
+	// Create one joint input for every (frame, label
+	// position) pair in every sequence.
+	var table []int
+	var numPairs int
+	predOffset := len(labels) * encSteps * encFeatures
+	for b, label := range labels {
+		for t := 0; t < frames[b]; t++ {
+			for u := 0; u <= len(label); u++ {
+				for i := 0; i < encFeatures; i++ {
+					table = append(table, (b*encSteps+t)*encFeatures+i)
+				}
+				for i := 0; i < predFeatures; i++ {
+					table = append(table, predOffset+(b*predSteps+u)*predFeatures+i)
+				}
+				numPairs++
+			}
+		}
+	}
+
+	var lattice anydiff.Res
+	if numPairs == 0 {
+		lattice = anydiff.NewConst(c.MakeVector(0))
+	} else {
+		joined := anydiff.Concat(encPadded, predPadded)
+		pairs := anydiff.Map(c.MakeMapper(joined.Output().Len(), table), joined)
+		lattice = m.Joint.Apply(pairs, numPairs)
+	}
+	return newLatticeCost(lattice, frames, labels, m.NumLabels+1)
+}
+
+// Parameters returns the parameters of the Predictor and
+// Joint networks.
+func (m *Model) Parameters() []*anydiff.Var {
+	return anynet.AllParameters(m.Predictor, m.Joint)
+}
+
+// SerializerType returns the unique ID used to serialize
+// a Model with the serializer package.
+func (m *Model) SerializerType() string {
+	return "github.com/unixpickle/anynet/anyrnnt.Model"
+}
+
+// Serialize serializes the Model.
+func (m *Model) Serialize() ([]byte, error) {
+	return serializer.SerializeAny(m.Predictor, m.Joint, serializer.Int(m.NumLabels))
+}
+
+// predictions applies the Predictor to every label and
+// returns the padded outputs, along with the number of
+// timesteps in the padded outputs.
+func (m *Model) predictions(c anyvec.Creator, labels [][]int) (anydiff.Res, int) {
+	var inputs [][]anyvec.Vector
+	var maxLen int
+	for _, label := range labels {
+		seq := []anyvec.Vector{m.oneHot(c, m.NumLabels)}
+		for _, l := range label {
+			seq = append(seq, m.oneHot(c, l))
+		}
+		inputs = append(inputs, seq)
+		if len(seq) > maxLen {
+			maxLen = len(seq)
+		}
+	}
+	outs := anyrnn.Map(anyseq.ConstSeqList(c, inputs), m.Predictor)
+	padded, _ := anyrnn.ToPadded(outs)
+	return padded, maxLen
+}
+
+func (m *Model) oneHot(c anyvec.Creator, idx int) anyvec.Vector {
+	if idx < 0 || idx > m.NumLabels {
+		panic("label out of bounds")
+	}
+	data := make([]float64, m.NumLabels+1)
+	data[idx] = 1
+	return c.MakeVectorData(c.MakeNumericList(data))
+}
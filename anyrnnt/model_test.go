@@ -0,0 +1,201 @@
+package anyrnnt
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anydifftest"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec32"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/serializer"
+)
+
+const (
+	testSymbolCount = 3
+	testPrecision   = 1e-3
+)
+
+func TestLatticeCostOutput(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	var frames []int
+	var labels [][]int
+	var lattices [][]float64
+	for i := 0; i < 6; i++ {
+		label := make([]int, rand.Intn(4))
+		for j := range label {
+			label[j] = rand.Intn(testSymbolCount)
+		}
+		labels = append(labels, label)
+		frames = append(frames, rand.Intn(4))
+		lattices = append(lattices, randomLattice(frames[i], len(label)))
+	}
+	var joined []float64
+	for _, l := range lattices {
+		joined = append(joined, l...)
+	}
+	lattice := anydiff.NewConst(c.MakeVectorData(joined))
+	actual := newLatticeCost(lattice, frames, labels, testSymbolCount+1)
+	for i, cost := range actual.Output().Data().([]float64) {
+		expected := exactLikelihood(lattices[i], frames[i], labels[i], 0, 0)
+		if expected == 0 {
+			if !math.IsInf(cost, 1) {
+				t.Errorf("sequence %d: expected infinite cost but got %f", i, cost)
+			}
+		} else if math.Abs(math.Exp(-cost)-expected)/expected > testPrecision {
+			t.Errorf("sequence %d: expected likelihood %e but got %e", i, expected,
+				math.Exp(-cost))
+		}
+	}
+}
+
+func TestLatticeCostGrad(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	frames := []int{3, 2, 4}
+	labels := [][]int{{0, 2}, {}, {1, 1, 0}}
+	var data []float64
+	for i, f := range frames {
+		data = append(data, randomLattice(f, len(labels[i]))...)
+	}
+	lattice := anydiff.NewVar(c.MakeVectorData(data))
+	ch := &anydifftest.ResChecker{
+		F: func() anydiff.Res {
+			return newLatticeCost(lattice, frames, labels, testSymbolCount+1)
+		},
+		V:     []*anydiff.Var{lattice},
+		Prec:  testPrecision * 3,
+		Delta: testPrecision,
+	}
+	ch.FullCheck(t)
+}
+
+func TestModelCostGrad(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	model := NewModel(c, testSymbolCount, 2, 3, 4)
+	var inVars []*anydiff.Var
+	var inBatches []*anyseq.ResBatch
+	for _, pres := range [][]bool{{true, true}, {true, false}} {
+		vec := c.MakeVector(2 * anyrnn.PresentMap(pres).NumPresent())
+		anyvec.Rand(vec, anyvec.Normal, nil)
+		v := anydiff.NewVar(vec)
+		inVars = append(inVars, v)
+		inBatches = append(inBatches, &anyseq.ResBatch{Packed: v, Present: pres})
+	}
+	encoded := anyseq.ResSeq(c, inBatches)
+	labels := [][]int{{2, 0}, {1}}
+	ch := &anydifftest.ResChecker{
+		F: func() anydiff.Res {
+			return model.Cost(encoded, labels)
+		},
+		V:     append(inVars, model.Parameters()...),
+		Prec:  testPrecision * 3,
+		Delta: testPrecision,
+	}
+	ch.FullCheck(t)
+}
+
+func TestGreedyLabels(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+
+	// The joint network copies the encoder outputs and
+	// ignores the prediction network.
+	weights := make([]float64, 4*6)
+	for i := 0; i < 4; i++ {
+		weights[i*6+i] = 1
+	}
+	model := &Model{
+		Predictor: anyrnn.NewLSTM(c, 4, 2),
+		Joint: &anynet.FC{
+			InCount:  6,
+			OutCount: 4,
+			Weights:  anydiff.NewVar(c.MakeVectorData(weights)),
+			Biases:   anydiff.NewVar(c.MakeVector(4)),
+		},
+		NumLabels: 3,
+	}
+	encoded := anyseq.ConstSeqList(c, [][]anyvec.Vector{
+		{
+			c.MakeVectorData([]float64{0, 0, 0, 1}),
+			c.MakeVectorData([]float64{0, 1, 0, 0}),
+			c.MakeVectorData([]float64{0, 0, 0, 1}),
+			c.MakeVectorData([]float64{0, 0, 1, 0}),
+		},
+		{
+			c.MakeVectorData([]float64{1, 0, 0, 0}),
+		},
+	})
+
+	actual := model.GreedyLabels(encoded, 1)
+	expected := [][]int{{1, 2}, {0}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+
+	actual = model.GreedyLabels(encoded, 2)
+	expected = [][]int{{1, 1, 2, 2}, {0, 0}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+}
+
+func TestModelSerialize(t *testing.T) {
+	model := NewModel(anyvec32.CurrentCreator(), 3, 2, 4, 5)
+	data, err := serializer.SerializeAny(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var newModel *Model
+	if err := serializer.DeserializeAny(data, &newModel); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(model, newModel) {
+		t.Error("models not equal")
+	}
+}
+
+// randomLattice creates random log probabilities for
+// every point in a lattice.
+func randomLattice(frames, labelLen int) []float64 {
+	var res []float64
+	for i := 0; i < frames*(labelLen+1); i++ {
+		probs := make([]float64, testSymbolCount+1)
+		var sum float64
+		for j := range probs {
+			probs[j] = math.Abs(rand.NormFloat64())
+			sum += probs[j]
+		}
+		for _, p := range probs {
+			res = append(res, math.Log(p/sum))
+		}
+	}
+	return res
+}
+
+// exactLikelihood computes the likelihood of a label by
+// enumerating every path through the lattice.
+func exactLikelihood(lattice []float64, frames int, label []int, t, u int) float64 {
+	if frames == 0 {
+		if len(label) == 0 {
+			return 1
+		}
+		return 0
+	}
+	numSymbols := testSymbolCount + 1
+	dist := lattice[(t*(len(label)+1)+u)*numSymbols:]
+	var res float64
+	if u < len(label) {
+		res += math.Exp(dist[label[u]]) * exactLikelihood(lattice, frames, label, t, u+1)
+	}
+	if t+1 < frames {
+		res += math.Exp(dist[numSymbols-1]) * exactLikelihood(lattice, frames, label, t+1, u)
+	} else if u == len(label) {
+		res += math.Exp(dist[numSymbols-1])
+	}
+	return res
+}
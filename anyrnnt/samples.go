@@ -0,0 +1,26 @@
+package anyrnnt
+
+import (
+	"github.com/unixpickle/anynet/anysgd"
+	"github.com/unixpickle/anyvec"
+)
+
+// A Sample is a training sequence paired with its
+// corresponding label.
+type Sample struct {
+	Input []anyvec.Vector
+	Label []int
+}
+
+// A SampleList is an anysgd.SampleList that produces
+// RNN-T samples.
+//
+// Lists which also implement anysgd.LenSampleList can be
+// wrapped in an anysgd.BucketSampleList to batch together
+// sequences of similar lengths.
+type SampleList interface {
+	anysgd.SampleList
+
+	GetSample(idx int) (*Sample, error)
+	Creator() anyvec.Creator
+}
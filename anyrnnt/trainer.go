@@ -0,0 +1,102 @@
+package anyrnnt
+
+import (
+	"errors"
+	"math/rand"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anysgd"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+)
+
+// A Batch stores a batch of input sequences and the
+// corresponding labels for each.
+type Batch struct {
+	Inputs anyseq.Seq
+	Labels [][]int
+}
+
+// A Trainer creates batches, computes gradients, and adds
+// up costs for RNN-T.
+type Trainer struct {
+	// Encoder produces the encoder outputs for a batch of
+	// input sequences.
+	Encoder func(anyseq.Seq) anyseq.Seq
+
+	Model  *Model
+	Params []*anydiff.Var
+
+	// Average indicates whether or not the total cost should
+	// be averaged before computing gradients.
+	// This affects gradients, LastCost, and the output of
+	// TotalCost().
+	Average bool
+
+	// After every gradient computation, LastCost is set to
+	// the cost from the batch.
+	LastCost anyvec.Numeric
+}
+
+// Fetch produces a *Batch for the subset of samples.
+// The s argument must implement SampleList.
+// The batch may not be empty.
+func (t *Trainer) Fetch(s anysgd.SampleList) (anysgd.Batch, error) {
+	if s.Len() == 0 {
+		return nil, errors.New("fetch batch: empty batch")
+	}
+	l := s.(SampleList)
+	ins := make([][]anyvec.Vector, l.Len())
+	outs := make([][]int, l.Len())
+	for i := 0; i < l.Len(); i++ {
+		sample, err := l.GetSample(i)
+		if err != nil {
+			return nil, essentials.AddCtx("fetch batch", err)
+		}
+		ins[i] = sample.Input
+		outs[i] = sample.Label
+	}
+	return &Batch{
+		Inputs: anyseq.ConstSeqList(l.Creator(), ins),
+		Labels: outs,
+	}, nil
+}
+
+// TotalCost computes the total cost for the *Batch.
+//
+// For more information on how this works, see
+// Model.Cost().
+func (t *Trainer) TotalCost(batch anysgd.Batch) anydiff.Res {
+	b := batch.(*Batch)
+	encoded := t.Encoder(b.Inputs)
+	costs := t.Model.Cost(encoded, b.Labels)
+	sum := anydiff.Sum(costs)
+	if t.Average {
+		scaler := sum.Output().Creator().MakeNumeric(1 / float64(costs.Output().Len()))
+		return anydiff.Scale(sum, scaler)
+	} else {
+		return sum
+	}
+}
+
+// Gradient computes the gradient for the batch's cost.
+// It also sets t.LastCost to the numerical value of the
+// total cost.
+//
+// The b argument must be a *Batch.
+func (t *Trainer) Gradient(b anysgd.Batch) anydiff.Grad {
+	grad, lc := anysgd.CosterGrad(t, b, t.Params)
+	t.LastCost = lc
+	return grad
+}
+
+// SetRand sets the source of randomness for the parts of
+// the model that implement anynet.RandSetter.
+//
+// The Encoder is not affected, since it is an arbitrary
+// function.
+func (t *Trainer) SetRand(r *rand.Rand) {
+	anynet.SetAllRand(r, t.Model.Predictor, t.Model.Joint)
+}
@@ -12,6 +12,19 @@ import (
 type Sample struct {
 	Input  []anyvec.Vector
 	Output []anyvec.Vector
+
+	// Weight scales the cost of every timestep in the
+	// sample.
+	// If it is 0, a weight of 1 is used.
+	Weight float64
+
+	// If non-nil, Weights specifies a weight for each
+	// timestep of the output sequence.
+	// These weights are multiplied by Weight.
+	//
+	// Unlike Weight, a weight of 0 is used as-is, so
+	// Weights may be used to mask out timesteps.
+	Weights []float64
 }
 
 // A SampleList is an anysgd.SampleList that produces
@@ -26,6 +39,10 @@ type SampleList interface {
 // A SortableSampleList is a SampleList with an extra
 // LenAt method for efficiently getting the length of an
 // input sequence.
+//
+// A SortableSampleList can be wrapped in an
+// anysgd.BucketSampleList to batch together sequences of
+// similar lengths.
 type SortableSampleList interface {
 	SampleList
 
@@ -36,6 +53,9 @@ type SortableSampleList interface {
 // samples will be sorted within reasonably small chunks.
 // This is often beneficial for RNNs on a GPU, since it
 // helps to keep batch sizes stable across timesteps.
+//
+// For more control over batching, see
+// anysgd.BucketSampleList.
 type SortSampleList struct {
 	SortableSampleList
 
@@ -2,11 +2,13 @@ package anys2s
 
 import (
 	"errors"
+	"math/rand"
 
 	"github.com/unixpickle/anydiff"
 	"github.com/unixpickle/anydiff/anyseq"
 	"github.com/unixpickle/anynet"
 	"github.com/unixpickle/anynet/anysgd"
+	"github.com/unixpickle/anynet/internal/sampleweight"
 	"github.com/unixpickle/anyvec"
 	"github.com/unixpickle/essentials"
 )
@@ -16,6 +18,16 @@ import (
 type Batch struct {
 	Inputs  anyseq.Seq
 	Outputs anyseq.Seq
+
+	// Weights stores a weight for each output timestep of
+	// each sequence.
+	// Each vector in the sequence has one component.
+	// It is nil if every weight is 1.
+	Weights anyseq.Seq
+
+	// WeightSum is the sum of every weight in Weights.
+	// It is only used if Weights is non-nil.
+	WeightSum float64
 }
 
 // A Trainer creates batches, computes gradients, and adds
@@ -25,10 +37,21 @@ type Trainer struct {
 	Cost   anynet.Cost
 	Params []*anydiff.Var
 
+	// Model, if non-nil, is the model applied by Func,
+	// such as an anyrnn.Block or an anyrnn.SeqStack.
+	// SetRand passes its source of randomness on to it.
+	Model interface{}
+
 	// Average indicates whether or not the total cost should
 	// be averaged before computing gradients.
 	// This affects gradients, LastCost, and the output of
 	// TotalCost().
+	//
+	// If the batch is weighted, the weighted cost is divided
+	// by the sum of the weights (including the weights of
+	// masked out timesteps, which are 0).
+	// Otherwise, the cost is divided by the total number of
+	// output timesteps.
 	Average bool
 
 	// After every gradient computation, LastCost is set to
@@ -46,6 +69,10 @@ func (t *Trainer) Fetch(s anysgd.SampleList) (anysgd.Batch, error) {
 	l := s.(SampleList)
 	ins := make([][]anyvec.Vector, l.Len())
 	outs := make([][]anyvec.Vector, l.Len())
+	weights := make([][]anyvec.Vector, l.Len())
+	var weightSum float64
+	var weighted bool
+	c := l.Creator()
 	for i := 0; i < l.Len(); i++ {
 		sample, err := l.GetSample(i)
 		if err != nil {
@@ -53,14 +80,38 @@ func (t *Trainer) Fetch(s anysgd.SampleList) (anysgd.Batch, error) {
 		}
 		ins[i] = sample.Input
 		outs[i] = sample.Output
+		if sample.Weights != nil && len(sample.Weights) != len(sample.Output) {
+			return nil, errors.New("fetch batch: weight count does not match output length")
+		}
+		sampleWeight := sampleweight.Value(sample.Weight)
+		for step := range sample.Output {
+			w := sampleWeight
+			if sample.Weights != nil {
+				w *= sample.Weights[step]
+			}
+			if w != 1 {
+				weighted = true
+			}
+			weightSum += w
+			weightVec := c.MakeVectorData(c.MakeNumericList([]float64{w}))
+			weights[i] = append(weights[i], weightVec)
+		}
+	}
+	res := &Batch{
+		Inputs:  anyseq.ConstSeqList(c, ins),
+		Outputs: anyseq.ConstSeqList(c, outs),
 	}
-	return &Batch{
-		Inputs:  anyseq.ConstSeqList(l.Creator(), ins),
-		Outputs: anyseq.ConstSeqList(l.Creator(), outs),
-	}, nil
+	if weighted {
+		res.Weights = anyseq.ConstSeqList(c, weights)
+		res.WeightSum = weightSum
+	}
+	return res, nil
 }
 
 // TotalCost computes the total cost for the *Batch.
+//
+// If the batch is weighted, the cost at each timestep is
+// multiplied by the corresponding weight.
 func (t *Trainer) TotalCost(batch anysgd.Batch) anydiff.Res {
 	b := batch.(*Batch)
 	actual := t.Func(b.Inputs)
@@ -76,15 +127,27 @@ func (t *Trainer) TotalCost(batch anysgd.Batch) anydiff.Res {
 		if batch.NumPresent() != n {
 			panic("mismatching actual and desired sequence shapes")
 		}
+		c := t.Cost.Cost(anydiff.NewConst(batch.Packed), a, n)
+		if b.Weights != nil {
+			weights := b.Weights.Output()[idx].Packed
+			c = anydiff.Mul(c, anydiff.NewConst(weights))
+		}
 		costCount += n
 		idx++
-		c := t.Cost.Cost(anydiff.NewConst(batch.Packed), a, n)
 		return c
 	})
 
 	sum := anydiff.Sum(anyseq.Sum(allCosts))
 	if t.Average {
-		scaler := sum.Output().Creator().MakeNumeric(1 / float64(costCount))
+		divisor := float64(costCount)
+		if b.Weights != nil {
+			divisor = b.WeightSum
+		}
+		if divisor == 0 {
+			// Every timestep is masked out.
+			return sum
+		}
+		scaler := sum.Output().Creator().MakeNumeric(1 / divisor)
 		return anydiff.Scale(sum, scaler)
 	} else {
 		return sum
@@ -101,3 +164,10 @@ func (t *Trainer) Gradient(b anysgd.Batch) anydiff.Grad {
 	t.LastCost = lc
 	return grad
 }
+
+// SetRand sets the source of randomness for t.Model if
+// it is an anynet.RandSetter.
+// This way, SGD can make dropout reproducible.
+func (t *Trainer) SetRand(r *rand.Rand) {
+	anynet.SetAllRand(r, t.Model)
+}
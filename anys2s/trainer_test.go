@@ -0,0 +1,154 @@
+package anys2s
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anysgd"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestTrainerWeights(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	vec := func(x float64) anyvec.Vector {
+		return c.MakeVectorData([]float64{x})
+	}
+	samples := testSampleList{
+		{
+			Input:   []anyvec.Vector{vec(1), vec(2)},
+			Output:  []anyvec.Vector{vec(0), vec(0)},
+			Weights: []float64{1, 0},
+		},
+		{
+			Input:  []anyvec.Vector{vec(3)},
+			Output: []anyvec.Vector{vec(1)},
+			Weight: 2,
+		},
+	}
+	bias := anydiff.NewVar(vec(0))
+	trainer := &Trainer{
+		Func: func(s anyseq.Seq) anyseq.Seq {
+			return anyseq.Map(s, func(v anydiff.Res, n int) anydiff.Res {
+				return anydiff.AddRepeated(v, bias)
+			})
+		},
+		Cost:    anynet.MSE{},
+		Params:  []*anydiff.Var{bias},
+		Average: true,
+	}
+	batch, err := trainer.Fetch(samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second timestep of the first sequence is masked
+	// out, so the weighted squared errors are 1*1 and 2*4,
+	// and the weights sum to 1+0+2.
+	grad := trainer.Gradient(batch)
+	if actual := trainer.LastCost.(float64); math.Abs(actual-3) > 1e-8 {
+		t.Errorf("expected cost 3 but got %f", actual)
+	}
+	expected := (2*1*1 + 2*2*2) / 3.0
+	if actual := grad[bias].Data().([]float64)[0]; math.Abs(actual-expected) > 1e-8 {
+		t.Errorf("expected gradient %f but got %f", expected, actual)
+	}
+
+	trainer.Average = false
+	total := anyvec.Sum(trainer.TotalCost(batch).Output()).(float64)
+	if math.Abs(total-9) > 1e-8 {
+		t.Errorf("expected total cost 9 but got %f", total)
+	}
+
+	// When every timestep is masked out, the average cost
+	// is 0 rather than NaN.
+	trainer.Average = true
+	samples[0].Weights = []float64{0, 0}
+	samples[1].Weights = []float64{0}
+	batch, err = trainer.Fetch(samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	grad = trainer.Gradient(batch)
+	if actual := trainer.LastCost.(float64); actual != 0 {
+		t.Errorf("expected masked cost 0 but got %f", actual)
+	}
+	if actual := grad[bias].Data().([]float64)[0]; actual != 0 {
+		t.Errorf("expected masked gradient 0 but got %f", actual)
+	}
+
+	samples[1].Weights = []float64{1, 1}
+	if _, err := trainer.Fetch(samples); err == nil {
+		t.Error("expected error for mismatched weight count")
+	}
+}
+
+func TestTrainerSetRand(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	samples := testSampleList{
+		{
+			Input:  []anyvec.Vector{c.MakeVectorData([]float64{1, 2, 3, 4})},
+			Output: []anyvec.Vector{c.MakeVectorData([]float64{0, 0, 0, 0})},
+		},
+	}
+	bias := anydiff.NewVar(c.MakeVectorData([]float64{1, 1, 1, 1}))
+	dropout := &anynet.Dropout{Enabled: true, KeepProb: 0.5}
+	trainer := &Trainer{
+		Func: func(s anyseq.Seq) anyseq.Seq {
+			return anyseq.Map(s, func(v anydiff.Res, n int) anydiff.Res {
+				return dropout.Apply(anydiff.AddRepeated(v, bias), n)
+			})
+		},
+		Cost:   anynet.MSE{},
+		Params: []*anydiff.Var{bias},
+		Model:  dropout,
+	}
+	batch, err := trainer.Fetch(samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var grads [][]float64
+	for i := 0; i < 2; i++ {
+		trainer.SetRand(rand.New(rand.NewSource(1337)))
+		var grad []float64
+		for j := 0; j < 5; j++ {
+			g := trainer.Gradient(batch)[bias].Data().([]float64)
+			grad = append(grad, g...)
+		}
+		grads = append(grads, grad)
+	}
+	if dropout.Rand == nil {
+		t.Fatal("Model was not given a source of randomness")
+	}
+	if !reflect.DeepEqual(grads[0], grads[1]) {
+		t.Errorf("gradients differ: %v vs %v", grads[0], grads[1])
+	}
+}
+
+type testSampleList []*Sample
+
+func (t testSampleList) Len() int {
+	return len(t)
+}
+
+func (t testSampleList) Swap(i, j int) {
+	t[i], t[j] = t[j], t[i]
+}
+
+func (t testSampleList) Slice(i, j int) anysgd.SampleList {
+	return append(testSampleList{}, t[i:j]...)
+}
+
+func (t testSampleList) GetSample(idx int) (*Sample, error) {
+	return t[idx], nil
+}
+
+func (t testSampleList) Creator() anyvec.Creator {
+	return anyvec64.CurrentCreator()
+}
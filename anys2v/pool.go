@@ -0,0 +1,357 @@
+package anys2v
+
+import (
+	"math/rand"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anynet/internal/numeric"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+)
+
+// maskPenalty is added to the scores of absent timesteps
+// so that they are never selected.
+const maskPenalty = -1e30
+
+func init() {
+	var m MeanPool
+	serializer.RegisterTypedDeserializer(m.SerializerType(), DeserializeMeanPool)
+	var x MaxPool
+	serializer.RegisterTypedDeserializer(x.SerializerType(), DeserializeMaxPool)
+	var l LastPool
+	serializer.RegisterTypedDeserializer(l.SerializerType(), DeserializeLastPool)
+	var a AttentionPool
+	serializer.RegisterTypedDeserializer(a.SerializerType(), DeserializeAttentionPool)
+}
+
+// A Pooler reduces each sequence in a batch to a single
+// vector.
+//
+// The result contains one vector per sequence, in the
+// order of the sequences in the batch.
+// Sequences may have different lengths.
+// Empty sequences produce zero vectors.
+//
+// If every sequence in the batch is empty, the batch has
+// no timesteps, so neither the number of sequences nor
+// the vector size is known.
+// In this case, the result is an empty vector.
+//
+// A Pooler can be combined with a recurrent network to
+// produce a function for Trainer.Func, for example:
+//
+//     func(in anyseq.Seq) anydiff.Res {
+//         return pooler.Pool(anyrnn.Map(in, block))
+//     }
+type Pooler interface {
+	Pool(in anyseq.Seq) anydiff.Res
+}
+
+// MeanPool is a Pooler which averages the vectors in each
+// sequence.
+type MeanPool struct{}
+
+// DeserializeMeanPool deserializes a MeanPool.
+func DeserializeMeanPool(d []byte) (MeanPool, error) {
+	return MeanPool{}, nil
+}
+
+// Pool averages the vectors in each sequence.
+func (m MeanPool) Pool(in anyseq.Seq) anydiff.Res {
+	p := newPadded(in)
+	if p == nil {
+		return emptyPool(in)
+	}
+	weights := make([]float64, p.Batch*p.Time)
+	for b, length := range p.Lengths {
+		for t := 0; t < p.Time; t++ {
+			if p.Present(b, t) {
+				weights[b*p.Time+t] = 1 / float64(length)
+			}
+		}
+	}
+	return p.weightedSum(p.Res, anydiff.NewConst(p.vector(weights)))
+}
+
+// SerializerType returns the unique ID used to serialize
+// a MeanPool with the serializer package.
+func (m MeanPool) SerializerType() string {
+	return "github.com/unixpickle/anynet/anys2v.MeanPool"
+}
+
+// Serialize serializes the instance.
+func (m MeanPool) Serialize() ([]byte, error) {
+	return []byte{}, nil
+}
+
+// MaxPool is a Pooler which computes the component-wise
+// maximum of the vectors in each sequence.
+type MaxPool struct{}
+
+// DeserializeMaxPool deserializes a MaxPool.
+func DeserializeMaxPool(d []byte) (MaxPool, error) {
+	return MaxPool{}, nil
+}
+
+// Pool computes the maximum of each component over time.
+func (m MaxPool) Pool(in anyseq.Seq) anydiff.Res {
+	p := newPadded(in)
+	if p == nil {
+		return emptyPool(in)
+	}
+
+	penalties := make([]float64, 0, p.Batch*p.Time*p.Features)
+	for b := 0; b < p.Batch; b++ {
+		for f := 0; f < p.Features; f++ {
+			for t := 0; t < p.Time; t++ {
+				if p.Present(b, t) {
+					penalties = append(penalties, 0)
+				} else {
+					penalties = append(penalties, maskPenalty)
+				}
+			}
+		}
+	}
+	rows := p.featureRows(p.Res)
+
+	scores := rows.Output().Copy()
+	scores.Add(p.vector(penalties))
+	return anydiff.Map(anyvec.MapMax(scores, p.Time), rows)
+}
+
+// SerializerType returns the unique ID used to serialize
+// a MaxPool with the serializer package.
+func (m MaxPool) SerializerType() string {
+	return "github.com/unixpickle/anynet/anys2v.MaxPool"
+}
+
+// Serialize serializes the instance.
+func (m MaxPool) Serialize() ([]byte, error) {
+	return []byte{}, nil
+}
+
+// LastPool is a Pooler which selects the last vector in
+// each sequence.
+type LastPool struct{}
+
+// DeserializeLastPool deserializes a LastPool.
+func DeserializeLastPool(d []byte) (LastPool, error) {
+	return LastPool{}, nil
+}
+
+// Pool selects the last vector in each sequence.
+func (l LastPool) Pool(in anyseq.Seq) anydiff.Res {
+	p := newPadded(in)
+	if p == nil {
+		return emptyPool(in)
+	}
+	table := make([]int, 0, p.Batch*p.Features)
+	for b := 0; b < p.Batch; b++ {
+		last := p.lastStep(b)
+		if last < 0 {
+			// Every entry for the sequence is zero.
+			last = 0
+		}
+		for f := 0; f < p.Features; f++ {
+			table = append(table, (b*p.Time+last)*p.Features+f)
+		}
+	}
+	c := p.Creator
+	return anydiff.Map(c.MakeMapper(p.Res.Output().Len(), table), p.Res)
+}
+
+// SerializerType returns the unique ID used to serialize
+// a LastPool with the serializer package.
+func (l LastPool) SerializerType() string {
+	return "github.com/unixpickle/anynet/anys2v.LastPool"
+}
+
+// Serialize serializes the instance.
+func (l LastPool) Serialize() ([]byte, error) {
+	return []byte{}, nil
+}
+
+// AttentionPool is a Pooler which computes a weighted
+// average of the vectors in each sequence, where the
+// weights are computed by a learned scoring function.
+//
+// The Scorer is applied to every vector to produce a
+// scalar score.
+// The weights for each sequence are the softmax of the
+// scores for the sequence's timesteps.
+type AttentionPool struct {
+	Scorer anynet.Layer
+}
+
+// NewAttentionPool creates an AttentionPool with a scorer
+// that has one hidden tanh layer.
+func NewAttentionPool(c anyvec.Creator, inSize, hiddenSize int) *AttentionPool {
+	return &AttentionPool{
+		Scorer: anynet.Net{
+			anynet.NewFC(c, inSize, hiddenSize),
+			anynet.Tanh,
+			anynet.NewFC(c, hiddenSize, 1),
+		},
+	}
+}
+
+// DeserializeAttentionPool deserializes an AttentionPool.
+func DeserializeAttentionPool(d []byte) (*AttentionPool, error) {
+	var res AttentionPool
+	if err := serializer.DeserializeAny(d, &res.Scorer); err != nil {
+		return nil, essentials.AddCtx("deserialize AttentionPool", err)
+	}
+	return &res, nil
+}
+
+// Pool computes an attention-weighted average of the
+// vectors in each sequence.
+func (a *AttentionPool) Pool(in anyseq.Seq) anydiff.Res {
+	p := newPadded(in)
+	if p == nil {
+		return emptyPool(in)
+	}
+	penalties := make([]float64, p.Batch*p.Time)
+	for b := 0; b < p.Batch; b++ {
+		for t := 0; t < p.Time; t++ {
+			if !p.Present(b, t) {
+				penalties[b*p.Time+t] = maskPenalty
+			}
+		}
+	}
+	return anydiff.Pool(p.Res, func(padded anydiff.Res) anydiff.Res {
+		scores := a.Scorer.Apply(padded, p.Batch*p.Time)
+		if scores.Output().Len() != p.Batch*p.Time {
+			panic("scorer must produce one value per timestep")
+		}
+		scores = anydiff.Add(scores, anydiff.NewConst(p.vector(penalties)))
+		weights := anydiff.Exp(anydiff.LogSoftmax(scores, p.Time))
+		return p.weightedSum(padded, weights)
+	})
+}
+
+// Parameters returns the parameters of the Scorer if it
+// implements anynet.Parameterizer.
+func (a *AttentionPool) Parameters() []*anydiff.Var {
+	return anynet.AllParameters(a.Scorer)
+}
+
+// SetRand sets the source of randomness for the Scorer if
+// it implements anynet.RandSetter.
+func (a *AttentionPool) SetRand(r *rand.Rand) {
+	anynet.SetAllRand(r, a.Scorer)
+}
+
+// SerializerType returns the unique ID used to serialize
+// an AttentionPool with the serializer package.
+func (a *AttentionPool) SerializerType() string {
+	return "github.com/unixpickle/anynet/anys2v.AttentionPool"
+}
+
+// Serialize serializes the AttentionPool.
+func (a *AttentionPool) Serialize() ([]byte, error) {
+	return serializer.SerializeAny(a.Scorer)
+}
+
+// padded is a padded representation of a batch of
+// sequences, as produced by anyrnn.ToPadded.
+type padded struct {
+	Creator  anyvec.Creator
+	Res      anydiff.Res
+	Mask     []float64
+	Lengths  []int
+	Batch    int
+	Time     int
+	Features int
+}
+
+// newPadded pads a batch of sequences.
+// It returns nil if the sequences contain no vectors.
+func newPadded(in anyseq.Seq) *padded {
+	res, mask := anyrnn.ToPadded(in)
+	if mask.Len() == 0 || res.Output().Len() == 0 {
+		return nil
+	}
+	batch := len(in.Output()[0].Present)
+	p := &padded{
+		Creator:  in.Creator(),
+		Res:      res,
+		Mask:     numeric.Float64s(mask.Data()),
+		Lengths:  make([]int, batch),
+		Batch:    batch,
+		Time:     mask.Len() / batch,
+		Features: res.Output().Len() / mask.Len(),
+	}
+	for b := range p.Lengths {
+		for t := 0; t < p.Time; t++ {
+			if p.Present(b, t) {
+				p.Lengths[b]++
+			}
+		}
+	}
+	return p
+}
+
+// Present checks if sequence b is present at timestep t.
+func (p *padded) Present(b, t int) bool {
+	return p.Mask[b*p.Time+t] != 0
+}
+
+func (p *padded) lastStep(b int) int {
+	for t := p.Time - 1; t >= 0; t-- {
+		if p.Present(b, t) {
+			return t
+		}
+	}
+	return -1
+}
+
+func (p *padded) vector(data []float64) anyvec.Vector {
+	return p.Creator.MakeVectorData(p.Creator.MakeNumericList(data))
+}
+
+// weightedSum computes a weighted sum over time for each
+// sequence, given a padded tensor and one weight per
+// padded timestep.
+func (p *padded) weightedSum(values, weights anydiff.Res) anydiff.Res {
+	weightTable := make([]int, 0, p.Batch*p.Features*p.Time)
+	for b := 0; b < p.Batch; b++ {
+		for f := 0; f < p.Features; f++ {
+			for t := 0; t < p.Time; t++ {
+				weightTable = append(weightTable, b*p.Time+t)
+			}
+		}
+	}
+	c := p.Creator
+	rows := p.featureRows(values)
+	rowWeights := anydiff.Map(c.MakeMapper(weights.Output().Len(), weightTable), weights)
+	return anydiff.SumCols(&anydiff.Matrix{
+		Data: anydiff.Mul(rows, rowWeights),
+		Rows: p.Batch * p.Features,
+		Cols: p.Time,
+	})
+}
+
+// featureRows rearranges a padded tensor so that each row
+// holds one feature of one sequence over time.
+func (p *padded) featureRows(values anydiff.Res) anydiff.Res {
+	table := make([]int, 0, p.Batch*p.Features*p.Time)
+	for b := 0; b < p.Batch; b++ {
+		for f := 0; f < p.Features; f++ {
+			for t := 0; t < p.Time; t++ {
+				table = append(table, (b*p.Time+t)*p.Features+f)
+			}
+		}
+	}
+	return anydiff.Map(p.Creator.MakeMapper(values.Output().Len(), table), values)
+}
+
+// emptyPool produces the result for a batch in which
+// every sequence is empty.
+func emptyPool(in anyseq.Seq) anydiff.Res {
+	return anydiff.NewConst(in.Creator().MakeVector(0))
+}
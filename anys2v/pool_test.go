@@ -0,0 +1,179 @@
+package anys2v
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anydifftest"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec32"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/serializer"
+)
+
+func TestPoolOutput(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	inSeq := anyseq.ConstSeqList(c, [][]anyvec.Vector{
+		{
+			c.MakeVectorData([]float64{1, -2}),
+		},
+		{},
+		{
+			c.MakeVectorData([]float64{3, 4}),
+			c.MakeVectorData([]float64{-5, 6}),
+			c.MakeVectorData([]float64{2, -8}),
+		},
+	})
+	poolers := map[string]Pooler{
+		"mean": MeanPool{},
+		"max":  MaxPool{},
+		"last": LastPool{},
+	}
+	expected := map[string][]float64{
+		"mean": {1, -2, 0, 0, 0, 2.0 / 3},
+		"max":  {1, -2, 0, 0, 3, 6},
+		"last": {1, -2, 0, 0, 2, -8},
+	}
+	for name, pooler := range poolers {
+		actual := pooler.Pool(inSeq).Output().Data().([]float64)
+		if !vectorsClose(actual, expected[name]) {
+			t.Errorf("%s: expected %v but got %v", name, expected[name], actual)
+		}
+	}
+}
+
+func TestAttentionPoolOutput(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	inSeq := anyseq.ConstSeqList(c, [][]anyvec.Vector{
+		{
+			c.MakeVectorData([]float64{1, 0}),
+			c.MakeVectorData([]float64{0, 1}),
+		},
+		{
+			c.MakeVectorData([]float64{2, 3}),
+		},
+	})
+
+	// The scorer gives the first feature as the score.
+	pool := &AttentionPool{Scorer: firstFeature{}}
+
+	actual := pool.Pool(inSeq).Output().Data().([]float64)
+	w := math.Exp(1) / (math.Exp(1) + 1)
+	expected := []float64{w, 1 - w, 2, 3}
+	if !vectorsClose(actual, expected) {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+}
+
+func TestPoolEmptyBatch(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	inSeq := anyseq.ConstSeqList(c, [][]anyvec.Vector{{}, {}})
+	poolers := map[string]Pooler{
+		"mean":      MeanPool{},
+		"max":       MaxPool{},
+		"last":      LastPool{},
+		"attention": NewAttentionPool(c, 3, 4),
+	}
+	for name, pooler := range poolers {
+		if n := pooler.Pool(inSeq).Output().Len(); n != 0 {
+			t.Errorf("%s: expected empty output but got length %d", name, n)
+		}
+	}
+}
+
+func TestPoolProp(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	poolers := map[string]Pooler{
+		"mean":      MeanPool{},
+		"max":       MaxPool{},
+		"last":      LastPool{},
+		"attention": NewAttentionPool(c, 3, 4),
+	}
+	for name, pooler := range poolers {
+		inSeq, inVars := randomTestSequence(c, 3)
+		if p, ok := pooler.(*AttentionPool); ok {
+			inVars = append(inVars, p.Parameters()...)
+		}
+		checker := &anydifftest.ResChecker{
+			F: func() anydiff.Res {
+				return pooler.Pool(inSeq)
+			},
+			V: inVars,
+		}
+		t.Run(name, checker.FullCheck)
+	}
+}
+
+func TestPoolSerialize(t *testing.T) {
+	c := anyvec32.CurrentCreator()
+	for _, obj := range []serializer.Serializer{
+		MeanPool{},
+		MaxPool{},
+		LastPool{},
+		NewAttentionPool(c, 3, 4),
+	} {
+		data, err := serializer.SerializeWithType(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		newObj, err := serializer.DeserializeWithType(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(obj, newObj) {
+			t.Errorf("expected %v but got %v", obj, newObj)
+		}
+	}
+}
+
+// firstFeature is a layer which selects the first of two
+// features from each vector.
+type firstFeature struct{}
+
+func (f firstFeature) Apply(in anydiff.Res, n int) anydiff.Res {
+	table := make([]int, n)
+	for i := range table {
+		table[i] = i * 2
+	}
+	c := in.Output().Creator()
+	return anydiff.Map(c.MakeMapper(in.Output().Len(), table), in)
+}
+
+func randomTestSequence(c anyvec.Creator, inSize int) (anyseq.Seq, []*anydiff.Var) {
+	inVars := []*anydiff.Var{}
+	inBatches := []*anyseq.ResBatch{}
+
+	presents := [][]bool{{true, true, true}, {true, false, true}, {false, false, true}}
+	numPres := []int{3, 2, 1}
+	chunkLengths := []int{2, 1, 2}
+
+	for chunkIdx, pres := range presents {
+		for i := 0; i < chunkLengths[chunkIdx]; i++ {
+			vec := c.MakeVector(inSize * numPres[chunkIdx])
+			anyvec.Rand(vec, anyvec.Normal, nil)
+			v := anydiff.NewVar(vec)
+			batch := &anyseq.ResBatch{
+				Packed:  v,
+				Present: pres,
+			}
+			inVars = append(inVars, v)
+			inBatches = append(inBatches, batch)
+		}
+	}
+	return anyseq.ResSeq(c, inBatches), inVars
+}
+
+func vectorsClose(actual, expected []float64) bool {
+	if len(actual) != len(expected) {
+		return false
+	}
+	for i, x := range expected {
+		if math.Abs(actual[i]-x) > 1e-5 {
+			return false
+		}
+	}
+	return true
+}
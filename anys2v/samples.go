@@ -13,10 +13,18 @@ import (
 type Sample struct {
 	Input  []anyvec.Vector
 	Output anyvec.Vector
+
+	// Weight scales the cost of the sample.
+	// If it is 0, a weight of 1 is used.
+	Weight float64
 }
 
 // A SampleList is an anysgd.SampleList that produces
 // sequence-to-vector samples.
+//
+// Lists which also implement anysgd.LenSampleList can be
+// wrapped in an anysgd.BucketSampleList to batch together
+// sequences of similar lengths.
 type SampleList interface {
 	anysgd.SampleList
 
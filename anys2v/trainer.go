@@ -2,11 +2,13 @@ package anys2v
 
 import (
 	"errors"
+	"math/rand"
 
 	"github.com/unixpickle/anydiff"
 	"github.com/unixpickle/anydiff/anyseq"
 	"github.com/unixpickle/anynet"
 	"github.com/unixpickle/anynet/anysgd"
+	"github.com/unixpickle/anynet/internal/sampleweight"
 	"github.com/unixpickle/anyvec"
 	"github.com/unixpickle/essentials"
 )
@@ -17,6 +19,14 @@ import (
 type Batch struct {
 	Inputs  anyseq.Seq
 	Outputs *anydiff.Const
+
+	// Weights stores the weight of each sample.
+	// It is nil if every sample has a weight of 1.
+	Weights *anydiff.Const
+
+	// WeightSum is the sum of the components of Weights.
+	// It is only used if Weights is non-nil.
+	WeightSum float64
 }
 
 // A Trainer creates batches, computes gradients, and adds
@@ -26,10 +36,21 @@ type Trainer struct {
 	Cost   anynet.Cost
 	Params []*anydiff.Var
 
+	// Model, if non-nil, holds the layers used by Func,
+	// such as a recurrent block or an AttentionPool.
+	// SetRand passes its source of randomness on to it.
+	Model interface{}
+
 	// Average indicates whether or not the total cost should
 	// be averaged before computing gradients.
 	// This affects gradients, LastCost, and the output of
 	// TotalCost().
+	//
+	// If the samples are weighted, the weighted cost is
+	// divided by the sum of the weights, giving a weighted
+	// average.
+	// Otherwise, the cost is divided by the number of cost
+	// values.
 	Average bool
 
 	// After every gradient computation, LastCost is set to
@@ -47,6 +68,7 @@ func (t *Trainer) Fetch(s anysgd.SampleList) (anysgd.Batch, error) {
 	l := s.(SampleList)
 	ins := make([][]anyvec.Vector, l.Len())
 	outs := make([]anyvec.Vector, l.Len())
+	weights := make([]float64, l.Len())
 	for i := 0; i < l.Len(); i++ {
 		sample, err := l.GetSample(i)
 		if err != nil {
@@ -57,15 +79,21 @@ func (t *Trainer) Fetch(s anysgd.SampleList) (anysgd.Batch, error) {
 		}
 		ins[i] = sample.Input
 		outs[i] = sample.Output
+		weights[i] = sample.Weight
 	}
 	cr := outs[0].Creator()
-	return &Batch{
+	res := &Batch{
 		Inputs:  anyseq.ConstSeqList(cr, ins),
 		Outputs: anydiff.NewConst(cr.Concat(outs...)),
-	}, nil
+	}
+	res.Weights, res.WeightSum = sampleweight.Pack(cr, weights)
+	return res, nil
 }
 
 // TotalCost computes the total cost for the *Batch.
+//
+// If the batch is weighted, the cost of each sample is
+// multiplied by the sample's weight.
 func (t *Trainer) TotalCost(batch anysgd.Batch) anydiff.Res {
 	b := batch.(*Batch)
 	n := 0
@@ -74,6 +102,14 @@ func (t *Trainer) TotalCost(batch anysgd.Batch) anydiff.Res {
 	}
 	outRes := t.Func(b.Inputs)
 	cost := t.Cost.Cost(b.Outputs, outRes, n)
+	if b.Weights != nil {
+		total := anydiff.Sum(anydiff.Mul(cost, b.Weights))
+		if t.Average {
+			divisor := 1 / b.WeightSum
+			return anydiff.Scale(total, total.Output().Creator().MakeNumeric(divisor))
+		}
+		return total
+	}
 	total := anydiff.Sum(cost)
 	if t.Average {
 		divisor := 1 / float64(cost.Output().Len())
@@ -93,3 +129,10 @@ func (t *Trainer) Gradient(b anysgd.Batch) anydiff.Grad {
 	t.LastCost = lc
 	return grad
 }
+
+// SetRand sets the source of randomness for t.Model if
+// it is an anynet.RandSetter.
+// This way, SGD can make dropout reproducible.
+func (t *Trainer) SetRand(r *rand.Rand) {
+	anynet.SetAllRand(r, t.Model)
+}
@@ -0,0 +1,78 @@
+package anys2v
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anysgd"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestTrainerWeights(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	vec := func(x float64) anyvec.Vector {
+		return c.MakeVectorData([]float64{x})
+	}
+	samples := testSampleList{
+		{Input: []anyvec.Vector{vec(5), vec(1)}, Output: vec(0), Weight: 0.5},
+		{Input: []anyvec.Vector{vec(2), vec(4), vec(2)}, Output: vec(0)},
+	}
+	bias := anydiff.NewVar(vec(0))
+	trainer := &Trainer{
+		Func: func(s anyseq.Seq) anydiff.Res {
+			return anydiff.AddRepeated(LastPool{}.Pool(s), bias)
+		},
+		Cost:    anynet.MSE{},
+		Params:  []*anydiff.Var{bias},
+		Average: true,
+	}
+	batch, err := trainer.Fetch(samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each sample is weighted once, regardless of its
+	// length, so the weighted costs of the last timesteps
+	// are 0.5*1 and 1*4.
+	grad := trainer.Gradient(batch)
+	if actual := trainer.LastCost.(float64); math.Abs(actual-3) > 1e-8 {
+		t.Errorf("expected cost 3 but got %f", actual)
+	}
+	expected := (2*0.5*1 + 2*1*2) / 1.5
+	if actual := grad[bias].Data().([]float64)[0]; math.Abs(actual-expected) > 1e-8 {
+		t.Errorf("expected gradient %f but got %f", expected, actual)
+	}
+
+	trainer.Average = false
+	total := anyvec.Sum(trainer.TotalCost(batch).Output()).(float64)
+	if math.Abs(total-4.5) > 1e-8 {
+		t.Errorf("expected total cost 4.5 but got %f", total)
+	}
+
+	samples = append(samples, &Sample{Output: vec(0), Weight: 2})
+	if _, err := trainer.Fetch(samples); err == nil {
+		t.Error("expected error for empty sequence")
+	}
+}
+
+type testSampleList []*Sample
+
+func (t testSampleList) Len() int {
+	return len(t)
+}
+
+func (t testSampleList) Swap(i, j int) {
+	t[i], t[j] = t[j], t[i]
+}
+
+func (t testSampleList) Slice(i, j int) anysgd.SampleList {
+	return append(testSampleList{}, t[i:j]...)
+}
+
+func (t testSampleList) GetSample(idx int) (*Sample, error) {
+	return t[idx], nil
+}
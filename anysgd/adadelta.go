@@ -0,0 +1,121 @@
+package anysgd
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+)
+
+const (
+	adadeltaDefaultDecayRate = 0.95
+	adadeltaDefaultDamping   = 1e-6
+)
+
+// Adadelta implements the technique described in
+// https://arxiv.org/abs/1212.5701.
+//
+// The transformed gradient is
+//
+//     sqrt(E[dx^2] + damping) / sqrt(E[g^2] + damping) * g
+//
+// where E[g^2] is a running average of the squared
+// gradients (including the current one), and E[dx^2] is
+// a running average of the squared transformed gradients
+// from previous steps.
+//
+// Since Adadelta has no learning rate of its own, it is
+// typically used with a constant learning rate of 1.
+type Adadelta struct {
+	// DecayRate is the decay rate for both running
+	// averages.
+	// If it is 0, a default of 0.95 is used.
+	DecayRate float64
+
+	// Damping is used to prevent divisions by zero.
+	// If it is 0, a default of 1e-6 is used.
+	Damping float64
+
+	// Vars is used by the marshalling routines to
+	// assign an ordering to the variables.
+	// It is only used by the MarshalBinary and
+	// UnmarshalBinary methods.
+	Vars []*anydiff.Var
+
+	gradMoment   anydiff.Grad
+	updateMoment anydiff.Grad
+}
+
+// Transform transforms the gradient using Adadelta.
+//
+// This is not thread-safe.
+func (a *Adadelta) Transform(realGrad anydiff.Grad) anydiff.Grad {
+	if a.gradMoment == nil {
+		a.gradMoment = anydiff.Grad{}
+		a.updateMoment = anydiff.Grad{}
+		for v, grad := range realGrad {
+			a.gradMoment[v] = grad.Creator().MakeVector(grad.Len())
+			a.updateMoment[v] = grad.Creator().MakeVector(grad.Len())
+		}
+	}
+	decayRate := valueOrDefault(a.DecayRate, adadeltaDefaultDecayRate)
+	damping := valueOrDefault(a.Damping, adadeltaDefaultDamping)
+	for v, grad := range realGrad {
+		c := grad.Creator()
+		sq := grad.Copy()
+		anyvec.Pow(sq, c.MakeNumeric(2))
+		rollingAverage(a.gradMoment[v], sq, decayRate)
+
+		scale := a.updateMoment[v].Copy()
+		scale.AddScalar(c.MakeNumeric(damping))
+		divisor := a.gradMoment[v].Copy()
+		divisor.AddScalar(c.MakeNumeric(damping))
+		scale.Div(divisor)
+		anyvec.Pow(scale, c.MakeNumeric(0.5))
+		grad.Mul(scale)
+
+		sq = grad.Copy()
+		anyvec.Pow(sq, c.MakeNumeric(2))
+		rollingAverage(a.updateMoment[v], sq, decayRate)
+	}
+	return realGrad
+}
+
+// MarshalBinary marshals the hyperparameters and current
+// state into a binary format.
+//
+// This requires that a.Vars contains all and only the
+// variables contained in gradients passed to Transform.
+// If Transform has never been called, then MarshalBinary
+// will always succeed.
+func (a *Adadelta) MarshalBinary() (data []byte, err error) {
+	defer essentials.AddCtxTo("marshal Adadelta", &err)
+	gradData, err := marshalGradient(a.Vars, a.gradMoment)
+	if err != nil {
+		return nil, err
+	}
+	updateData, err := marshalGradient(a.Vars, a.updateMoment)
+	if err != nil {
+		return nil, err
+	}
+	return serializer.SerializeAny(a.DecayRate, a.Damping, gradData, updateData)
+}
+
+// UnmarshalBinary performs the inverse of MarshalBinary.
+//
+// Like MarshalBinary, this requires a.Vars to be set.
+func (a *Adadelta) UnmarshalBinary(data []byte) (err error) {
+	defer essentials.AddCtxTo("unmarshal Adadelta", &err)
+	var gradData, updateData []byte
+	err = serializer.DeserializeAny(data, &a.DecayRate, &a.Damping, &gradData,
+		&updateData)
+	if err != nil {
+		return
+	}
+	a.gradMoment, err = unmarshalGradient(a.Vars, gradData)
+	if err != nil {
+		return
+	}
+	a.updateMoment, err = unmarshalGradient(a.Vars, updateData)
+	return
+}
@@ -0,0 +1,31 @@
+package anysgd
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestAdadeltaValues(t *testing.T) {
+	var gradMoment, updateMoment []float64
+	a := &Adadelta{DecayRate: 0.9, Damping: 1e-4}
+	testTransformerValues(t, a, func(w, g []float64) []float64 {
+		if gradMoment == nil {
+			gradMoment = make([]float64, len(g))
+			updateMoment = make([]float64, len(g))
+		}
+		res := make([]float64, len(g))
+		for i, x := range g {
+			gradMoment[i] = 0.9*gradMoment[i] + 0.1*x*x
+			res[i] = math.Sqrt((updateMoment[i]+1e-4)/(gradMoment[i]+1e-4)) * x
+			updateMoment[i] = 0.9*updateMoment[i] + 0.1*res[i]*res[i]
+		}
+		return res
+	})
+}
+
+func TestAdadeltaMarshal(t *testing.T) {
+	a := &Adadelta{DecayRate: 0.5, Damping: 0.1, Vars: randomVars(anyvec64.DefaultCreator{})}
+	testMarshal(t, a, a.Vars)
+}
@@ -0,0 +1,84 @@
+package anysgd
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+)
+
+const adagradDefaultDamping = 1e-8
+
+// Adagrad implements the adaptive gradient technique
+// described in http://jmlr.org/papers/v12/duchi11a.html.
+//
+// Each component of the gradient is divided by the square
+// root of the sum of the squares of all the previous
+// values of that component.
+type Adagrad struct {
+	// Damping is used to prevent divisions by zero.
+	// This should be very small.
+	// If it is 0, a default is used.
+	Damping float64
+
+	// Vars is used by the marshalling routines to
+	// assign an ordering to the variables.
+	// It is only used by the MarshalBinary and
+	// UnmarshalBinary methods.
+	Vars []*anydiff.Var
+
+	sumSquares anydiff.Grad
+}
+
+// Transform transforms the gradient using Adagrad.
+//
+// This is not thread-safe.
+func (a *Adagrad) Transform(realGrad anydiff.Grad) anydiff.Grad {
+	if a.sumSquares == nil {
+		a.sumSquares = anydiff.Grad{}
+		for v, grad := range realGrad {
+			a.sumSquares[v] = grad.Creator().MakeVector(grad.Len())
+		}
+	}
+	damping := valueOrDefault(a.Damping, adagradDefaultDamping)
+	for v, grad := range realGrad {
+		sq := grad.Copy()
+		anyvec.Pow(sq, sq.Creator().MakeNumeric(2))
+		a.sumSquares[v].Add(sq)
+
+		divisor := a.sumSquares[v].Copy()
+		divisor.AddScalar(divisor.Creator().MakeNumeric(damping))
+		anyvec.Pow(divisor, divisor.Creator().MakeNumeric(0.5))
+		grad.Div(divisor)
+	}
+	return realGrad
+}
+
+// MarshalBinary marshals the hyperparameters and current
+// state into a binary format.
+//
+// This requires that a.Vars contains all and only the
+// variables contained in gradients passed to Transform.
+// If Transform has never been called, then MarshalBinary
+// will always succeed.
+func (a *Adagrad) MarshalBinary() (data []byte, err error) {
+	defer essentials.AddCtxTo("marshal Adagrad", &err)
+	sumData, err := marshalGradient(a.Vars, a.sumSquares)
+	if err != nil {
+		return nil, err
+	}
+	return serializer.SerializeAny(a.Damping, sumData)
+}
+
+// UnmarshalBinary performs the inverse of MarshalBinary.
+//
+// Like MarshalBinary, this requires a.Vars to be set.
+func (a *Adagrad) UnmarshalBinary(data []byte) (err error) {
+	defer essentials.AddCtxTo("unmarshal Adagrad", &err)
+	var sumData []byte
+	if err = serializer.DeserializeAny(data, &a.Damping, &sumData); err != nil {
+		return
+	}
+	a.sumSquares, err = unmarshalGradient(a.Vars, sumData)
+	return
+}
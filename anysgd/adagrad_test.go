@@ -0,0 +1,28 @@
+package anysgd
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestAdagradValues(t *testing.T) {
+	var sumSquares []float64
+	testTransformerValues(t, &Adagrad{Damping: 1e-4}, func(w, g []float64) []float64 {
+		if sumSquares == nil {
+			sumSquares = make([]float64, len(g))
+		}
+		res := make([]float64, len(g))
+		for i, x := range g {
+			sumSquares[i] += x * x
+			res[i] = x / math.Sqrt(sumSquares[i]+1e-4)
+		}
+		return res
+	})
+}
+
+func TestAdagradMarshal(t *testing.T) {
+	a := &Adagrad{Damping: 0.1, Vars: randomVars(anyvec64.DefaultCreator{})}
+	testMarshal(t, a, a.Vars)
+}
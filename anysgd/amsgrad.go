@@ -0,0 +1,89 @@
+package anysgd
+
+import (
+	"math"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+)
+
+// AMSGrad implements the variant of Adam described in
+// https://openreview.net/forum?id=ryQu7f-RZ.
+//
+// It is like Adam, except that the running maximum of the
+// second moment is used in place of the second moment.
+// With first moment m and maximum second moment vMax, the
+// transformed gradient for step t is
+//
+//     m / (1-b1^t) / sqrt(vMax/(1-b2^t) + damping)
+//
+// The hyperparameters and defaults are the same as for
+// Adam.
+type AMSGrad struct {
+	Adam
+
+	maxMoment anydiff.Grad
+}
+
+// Transform transforms the gradient using AMSGrad.
+//
+// This is not thread-safe.
+func (a *AMSGrad) Transform(realGrad anydiff.Grad) anydiff.Grad {
+	a.updateMoments(copyGrad(realGrad))
+	a.iteration++
+	if a.maxMoment == nil {
+		a.maxMoment = copyGrad(a.secondMoment)
+	} else {
+		for variable, maxVec := range a.maxMoment {
+			diff := a.secondMoment[variable].Copy()
+			diff.Sub(maxVec)
+			anyvec.ClipPos(diff)
+			maxVec.Add(diff)
+		}
+	}
+
+	correction1 := 1 - math.Pow(a.decayRate(1), a.iteration)
+	correction2 := 1 - math.Pow(a.decayRate(2), a.iteration)
+	damping := a.damping()
+	for variable, vec := range realGrad {
+		vec.Set(a.firstMoment[variable])
+		vec.Scale(vec.Creator().MakeNumeric(1 / correction1))
+		vec.Div(adamDivisor(a.maxMoment[variable], correction2, damping))
+	}
+	return realGrad
+}
+
+// MarshalBinary marshals the hyperparameters and current
+// state into a binary format.
+//
+// Like Adam, this requires a.Vars to be set.
+func (a *AMSGrad) MarshalBinary() (data []byte, err error) {
+	defer essentials.AddCtxTo("marshal AMSGrad", &err)
+	adamData, err := a.Adam.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	maxData, err := marshalGradient(a.Vars, a.maxMoment)
+	if err != nil {
+		return nil, err
+	}
+	return serializer.SerializeAny(adamData, maxData)
+}
+
+// UnmarshalBinary performs the inverse of MarshalBinary.
+//
+// Like MarshalBinary, this requires a.Vars to be set.
+func (a *AMSGrad) UnmarshalBinary(data []byte) (err error) {
+	defer essentials.AddCtxTo("unmarshal AMSGrad", &err)
+	var adamData, maxData []byte
+	if err = serializer.DeserializeAny(data, &adamData, &maxData); err != nil {
+		return
+	}
+	if err = a.Adam.UnmarshalBinary(adamData); err != nil {
+		return
+	}
+	a.maxMoment, err = unmarshalGradient(a.Vars, maxData)
+	return
+}
@@ -0,0 +1,36 @@
+package anysgd
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestAMSGradValues(t *testing.T) {
+	var m, v, vMax []float64
+	var step float64
+	a := &AMSGrad{Adam: Adam{DecayRate1: 0.9, DecayRate2: 0.5, Damping: 1e-8}}
+	testTransformerValues(t, a, func(w, g []float64) []float64 {
+		if m == nil {
+			m = make([]float64, len(g))
+			v = make([]float64, len(g))
+			vMax = make([]float64, len(g))
+		}
+		step++
+		res := make([]float64, len(g))
+		for i, x := range g {
+			m[i] = 0.9*m[i] + 0.1*x
+			v[i] = 0.5*v[i] + 0.5*x*x
+			vMax[i] = math.Max(vMax[i], v[i])
+			res[i] = m[i] / (1 - math.Pow(0.9, step)) /
+				math.Sqrt(vMax[i]/(1-math.Pow(0.5, step))+1e-8)
+		}
+		return res
+	})
+}
+
+func TestAMSGradMarshal(t *testing.T) {
+	a := &AMSGrad{Adam: Adam{Damping: 0.2, Vars: randomVars(anyvec64.DefaultCreator{})}}
+	testMarshal(t, a, a.Vars)
+}
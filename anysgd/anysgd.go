@@ -4,7 +4,11 @@
 // can be applied to other areas as well.
 package anysgd
 
-import "github.com/unixpickle/anydiff"
+import (
+	"math/rand"
+
+	"github.com/unixpickle/anydiff"
+)
 
 // SGD performs stochastic gradient descent.
 type SGD struct {
@@ -33,12 +37,32 @@ type SGD struct {
 	// BatchSize is the mini-batch size.
 	// If it is 0, then the entire sample list is used at
 	// every iteration.
+	//
+	// If Samples implements BatchSizer, then BatchSize is
+	// ignored.
 	BatchSize int
 
 	// StatusFunc, if non-nil, is called before every
 	// iteration with the next mini-batch.
 	StatusFunc func(batch Batch)
 
+	// Rand, if non-nil, is the source of randomness for
+	// shuffling the samples.
+	//
+	// If the Gradienter has a SetRand(*rand.Rand) method
+	// (like the trainers in anyff), it is also given a
+	// source of randomness, seeded from Rand, every time
+	// Run or RunAvg is called.
+	// This way, seeding Rand (and the initialization of
+	// the model) makes training reproducible.
+	Rand *rand.Rand
+
+	// Recorder, if non-nil, records metrics after every
+	// mini-batch.
+	// The recorded gradient norm is computed before the
+	// gradient is transformed.
+	Recorder *Recorder
+
 	// NumProcessed keeps track of the number of samples that
 	// have been passed to Gradienter so far.
 	// It is used to compute the epoch for Rater.
@@ -47,7 +71,7 @@ type SGD struct {
 }
 
 // Run runs SGD until doneChan is closed or the fetcher
-// returns an error.
+// (or the Recorder) returns an error.
 //
 // Run is not thread-safe, and you should never modify the
 // struct's fields while Run is active.
@@ -107,19 +131,21 @@ func (s *SGD) RunAvg(n int, doneChan <-chan struct{}) error {
 	})
 }
 
-func (s *SGD) batchSize(remaining int) int {
-	if s.BatchSize == 0 || s.BatchSize > remaining {
-		return remaining
-	} else {
-		return s.BatchSize
-	}
-}
-
 func (s *SGD) streamGradients(doneChan <-chan struct{}, f func(anydiff.Grad)) error {
 	if s.Samples.Len() == 0 {
 		panic("cannot run SGD with empty sample list")
 	}
 
+	// Use separate sources for each Goroutine so that
+	// the random numbers do not depend on timing.
+	var shuffleRand *rand.Rand
+	if s.Rand != nil {
+		shuffleRand = rand.New(rand.NewSource(s.Rand.Int63()))
+		if r, ok := s.Gradienter.(randSetter); ok {
+			r.SetRand(rand.New(rand.NewSource(s.Rand.Int63())))
+		}
+	}
+
 	errChan := make(chan error, 1)
 	batchChan := make(chan *batchInfo)
 
@@ -131,13 +157,11 @@ func (s *SGD) streamGradients(doneChan <-chan struct{}, f func(anydiff.Grad)) er
 				return
 			default:
 			}
-			remaining := s.Samples.Len() - idx
-			if remaining == 0 {
-				Shuffle(s.Samples)
+			if idx == s.Samples.Len() {
+				ShuffleRand(s.Samples, shuffleRand)
 				idx = 0
-				remaining = s.Samples.Len()
 			}
-			batchSize := s.batchSize(remaining)
+			batchSize := batchSizeAt(s.Samples, idx, s.BatchSize)
 			batchSlice := s.Samples.Slice(idx, idx+batchSize)
 			idx += batchSize
 			batch, err := s.Fetcher.Fetch(batchSlice)
@@ -181,7 +205,18 @@ func (s *SGD) streamGradients(doneChan <-chan struct{}, f func(anydiff.Grad)) er
 		s.NumProcessed += info.Size
 
 		grad := s.Gradienter.Gradient(info.Batch)
+		if s.Recorder == nil {
+			f(grad)
+			continue
+		}
+		norm, err := gradNorm(grad)
+		if err != nil {
+			return err
+		}
 		f(grad)
+		if err := s.Recorder.recordStep(s, norm); err != nil {
+			return err
+		}
 	}
 }
 
@@ -189,6 +224,24 @@ func (s *SGD) epoch() float64 {
 	return float64(s.NumProcessed) / float64(s.Samples.Len())
 }
 
+// batchSizeAt computes the size of the mini-batch that
+// starts at index idx of s.
+func batchSizeAt(s SampleList, idx, batchSize int) int {
+	if b, ok := s.(BatchSizer); ok {
+		batchSize = b.BatchSizeAt(idx)
+	}
+	remaining := s.Len() - idx
+	if batchSize <= 0 || batchSize > remaining {
+		return remaining
+	} else {
+		return batchSize
+	}
+}
+
+type randSetter interface {
+	SetRand(r *rand.Rand)
+}
+
 type batchInfo struct {
 	Batch Batch
 	Size  int
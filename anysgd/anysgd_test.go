@@ -2,10 +2,13 @@ package anysgd
 
 import (
 	"math"
+	"math/rand"
+	"reflect"
 	"testing"
 
 	"github.com/unixpickle/anydiff"
 	"github.com/unixpickle/anyvec/anyvec32"
+	"github.com/unixpickle/anyvec/anyvec64"
 )
 
 type testSample struct {
@@ -151,3 +154,79 @@ func TestSGD(t *testing.T) {
 		t.Errorf("bad solution: %f, %f", x, y)
 	}
 }
+
+func TestSGDRand(t *testing.T) {
+	run := func() ([]*testSample, float64, float64, *rand.Rand) {
+		stop := newTestStopper(50)
+		g := &randTestGradienter{testGradienter: newTestGradienter()}
+		var order []*testSample
+		s := &SGD{
+			Fetcher:    testFetcher{},
+			Gradienter: g,
+			Samples:    newTestSampleList(),
+			Rater:      ConstRater(0.01),
+			StatusFunc: func(b Batch) {
+				order = append(order, b.(testSampleList)[0])
+				stop.StatusFunc(b)
+			},
+			BatchSize: 1,
+			Rand:      rand.New(rand.NewSource(1337)),
+		}
+		s.Run(stop.Chan())
+		x, y := g.current()
+		return order, x, y, g.Rand
+	}
+	order1, x1, y1, r1 := run()
+	order2, x2, y2, r2 := run()
+	if r1 == nil || r2 == nil {
+		t.Fatal("Gradienter was not given a source of randomness")
+	}
+	if r1.Int63() != r2.Int63() {
+		t.Error("Gradienter sources differ")
+	}
+	if x1 != x2 || y1 != y2 {
+		t.Errorf("results differ: (%f, %f) vs (%f, %f)", x1, y1, x2, y2)
+	}
+	if len(order1) != len(order2) {
+		t.Fatalf("batch counts differ: %d vs %d", len(order1), len(order2))
+	}
+	for i, sample := range order1 {
+		if !reflect.DeepEqual(sample, order2[i]) {
+			t.Errorf("batch %d differs", i)
+			break
+		}
+	}
+}
+
+type randTestGradienter struct {
+	*testGradienter
+	Rand *rand.Rand
+}
+
+func (r *randTestGradienter) SetRand(rng *rand.Rand) {
+	r.Rand = rng
+}
+
+// testTransformerValues runs a Transformer on a fixed
+// sequence of gradients and compares its outputs to the
+// outputs of a reference implementation.
+//
+// The reference receives the parameter values and the
+// gradient at every step.
+func testTransformerValues(t *testing.T, tr Transformer,
+	reference func(w, g []float64) []float64) {
+	c := anyvec64.DefaultCreator{}
+	params := []float64{0.5, -1, 2}
+	v := anydiff.NewVar(c.MakeVectorData(append([]float64{}, params...)))
+	inputs := [][]float64{{1, -2, 0.5}, {2, 1, -0.3}, {-0.5, 0.7, 0.1}, {0.1, 0.1, 3}}
+	for i, input := range inputs {
+		expected := reference(params, input)
+		g := anydiff.Grad{v: c.MakeVectorData(append([]float64{}, input...))}
+		actual := tr.Transform(g)[v].Data().([]float64)
+		for j, x := range expected {
+			if math.IsNaN(actual[j]) || math.Abs(actual[j]-x) > 1e-6 {
+				t.Errorf("step %d out %d: expected %f, got %f", i, j, x, actual[j])
+			}
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package anysgd
+
+import (
+	"errors"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+)
+
+const averagerDefaultDecay = 0.999
+
+// An Averager keeps running averages of parameters, which
+// often perform better than the parameters themselves.
+//
+// By default, an exponential moving average is used.
+// If SWA is set, an equally-weighted average of parameter
+// snapshots is used instead, as in stochastic weight
+// averaging (https://arxiv.org/abs/1803.05407).
+//
+// Update should be called after every training step.
+// With SGD, Update can be called from SGD.StatusFunc.
+// The StatusFunc is called before every iteration with
+// the upcoming batch, so each call comes after the
+// previous step (and the first call comes before any
+// steps, so it records the initial parameters).
+//
+// To evaluate the averaged parameters, call SwapIn,
+// evaluate the model, and then call SwapOut.
+type Averager struct {
+	Params []*anydiff.Var
+
+	// Decay is the decay rate for the exponential moving
+	// average.
+	// If it is 0, a default of 0.999 is used.
+	Decay float64
+
+	// SWA indicates that an equally-weighted average
+	// should be used instead of an exponential moving
+	// average.
+	SWA bool
+
+	// Warmup is the number of updates before averaging
+	// starts.
+	// During the warmup, the averages are equal to the
+	// parameters.
+	Warmup int
+
+	// Interval is the number of updates between snapshots
+	// in SWA mode.
+	// If it is 0, a snapshot is taken at every update.
+	Interval int
+
+	numUpdates   int
+	numSnapshots int
+	averages     anydiff.Grad
+	live         anydiff.Grad
+}
+
+// Update updates the averages using the current values of
+// the parameters.
+//
+// Update may not be called while the averages are swapped
+// in.
+func (a *Averager) Update() {
+	if a.live != nil {
+		panic("cannot update while averages are swapped in")
+	}
+	a.numUpdates++
+	if a.averages == nil || a.numUpdates <= a.Warmup {
+		a.averages = anydiff.Grad{}
+		for _, p := range a.Params {
+			a.averages[p] = p.Vector.Copy()
+		}
+		return
+	}
+	if a.SWA {
+		interval := a.Interval
+		if interval == 0 {
+			interval = 1
+		}
+		if (a.numUpdates-a.Warmup)%interval != 0 {
+			return
+		}
+		a.numSnapshots++
+		a.addToAverages(1 / float64(a.numSnapshots+1))
+	} else {
+		a.addToAverages(1 - valueOrDefault(a.Decay, averagerDefaultDecay))
+	}
+}
+
+// Averages returns the current averages, in the same
+// order as the parameters.
+//
+// The result is nil if Update has never been called.
+func (a *Averager) Averages() []anyvec.Vector {
+	if a.averages == nil {
+		return nil
+	}
+	res := make([]anyvec.Vector, len(a.Params))
+	for i, p := range a.Params {
+		res[i] = a.averages[p]
+	}
+	return res
+}
+
+// SwapIn replaces the parameters with their averages.
+// The original values are saved until SwapOut is called.
+//
+// SwapIn has no effect if Update has never been called.
+func (a *Averager) SwapIn() {
+	if a.live != nil {
+		panic("averages are already swapped in")
+	}
+	if a.averages == nil {
+		return
+	}
+	a.live = anydiff.Grad{}
+	for _, p := range a.Params {
+		a.live[p] = p.Vector.Copy()
+		p.Vector.Set(a.averages[p])
+	}
+}
+
+// SwapOut restores the parameters that were replaced by
+// SwapIn.
+func (a *Averager) SwapOut() {
+	if a.live == nil {
+		return
+	}
+	for _, p := range a.Params {
+		p.Vector.Set(a.live[p])
+	}
+	a.live = nil
+}
+
+// MarshalBinary marshals the hyperparameters and current
+// averages into a binary format.
+//
+// The averages may not be swapped in.
+func (a *Averager) MarshalBinary() (data []byte, err error) {
+	defer essentials.AddCtxTo("marshal Averager", &err)
+	if a.live != nil {
+		return nil, errors.New("averages are swapped in")
+	}
+	averageData, err := marshalGradient(a.Params, a.averages)
+	if err != nil {
+		return nil, err
+	}
+	return serializer.SerializeAny(a.Decay, a.SWA, a.Warmup, a.Interval,
+		a.numUpdates, a.numSnapshots, averageData)
+}
+
+// UnmarshalBinary performs the inverse of MarshalBinary.
+//
+// This requires a.Params to be set.
+func (a *Averager) UnmarshalBinary(data []byte) (err error) {
+	defer essentials.AddCtxTo("unmarshal Averager", &err)
+	var averageData []byte
+	err = serializer.DeserializeAny(data, &a.Decay, &a.SWA, &a.Warmup,
+		&a.Interval, &a.numUpdates, &a.numSnapshots, &averageData)
+	if err != nil {
+		return
+	}
+	a.live = nil
+	a.averages, err = unmarshalGradient(a.Params, averageData)
+	return
+}
+
+// addToAverages moves each average towards the current
+// parameter by the given fraction of the difference.
+func (a *Averager) addToAverages(frac float64) {
+	for _, p := range a.Params {
+		avg := a.averages[p]
+		diff := p.Vector.Copy()
+		diff.Sub(avg)
+		diff.Scale(diff.Creator().MakeNumeric(frac))
+		avg.Add(diff)
+	}
+}
@@ -0,0 +1,112 @@
+package anysgd
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestAveragerEMA(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	param := anydiff.NewVar(c.MakeVectorData([]float64{1, 2}))
+	a := &Averager{Params: []*anydiff.Var{param}, Decay: 0.5, Warmup: 2}
+
+	values := [][]float64{{5, 5}, {3, 4}, {1, 0}, {-1, 2}}
+	expected := [][]float64{{5, 5}, {3, 4}, {2, 2}, {0.5, 2}}
+	for i, value := range values {
+		param.Vector.SetData(value)
+		a.Update()
+		actual := a.Averages()[0].Data().([]float64)
+		if !slicesClose(actual, expected[i]) {
+			t.Errorf("update %d: expected %v but got %v", i, expected[i], actual)
+		}
+	}
+}
+
+func TestAveragerSWA(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	param := anydiff.NewVar(c.MakeVectorData([]float64{0}))
+	a := &Averager{Params: []*anydiff.Var{param}, SWA: true, Warmup: 1, Interval: 2}
+
+	values := []float64{100, 1, 2, 3, 4, 5}
+	expected := []float64{100, 100, 51, 51, 106.0 / 3, 106.0 / 3}
+	for i, value := range values {
+		param.Vector.SetData([]float64{value})
+		a.Update()
+		actual := a.Averages()[0].Data().([]float64)[0]
+		if math.Abs(actual-expected[i]) > 1e-8 {
+			t.Errorf("update %d: expected %f but got %f", i, expected[i], actual)
+		}
+	}
+}
+
+func TestAveragerSwap(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	param := anydiff.NewVar(c.MakeVectorData([]float64{1, 2}))
+	a := &Averager{Params: []*anydiff.Var{param}, SWA: true}
+	a.Update()
+	param.Vector.SetData([]float64{3, 6})
+	a.Update()
+
+	a.SwapIn()
+	if actual := param.Vector.Data().([]float64); !slicesClose(actual, []float64{2, 4}) {
+		t.Errorf("swapped in: expected [2 4] but got %v", actual)
+	}
+	a.SwapOut()
+	if actual := param.Vector.Data().([]float64); !slicesClose(actual, []float64{3, 6}) {
+		t.Errorf("swapped out: expected [3 6] but got %v", actual)
+	}
+}
+
+func TestAveragerMarshal(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	vars := randomVars(c)
+	a := &Averager{Params: vars, Decay: 0.9, Warmup: 1}
+	for i := 0; i < 3; i++ {
+		randomizeVars(vars)
+		a.Update()
+	}
+
+	data, err := a.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a1 := &Averager{Params: vars}
+	if err := a1.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if a1.Decay != a.Decay || a1.Warmup != a.Warmup || a1.numUpdates != a.numUpdates {
+		t.Errorf("hyperparameter mismatch: %+v vs %+v", a1, a)
+	}
+
+	randomizeVars(vars)
+	a.Update()
+	a1.Update()
+	for i, avg := range a.Averages() {
+		expected := avg.Data().([]float64)
+		actual := a1.Averages()[i].Data().([]float64)
+		if !slicesClose(actual, expected) {
+			t.Errorf("average %d: expected %v but got %v", i, expected, actual)
+		}
+	}
+}
+
+func slicesClose(actual, expected []float64) bool {
+	if len(actual) != len(expected) {
+		return false
+	}
+	for i, x := range expected {
+		if math.Abs(actual[i]-x) > 1e-8 {
+			return false
+		}
+	}
+	return true
+}
+
+func randomizeVars(vars []*anydiff.Var) {
+	for v, vec := range randomGrad(vars) {
+		v.Vector.Set(vec)
+	}
+}
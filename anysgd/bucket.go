@@ -0,0 +1,189 @@
+package anysgd
+
+import (
+	"math/rand"
+	"sort"
+
+	"github.com/unixpickle/anynet/internal/randutil"
+)
+
+// A LenSampleList is a SampleList with an extra LenAt
+// method for efficiently getting the length of a sample,
+// such as the number of timesteps in an input sequence.
+type LenSampleList interface {
+	SampleList
+
+	LenAt(idx int) int
+}
+
+// A BucketSampleList wraps a LenSampleList and arranges
+// samples so that every mini-batch contains samples of
+// similar lengths.
+// This reduces the amount of padding in batches of
+// sequences.
+//
+// Every time the list is shuffled, the samples are
+// grouped into length buckets, sorted by length within
+// each bucket, and split up into mini-batches.
+// Mini-batches never span multiple buckets.
+// Finally, the mini-batches are put in a random order.
+//
+// SGD and LRFinder use BatchSizeAt to find the
+// mini-batches, so the BatchSize fields of those types
+// are ignored in favor of the fields of this type.
+//
+// Slicing a BucketSampleList yields a slice of the
+// wrapped list, so Fetchers see the original list type.
+type BucketSampleList struct {
+	LenSampleList
+
+	// BucketWidth is the range of lengths in each bucket.
+	// For example, if it is 10, then lengths 0 through 9
+	// form the first bucket.
+	//
+	// If it is 0, then all the samples are put in one
+	// bucket, so each mini-batch is a run of samples from
+	// the sorted list.
+	BucketWidth int
+
+	// BatchSize is the maximum number of samples per
+	// mini-batch.
+	// If it is 0, only MaxTokens limits the batch size.
+	// If both BatchSize and MaxTokens are 0, then every
+	// bucket is a single mini-batch.
+	BatchSize int
+
+	// MaxTokens, if non-zero, is the maximum number of
+	// tokens per mini-batch, where the number of tokens is
+	// the number of samples times the longest length in
+	// the batch.
+	// A sample that is longer than MaxTokens is put in a
+	// batch by itself.
+	MaxTokens int
+
+	batchSizes map[int]int
+}
+
+// Slice produces a slice of the wrapped list.
+func (b *BucketSampleList) Slice(i, j int) SampleList {
+	return b.LenSampleList.Slice(i, j)
+}
+
+// PostShuffle arranges the samples into mini-batches.
+func (b *BucketSampleList) PostShuffle() {
+	b.PostShuffleRand(nil)
+}
+
+// PostShuffleRand is like PostShuffle, but it uses the
+// given source of randomness to order the mini-batches.
+func (b *BucketSampleList) PostShuffleRand(r *rand.Rand) {
+	if p, ok := b.LenSampleList.(RandPostShuffler); ok {
+		p.PostShuffleRand(r)
+	} else if p, ok := b.LenSampleList.(PostShuffler); ok {
+		p.PostShuffle()
+	}
+
+	lengths := make([]int, b.Len())
+	order := make([]int, b.Len())
+	for i := range order {
+		lengths[i] = b.LenAt(i)
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		l1, l2 := lengths[order[i]], lengths[order[j]]
+		if b1, b2 := b.bucket(l1), b.bucket(l2); b1 != b2 {
+			return b1 < b2
+		}
+		return l1 < l2
+	})
+
+	var batches [][]int
+	var batch []int
+	var maxLen int
+	for _, idx := range order {
+		length := lengths[idx]
+		if len(batch) > 0 && !b.fits(batch, maxLen, idx, lengths) {
+			batches = append(batches, batch)
+			batch = nil
+			maxLen = 0
+		}
+		batch = append(batch, idx)
+		if length > maxLen {
+			maxLen = length
+		}
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+
+	order = order[:0]
+	b.batchSizes = map[int]int{}
+	for _, i := range randutil.Perm(r, len(batches)) {
+		b.batchSizes[len(order)] = len(batches[i])
+		order = append(order, batches[i]...)
+	}
+	permuteSamples(b.LenSampleList, order)
+}
+
+// BatchSizeAt returns the size of the mini-batch that
+// starts at the given index.
+//
+// If the list has not been shuffled, or if idx is not the
+// start of a mini-batch, then BatchSize is returned (or
+// the length of the list if BatchSize is 0).
+func (b *BucketSampleList) BatchSizeAt(idx int) int {
+	if size, ok := b.batchSizes[idx]; ok {
+		return size
+	} else if b.BatchSize != 0 {
+		return b.BatchSize
+	}
+	return b.Len()
+}
+
+func (b *BucketSampleList) bucket(length int) int {
+	if b.BucketWidth == 0 {
+		return 0
+	}
+	return length / b.BucketWidth
+}
+
+func (b *BucketSampleList) fits(batch []int, maxLen, idx int, lengths []int) bool {
+	if b.bucket(lengths[idx]) != b.bucket(lengths[batch[0]]) {
+		return false
+	}
+	if b.BatchSize != 0 && len(batch) >= b.BatchSize {
+		return false
+	}
+	if b.MaxTokens != 0 {
+		if lengths[idx] > maxLen {
+			maxLen = lengths[idx]
+		}
+		if (len(batch)+1)*maxLen > b.MaxTokens {
+			return false
+		}
+	}
+	return true
+}
+
+// permuteSamples re-orders s so that the sample at index
+// i is the sample that was originally at index order[i].
+func permuteSamples(s SampleList, order []int) {
+	// position[j] is the current index of sample j, and
+	// sample[i] is the sample currently at index i.
+	position := make([]int, len(order))
+	sample := make([]int, len(order))
+	for i := range order {
+		position[i] = i
+		sample[i] = i
+	}
+	for i, j := range order {
+		src := position[j]
+		if src == i {
+			continue
+		}
+		s.Swap(i, src)
+		displaced := sample[i]
+		sample[i], sample[src] = j, displaced
+		position[j], position[displaced] = i, src
+	}
+}
@@ -0,0 +1,93 @@
+package anysgd
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestBucketSampleList(t *testing.T) {
+	lengths := make(testLenList, 500)
+	for i := range lengths {
+		lengths[i] = rand.Intn(50) + 1
+	}
+	original := append([]int{}, lengths...)
+	sort.Ints(original)
+	list := &BucketSampleList{
+		LenSampleList: lengths,
+		BucketWidth:   10,
+		BatchSize:     8,
+		MaxTokens:     200,
+	}
+	for epoch := 0; epoch < 2; epoch++ {
+		Shuffle(list)
+
+		sorted := append([]int{}, lengths...)
+		sort.Ints(sorted)
+		for i, x := range sorted {
+			if x != original[i] {
+				t.Fatal("samples were lost or duplicated")
+			}
+		}
+
+		var numBatches int
+		for idx := 0; idx < list.Len(); {
+			size := list.BatchSizeAt(idx)
+			if size < 1 || size > 8 {
+				t.Fatalf("bad batch size: %d", size)
+			}
+			batch := list.Slice(idx, idx+size).(testLenList)
+			maxLen := batch[len(batch)-1]
+			if size > 1 && size*maxLen > 200 {
+				t.Errorf("batch exceeds token limit: %v", batch)
+			}
+			for i, x := range batch {
+				if x/10 != batch[0]/10 {
+					t.Errorf("batch spans buckets: %v", batch)
+				}
+				if i > 0 && x < batch[i-1] {
+					t.Errorf("batch is not sorted: %v", batch)
+				}
+			}
+			idx += size
+			numBatches++
+		}
+		if numBatches < 500/8 {
+			t.Errorf("too few batches: %d", numBatches)
+		}
+	}
+}
+
+func TestPermuteSamples(t *testing.T) {
+	for trial := 0; trial < 10; trial++ {
+		list := make(testLenList, 20)
+		for i := range list {
+			list[i] = i
+		}
+		order := rand.Perm(len(list))
+		permuteSamples(list, order)
+		for i, x := range list {
+			if x != order[i] {
+				t.Fatalf("expected %v but got %v", order, list)
+			}
+		}
+	}
+}
+
+type testLenList []int
+
+func (t testLenList) Len() int {
+	return len(t)
+}
+
+func (t testLenList) Swap(i, j int) {
+	t[i], t[j] = t[j], t[i]
+}
+
+func (t testLenList) Slice(i, j int) SampleList {
+	return append(testLenList{}, t[i:j]...)
+}
+
+func (t testLenList) LenAt(idx int) int {
+	return t[idx]
+}
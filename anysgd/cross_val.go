@@ -0,0 +1,98 @@
+package anysgd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/unixpickle/essentials"
+)
+
+// A FoldModel is a model that is trained and evaluated on
+// a single fold of cross-validation.
+type FoldModel interface {
+	// Train trains the model on the samples.
+	Train(s SampleList) error
+
+	// Evaluate computes named validation metrics (such as
+	// "cost" or "accuracy") on the samples.
+	Evaluate(s SampleList) (map[string]float64, error)
+}
+
+// A CrossValidator runs k-fold cross-validation.
+type CrossValidator struct {
+	// Samples is split up into folds using KFold or
+	// StratifiedKFold.
+	Samples Hasher
+
+	// NumFolds is the number of folds.
+	NumFolds int
+
+	// Label, if non-nil, is used to stratify the folds.
+	// See StratifiedSplit for details.
+	Label func(i int) string
+
+	// NewModel creates a fresh, untrained model.
+	// It is called once per fold.
+	NewModel func(fold int) (FoldModel, error)
+}
+
+// CrossValResult stores the validation metrics from
+// every fold of cross-validation.
+type CrossValResult struct {
+	Folds []map[string]float64
+}
+
+// Mean computes the mean of a metric across folds.
+func (c *CrossValResult) Mean(metric string) float64 {
+	var sum float64
+	for _, f := range c.Folds {
+		sum += f[metric]
+	}
+	return sum / float64(len(c.Folds))
+}
+
+// Stddev computes the standard deviation of a metric
+// across folds.
+func (c *CrossValResult) Stddev(metric string) float64 {
+	mean := c.Mean(metric)
+	var sum float64
+	for _, f := range c.Folds {
+		sum += math.Pow(f[metric]-mean, 2)
+	}
+	return math.Sqrt(sum / float64(len(c.Folds)))
+}
+
+// Run trains and evaluates a model for every fold.
+func (c *CrossValidator) Run() (res *CrossValResult, err error) {
+	defer essentials.AddCtxTo("cross-validation", &err)
+	if c.NumFolds < 2 {
+		return nil, errors.New("need at least two folds")
+	}
+	var folds []*Fold
+	if c.Label != nil {
+		folds = StratifiedKFold(c.Samples, c.NumFolds, c.Label)
+	} else {
+		folds = KFold(c.Samples, c.NumFolds)
+	}
+	res = &CrossValResult{}
+	for i, fold := range folds {
+		metrics, err := c.runFold(i, fold)
+		if err != nil {
+			return nil, essentials.AddCtx(fmt.Sprintf("fold %d", i), err)
+		}
+		res.Folds = append(res.Folds, metrics)
+	}
+	return res, nil
+}
+
+func (c *CrossValidator) runFold(idx int, fold *Fold) (map[string]float64, error) {
+	model, err := c.NewModel(idx)
+	if err != nil {
+		return nil, err
+	}
+	if err := model.Train(fold.Train); err != nil {
+		return nil, err
+	}
+	return model.Evaluate(fold.Validation)
+}
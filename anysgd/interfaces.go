@@ -2,6 +2,7 @@ package anysgd
 
 import (
 	"encoding"
+	"math/rand"
 
 	"github.com/unixpickle/anydiff"
 )
@@ -99,6 +100,32 @@ type PostShuffler interface {
 	PostShuffle()
 }
 
+// A RandPostShuffler is a PostShuffler that uses random
+// numbers, allowing ShuffleRand to provide the source of
+// randomness.
+//
+// A nil source indicates that the global source from
+// math/rand should be used.
+type RandPostShuffler interface {
+	PostShuffler
+
+	PostShuffleRand(r *rand.Rand)
+}
+
+// A BatchSizer is a SampleList that decides how it
+// should be split up into mini-batches.
+//
+// After the list is shuffled, the first mini-batch
+// starts at index 0, and every other mini-batch starts
+// where the previous one ends.
+type BatchSizer interface {
+	SampleList
+
+	// BatchSizeAt returns the size of the mini-batch that
+	// starts at the given index.
+	BatchSizeAt(idx int) int
+}
+
 // A Coster computes differentiable costs for a Batch.
 // The resulting cost vectors should have one component.
 type Coster interface {
@@ -0,0 +1,94 @@
+package anysgd
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+)
+
+// LAMB implements the layer-wise adaptive optimizer
+// described in https://arxiv.org/abs/1904.00962.
+//
+// For each variable w, the Adam update r is computed and
+// weight decay is added to it.
+// The result is then scaled by the trust ratio
+//
+//     ||w|| / ||r + WeightDecay*w||
+//
+// so that the size of each variable's step is proportional
+// to the size of the variable.
+// If either norm is zero, the trust ratio is 1.
+//
+// The remaining hyperparameters and defaults are the same
+// as for Adam.
+type LAMB struct {
+	Adam
+
+	// WeightDecay is the coefficient for decoupled weight
+	// decay.
+	WeightDecay float64
+}
+
+// Transform transforms the gradient using LAMB.
+//
+// This is not thread-safe.
+func (l *LAMB) Transform(realGrad anydiff.Grad) anydiff.Grad {
+	l.Adam.Transform(realGrad)
+	for variable, vec := range realGrad {
+		if l.WeightDecay != 0 {
+			decay := variable.Vector.Copy()
+			decay.Scale(decay.Creator().MakeNumeric(l.WeightDecay))
+			vec.Add(decay)
+		}
+		vec.Scale(vec.Creator().MakeNumeric(trustRatio(variable.Vector, vec)))
+	}
+	return realGrad
+}
+
+// MarshalBinary marshals the hyperparameters and current
+// state into a binary format.
+//
+// Like Adam, this requires l.Vars to be set.
+func (l *LAMB) MarshalBinary() (data []byte, err error) {
+	defer essentials.AddCtxTo("marshal LAMB", &err)
+	adamData, err := l.Adam.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return serializer.SerializeAny(adamData, l.WeightDecay)
+}
+
+// UnmarshalBinary performs the inverse of MarshalBinary.
+//
+// Like MarshalBinary, this requires l.Vars to be set.
+func (l *LAMB) UnmarshalBinary(data []byte) (err error) {
+	defer essentials.AddCtxTo("unmarshal LAMB", &err)
+	var adamData []byte
+	if err = serializer.DeserializeAny(data, &adamData, &l.WeightDecay); err != nil {
+		return
+	}
+	return l.Adam.UnmarshalBinary(adamData)
+}
+
+// trustRatio computes ||param|| / ||update||, or 1 if
+// either norm is zero.
+func trustRatio(param, update anyvec.Vector) float64 {
+	paramNorm := vectorNorm(param)
+	updateNorm := vectorNorm(update)
+	if paramNorm == 0 || updateNorm == 0 {
+		return 1
+	}
+	return paramNorm / updateNorm
+}
+
+func vectorNorm(v anyvec.Vector) float64 {
+	if v.Len() == 0 {
+		return 0
+	}
+	norm, err := numericFloat(anyvec.Norm(v))
+	if err != nil {
+		panic(err)
+	}
+	return norm
+}
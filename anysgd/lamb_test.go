@@ -0,0 +1,52 @@
+package anysgd
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestLAMBValues(t *testing.T) {
+	var m, v []float64
+	var step float64
+	l := &LAMB{
+		Adam:        Adam{DecayRate1: 0.9, DecayRate2: 0.99, Damping: 1e-8},
+		WeightDecay: 0.1,
+	}
+	testTransformerValues(t, l, func(w, g []float64) []float64 {
+		if m == nil {
+			m = make([]float64, len(g))
+			v = make([]float64, len(g))
+		}
+		step++
+		scale := math.Sqrt(1-math.Pow(0.99, step)) / (1 - math.Pow(0.9, step))
+		res := make([]float64, len(g))
+		for i, x := range g {
+			m[i] = 0.9*m[i] + 0.1*x
+			v[i] = 0.99*v[i] + 0.01*x*x
+			res[i] = scale*m[i]/math.Sqrt(v[i]+1e-8) + 0.1*w[i]
+		}
+		ratio := euclideanNorm(w) / euclideanNorm(res)
+		for i := range res {
+			res[i] *= ratio
+		}
+		return res
+	})
+}
+
+func TestLAMBMarshal(t *testing.T) {
+	l := &LAMB{
+		Adam:        Adam{DecayRate2: 0.4, Vars: randomVars(anyvec64.DefaultCreator{})},
+		WeightDecay: 0.01,
+	}
+	testMarshal(t, l, l.Vars)
+}
+
+func euclideanNorm(v []float64) float64 {
+	var sum float64
+	for _, x := range v {
+		sum += x * x
+	}
+	return math.Sqrt(sum)
+}
@@ -0,0 +1,108 @@
+package anysgd
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+)
+
+const larsDefaultTrustCoeff = 0.001
+
+// LARS implements layer-wise adaptive rate scaling, as
+// described in https://arxiv.org/abs/1708.03888.
+//
+// For each variable w with gradient g, the local rate is
+//
+//     TrustCoeff * ||w|| / (||g|| + WeightDecay*||w||)
+//
+// or 1 if either ||w|| or ||g|| is zero.
+// The update is the local rate times g+WeightDecay*w, and
+// the transformed gradient is a running sum of updates
+// with momentum:
+//
+//     v := Momentum * v + update
+type LARS struct {
+	// Momentum is the momentum coefficient.
+	// If it is 0, no momentum is used.
+	Momentum float64
+
+	// WeightDecay is the coefficient for weight decay.
+	WeightDecay float64
+
+	// TrustCoeff scales the local rates.
+	// If it is 0, a default of 0.001 is used.
+	TrustCoeff float64
+
+	// Vars is used by the marshalling routines to
+	// assign an ordering to the variables.
+	// It is only used by the MarshalBinary and
+	// UnmarshalBinary methods.
+	Vars []*anydiff.Var
+
+	velocity anydiff.Grad
+}
+
+// Transform transforms the gradient using LARS.
+//
+// This is not thread-safe.
+func (l *LARS) Transform(realGrad anydiff.Grad) anydiff.Grad {
+	trustCoeff := valueOrDefault(l.TrustCoeff, larsDefaultTrustCoeff)
+	for variable, vec := range realGrad {
+		c := vec.Creator()
+		paramNorm := vectorNorm(variable.Vector)
+		gradNorm := vectorNorm(vec)
+		localRate := 1.0
+		if paramNorm != 0 && gradNorm != 0 {
+			localRate = trustCoeff * paramNorm / (gradNorm + l.WeightDecay*paramNorm)
+		}
+		if l.WeightDecay != 0 {
+			decay := variable.Vector.Copy()
+			decay.Scale(c.MakeNumeric(l.WeightDecay))
+			vec.Add(decay)
+		}
+		vec.Scale(c.MakeNumeric(localRate))
+	}
+
+	if l.velocity == nil {
+		l.velocity = copyGrad(realGrad)
+		return realGrad
+	}
+	for variable, vec := range l.velocity {
+		vec.Scale(vec.Creator().MakeNumeric(l.Momentum))
+		vec.Add(realGrad[variable])
+		realGrad[variable].Set(vec)
+	}
+	return realGrad
+}
+
+// MarshalBinary marshals the hyperparameters and current
+// state into a binary format.
+//
+// This requires that l.Vars contains all and only the
+// variables contained in gradients passed to Transform.
+// If Transform has never been called, then MarshalBinary
+// will always succeed.
+func (l *LARS) MarshalBinary() (data []byte, err error) {
+	defer essentials.AddCtxTo("marshal LARS", &err)
+	velocityData, err := marshalGradient(l.Vars, l.velocity)
+	if err != nil {
+		return nil, err
+	}
+	return serializer.SerializeAny(l.Momentum, l.WeightDecay, l.TrustCoeff,
+		velocityData)
+}
+
+// UnmarshalBinary performs the inverse of MarshalBinary.
+//
+// Like MarshalBinary, this requires l.Vars to be set.
+func (l *LARS) UnmarshalBinary(data []byte) (err error) {
+	defer essentials.AddCtxTo("unmarshal LARS", &err)
+	var velocityData []byte
+	err = serializer.DeserializeAny(data, &l.Momentum, &l.WeightDecay,
+		&l.TrustCoeff, &velocityData)
+	if err != nil {
+		return
+	}
+	l.velocity, err = unmarshalGradient(l.Vars, velocityData)
+	return
+}
@@ -0,0 +1,35 @@
+package anysgd
+
+import (
+	"testing"
+
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestLARSValues(t *testing.T) {
+	var velocity []float64
+	l := &LARS{Momentum: 0.9, WeightDecay: 0.01, TrustCoeff: 0.02}
+	testTransformerValues(t, l, func(w, g []float64) []float64 {
+		rate := 0.02 * euclideanNorm(w) / (euclideanNorm(g) + 0.01*euclideanNorm(w))
+		res := make([]float64, len(g))
+		for i, x := range g {
+			update := rate * (x + 0.01*w[i])
+			if velocity == nil {
+				res[i] = update
+			} else {
+				res[i] = 0.9*velocity[i] + update
+			}
+		}
+		velocity = res
+		return res
+	})
+}
+
+func TestLARSMarshal(t *testing.T) {
+	l := &LARS{
+		Momentum:    0.5,
+		WeightDecay: 0.1,
+		Vars:        randomVars(anyvec64.DefaultCreator{}),
+	}
+	testMarshal(t, l, l.Vars)
+}
@@ -0,0 +1,402 @@
+package anysgd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet/internal/numeric"
+	"github.com/unixpickle/essentials"
+)
+
+const (
+	lbfgsDefaultHistorySize   = 10
+	lbfgsDefaultMaxIters      = 100
+	lbfgsDefaultGradTolerance = 1e-5
+	lbfgsDefaultCostTolerance = 1e-9
+	lbfgsDefaultMaxEvals      = 25
+	lbfgsDefaultC1            = 1e-4
+	lbfgsDefaultC2            = 0.9
+)
+
+// LBFGS minimizes the cost of a single batch using the
+// limited-memory BFGS algorithm.
+// For details, see Nocedal and Wright, "Numerical
+// Optimization", chapters 3 and 7.
+//
+// Unlike SGD, LBFGS evaluates the cost several times per
+// step in order to perform a line search.
+// Thus, it is best suited for full-batch optimization of
+// small models.
+type LBFGS struct {
+	Coster Coster
+	Batch  Batch
+	Params []*anydiff.Var
+
+	// HistorySize is the number of curvature pairs used
+	// to approximate the inverse Hessian.
+	// If it is 0, a default of 10 is used.
+	HistorySize int
+
+	// MaxIters is the maximum number of iterations.
+	// If it is 0, a default of 100 is used.
+	MaxIters int
+
+	// GradTolerance is the largest absolute gradient
+	// component at which the algorithm has converged.
+	// If it is 0, a default of 1e-5 is used.
+	GradTolerance float64
+
+	// CostTolerance is the smallest relative decrease in
+	// cost that does not indicate convergence.
+	// If it is 0, a default of 1e-9 is used.
+	CostTolerance float64
+
+	// MaxEvals is the maximum number of cost evaluations
+	// for a single line search.
+	// If it is 0, a default of 25 is used.
+	MaxEvals int
+
+	// C1 and C2 are the constants for the strong Wolfe
+	// conditions.
+	// If they are 0, defaults of 1e-4 and 0.9 are used.
+	C1 float64
+	C2 float64
+
+	// StatusFunc, if non-nil, is called after every
+	// iteration.
+	StatusFunc func(s *LBFGSStatus)
+}
+
+// LBFGSStatus describes the progress of LBFGS.
+type LBFGSStatus struct {
+	// Iteration is the number of completed iterations.
+	Iteration int
+
+	// Evaluations is the total number of cost evaluations.
+	Evaluations int
+
+	Cost float64
+
+	// GradMax is the largest absolute gradient component.
+	GradMax float64
+
+	// StepSize is the step size from the last line search.
+	StepSize float64
+
+	// Converged is set once a convergence tolerance is
+	// met.
+	Converged bool
+}
+
+// Run runs LBFGS until it converges or reaches the
+// maximum number of iterations.
+//
+// When Run returns, the parameters are set to the best
+// point found.
+// An error is returned if a line search fails, in which
+// case the parameters are left at the last accepted
+// point.
+func (l *LBFGS) Run() (status *LBFGSStatus, err error) {
+	defer essentials.AddCtxTo("L-BFGS", &err)
+
+	x := l.paramValues()
+	cost, grad := l.evaluate(x)
+	status = &LBFGSStatus{Evaluations: 1, Cost: cost, GradMax: maxAbs(grad)}
+	if status.GradMax <= l.gradTolerance() {
+		status.Converged = true
+		return status, nil
+	}
+
+	var history []*lbfgsPair
+	maxIters := l.MaxIters
+	if maxIters == 0 {
+		maxIters = lbfgsDefaultMaxIters
+	}
+	for status.Iteration < maxIters {
+		direction := lbfgsDirection(history, grad)
+		slope := dot(direction, grad)
+		if slope >= 0 {
+			// The curvature information is useless, so
+			// fall back on steepest descent.
+			history = nil
+			direction = scaled(grad, -1)
+			slope = dot(direction, grad)
+		}
+
+		initStep := 1.0
+		if status.Iteration == 0 {
+			initStep = math.Min(1, 1/sumAbs(grad))
+		}
+		search := &lbfgsLineSearch{
+			LBFGS:     l,
+			X:         x,
+			Direction: direction,
+			Start:     &lbfgsPoint{Cost: cost, Grad: grad, Slope: slope},
+		}
+		point, err := search.Search(initStep)
+		status.Evaluations += search.Evals
+		if err != nil {
+			l.setParams(x)
+			return status, err
+		}
+
+		newX := addScaled(x, direction, point.Step)
+		pair := &lbfgsPair{
+			S: addScaled(newX, x, -1),
+			Y: addScaled(point.Grad, grad, -1),
+		}
+		if dot(pair.S, pair.Y) > 1e-10 {
+			history = append(history, pair)
+			if len(history) > l.historySize() {
+				history = history[1:]
+			}
+		}
+
+		costDelta := cost - point.Cost
+		x, cost, grad = newX, point.Cost, point.Grad
+		status.Iteration++
+		status.Cost = cost
+		status.GradMax = maxAbs(grad)
+		status.StepSize = point.Step
+		status.Converged = status.GradMax <= l.gradTolerance() ||
+			math.Abs(costDelta) <= l.costTolerance()*math.Max(1, math.Abs(cost))
+		l.setParams(x)
+		if l.StatusFunc != nil {
+			l.StatusFunc(status)
+		}
+		if status.Converged {
+			break
+		}
+	}
+	return status, nil
+}
+
+// evaluate computes the cost and gradient at x.
+func (l *LBFGS) evaluate(x []float64) (float64, []float64) {
+	l.setParams(x)
+	grad, cost := CosterGrad(l.Coster, l.Batch, l.Params)
+	var gradValues []float64
+	for _, p := range l.Params {
+		gradValues = append(gradValues, numeric.Float64s(grad[p].Data())...)
+	}
+	costValue, err := numericFloat(cost)
+	if err != nil {
+		panic(err)
+	}
+	return costValue, gradValues
+}
+
+func (l *LBFGS) paramValues() []float64 {
+	var res []float64
+	for _, p := range l.Params {
+		res = append(res, numeric.Float64s(p.Vector.Data())...)
+	}
+	return res
+}
+
+func (l *LBFGS) setParams(x []float64) {
+	for _, p := range l.Params {
+		n := p.Vector.Len()
+		p.Vector.SetData(p.Vector.Creator().MakeNumericList(x[:n]))
+		x = x[n:]
+	}
+}
+
+func (l *LBFGS) historySize() int {
+	if l.HistorySize == 0 {
+		return lbfgsDefaultHistorySize
+	}
+	return l.HistorySize
+}
+
+func (l *LBFGS) gradTolerance() float64 {
+	return valueOrDefault(l.GradTolerance, lbfgsDefaultGradTolerance)
+}
+
+func (l *LBFGS) costTolerance() float64 {
+	return valueOrDefault(l.CostTolerance, lbfgsDefaultCostTolerance)
+}
+
+// lbfgsPair is a curvature pair, storing the change in
+// parameters and the corresponding change in gradient.
+type lbfgsPair struct {
+	S []float64
+	Y []float64
+}
+
+// lbfgsDirection computes the search direction using the
+// two-loop recursion.
+func lbfgsDirection(history []*lbfgsPair, grad []float64) []float64 {
+	q := append([]float64{}, grad...)
+	alphas := make([]float64, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		pair := history[i]
+		alphas[i] = dot(pair.S, q) / dot(pair.Y, pair.S)
+		q = addScaled(q, pair.Y, -alphas[i])
+	}
+	if len(history) > 0 {
+		last := history[len(history)-1]
+		q = scaled(q, dot(last.S, last.Y)/dot(last.Y, last.Y))
+	}
+	for i, pair := range history {
+		beta := dot(pair.Y, q) / dot(pair.Y, pair.S)
+		q = addScaled(q, pair.S, alphas[i]-beta)
+	}
+	return scaled(q, -1)
+}
+
+// lbfgsPoint is a point evaluated during a line search.
+type lbfgsPoint struct {
+	Step  float64
+	Cost  float64
+	Grad  []float64
+	Slope float64
+}
+
+// lbfgsLineSearch finds a step size satisfying the strong
+// Wolfe conditions, using algorithms 3.5 and 3.6 from
+// Nocedal and Wright.
+type lbfgsLineSearch struct {
+	LBFGS     *LBFGS
+	X         []float64
+	Direction []float64
+	Start     *lbfgsPoint
+	Evals     int
+}
+
+func (l *lbfgsLineSearch) Search(step float64) (*lbfgsPoint, error) {
+	prev := l.Start
+	for i := 0; ; i++ {
+		point, err := l.evaluate(step)
+		if err != nil {
+			return nil, err
+		}
+		if !l.sufficientDecrease(point) || (i > 0 && point.Cost >= prev.Cost) {
+			return l.zoom(prev, point)
+		}
+		if l.curvatureMet(point) {
+			return point, nil
+		}
+		if point.Slope >= 0 {
+			return l.zoom(point, prev)
+		}
+		prev = point
+		step *= 2
+	}
+}
+
+func (l *lbfgsLineSearch) zoom(lo, hi *lbfgsPoint) (*lbfgsPoint, error) {
+	for {
+		point, err := l.evaluate(interpolateStep(lo, hi))
+		if err != nil {
+			return nil, err
+		}
+		if !l.sufficientDecrease(point) || point.Cost >= lo.Cost {
+			hi = point
+			continue
+		}
+		if l.curvatureMet(point) {
+			return point, nil
+		}
+		if point.Slope*(hi.Step-lo.Step) >= 0 {
+			hi = lo
+		}
+		lo = point
+	}
+}
+
+func (l *lbfgsLineSearch) evaluate(step float64) (*lbfgsPoint, error) {
+	maxEvals := l.LBFGS.MaxEvals
+	if maxEvals == 0 {
+		maxEvals = lbfgsDefaultMaxEvals
+	}
+	if l.Evals >= maxEvals {
+		return nil, errors.New("line search failed")
+	}
+	l.Evals++
+	cost, grad := l.LBFGS.evaluate(addScaled(l.X, l.Direction, step))
+	if math.IsNaN(cost) {
+		return nil, fmt.Errorf("cost is NaN at step size %e", step)
+	}
+	return &lbfgsPoint{
+		Step:  step,
+		Cost:  cost,
+		Grad:  grad,
+		Slope: dot(grad, l.Direction),
+	}, nil
+}
+
+func (l *lbfgsLineSearch) sufficientDecrease(p *lbfgsPoint) bool {
+	c1 := valueOrDefault(l.LBFGS.C1, lbfgsDefaultC1)
+	return p.Cost <= l.Start.Cost+c1*p.Step*l.Start.Slope
+}
+
+func (l *lbfgsLineSearch) curvatureMet(p *lbfgsPoint) bool {
+	c2 := valueOrDefault(l.LBFGS.C2, lbfgsDefaultC2)
+	return math.Abs(p.Slope) <= -c2*l.Start.Slope
+}
+
+// interpolateStep finds the minimizer of the cubic that
+// interpolates two points, falling back on bisection if
+// the minimizer is too close to either end of the
+// interval.
+func interpolateStep(p1, p2 *lbfgsPoint) float64 {
+	d1 := p1.Slope + p2.Slope - 3*(p1.Cost-p2.Cost)/(p1.Step-p2.Step)
+	d2Sq := d1*d1 - p1.Slope*p2.Slope
+	low, high := math.Min(p1.Step, p2.Step), math.Max(p1.Step, p2.Step)
+	margin := 0.1 * (high - low)
+	if d2Sq >= 0 {
+		d2 := math.Sqrt(d2Sq)
+		if p2.Step < p1.Step {
+			d2 = -d2
+		}
+		step := p2.Step - (p2.Step-p1.Step)*(p2.Slope+d2-d1)/(p2.Slope-p1.Slope+2*d2)
+		if step >= low+margin && step <= high-margin {
+			return step
+		}
+	}
+	return (low + high) / 2
+}
+
+func dot(v1, v2 []float64) float64 {
+	var res float64
+	for i, x := range v1 {
+		res += x * v2[i]
+	}
+	return res
+}
+
+func scaled(v []float64, s float64) []float64 {
+	res := make([]float64, len(v))
+	for i, x := range v {
+		res[i] = x * s
+	}
+	return res
+}
+
+// addScaled computes v1 + s*v2.
+func addScaled(v1, v2 []float64, s float64) []float64 {
+	res := make([]float64, len(v1))
+	for i, x := range v1 {
+		res[i] = x + s*v2[i]
+	}
+	return res
+}
+
+func maxAbs(v []float64) float64 {
+	var res float64
+	for _, x := range v {
+		res = math.Max(res, math.Abs(x))
+	}
+	return res
+}
+
+func sumAbs(v []float64) float64 {
+	var res float64
+	for _, x := range v {
+		res += math.Abs(x)
+	}
+	return res
+}
@@ -0,0 +1,80 @@
+package anysgd
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestLBFGSRosenbrock(t *testing.T) {
+	r := newRosenbrock()
+	var calls int
+	l := &LBFGS{
+		Coster:        r,
+		Params:        []*anydiff.Var{r.X, r.Y},
+		GradTolerance: 1e-8,
+		StatusFunc: func(s *LBFGSStatus) {
+			calls++
+			if s.Iteration != calls {
+				t.Errorf("expected iteration %d but got %d", calls, s.Iteration)
+			}
+		},
+	}
+	status, err := l.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Converged {
+		t.Error("did not converge")
+	}
+	if status.Iteration == 0 || status.Iteration > 60 {
+		t.Errorf("unexpected iteration count: %d", status.Iteration)
+	}
+	x := r.X.Vector.Data().([]float64)[0]
+	y := r.Y.Vector.Data().([]float64)[0]
+	if math.Abs(x-1) > 1e-4 || math.Abs(y-1) > 1e-4 {
+		t.Errorf("bad solution: %f, %f", x, y)
+	}
+	if math.Abs(status.Cost) > 1e-8 {
+		t.Errorf("bad final cost: %e", status.Cost)
+	}
+}
+
+func TestLBFGSConverged(t *testing.T) {
+	r := newRosenbrock()
+	r.X.Vector.SetData([]float64{1})
+	r.Y.Vector.SetData([]float64{1})
+	status, err := (&LBFGS{Coster: r, Params: []*anydiff.Var{r.X, r.Y}}).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Converged || status.Iteration != 0 || status.Evaluations != 1 {
+		t.Errorf("unexpected status: %+v", status)
+	}
+}
+
+// rosenbrock is a Coster for the Rosenbrock function
+//
+//     (1-x)^2 + 100*(y-x^2)^2
+type rosenbrock struct {
+	X *anydiff.Var
+	Y *anydiff.Var
+}
+
+func newRosenbrock() *rosenbrock {
+	c := anyvec64.DefaultCreator{}
+	return &rosenbrock{
+		X: anydiff.NewVar(c.MakeVectorData([]float64{-1.2})),
+		Y: anydiff.NewVar(c.MakeVectorData([]float64{1})),
+	}
+}
+
+func (r *rosenbrock) TotalCost(b Batch) anydiff.Res {
+	c := r.X.Vector.Creator()
+	term1 := anydiff.Square(anydiff.AddScalar(anydiff.Scale(r.X, c.MakeNumeric(-1)),
+		c.MakeNumeric(1)))
+	term2 := anydiff.Square(anydiff.Sub(r.Y, anydiff.Square(r.X)))
+	return anydiff.Add(term1, anydiff.Scale(term2, c.MakeNumeric(100)))
+}
@@ -0,0 +1,216 @@
+package anysgd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+)
+
+const (
+	lrFinderDefaultMinRate   = 1e-7
+	lrFinderDefaultMaxRate   = 10
+	lrFinderDefaultNumSteps  = 100
+	lrFinderDefaultSmoothing = 0.98
+	lrFinderDefaultDiverge   = 4
+)
+
+// An LRFinder performs a learning rate range test, as
+// described in https://arxiv.org/abs/1506.01186.
+//
+// The test runs a short session of SGD while increasing
+// the learning rate exponentially from MinRate to
+// MaxRate, recording the cost at every step.
+// When the test is done, the parameters are restored to
+// their original values.
+type LRFinder struct {
+	Fetcher    Fetcher
+	Gradienter Gradienter
+
+	// Transformer, if non-nil, is used to transform each
+	// gradient before the step.
+	//
+	// Running the test changes the Transformer's internal
+	// state.
+	// If the Transformer implements TransformMarshaler,
+	// its state is restored after the test.
+	// Otherwise, a fresh Transformer should be used for
+	// training after the test.
+	Transformer Transformer
+
+	// Cost is called after every gradient computation to
+	// get the cost of the batch.
+	// For the trainers in anynet, this can simply return
+	// the trainer's LastCost field.
+	Cost func() anyvec.Numeric
+
+	// Params are the parameters to restore after the test.
+	Params []*anydiff.Var
+
+	// Samples is the list of training samples.
+	// It will be shuffled as needed.
+	//
+	// The list may not be empty.
+	Samples SampleList
+
+	// Rand, if non-nil, is used to shuffle the samples.
+	Rand *rand.Rand
+
+	// BatchSize is the mini-batch size.
+	// If it is 0, then the entire sample list is used at
+	// every step.
+	//
+	// If Samples implements BatchSizer, then BatchSize is
+	// ignored.
+	BatchSize int
+
+	// MinRate and MaxRate are the first and last learning
+	// rates to try.
+	// If they are 0, defaults of 1e-7 and 10 are used.
+	MinRate float64
+	MaxRate float64
+
+	// NumSteps is the number of steps to run.
+	// If it is 0, a default of 100 is used.
+	NumSteps int
+
+	// Smoothing is the decay rate of the moving average
+	// used to smooth the costs.
+	// If it is 0, a default of 0.98 is used.
+	Smoothing float64
+
+	// DivergeFactor determines when to stop the test
+	// early.
+	// The test stops once the smoothed cost exceeds the
+	// lowest smoothed cost times DivergeFactor.
+	// If it is 0, a default of 4 is used.
+	DivergeFactor float64
+}
+
+// An LRCurve stores the results of a learning rate range
+// test.
+type LRCurve struct {
+	// Rates stores the learning rate for each step.
+	Rates []float64
+
+	// Costs stores the raw cost for each step.
+	Costs []float64
+
+	// Smoothed stores the smoothed cost for each step.
+	Smoothed []float64
+
+	// Suggested is the rate at which the smoothed cost
+	// decreased most steeply.
+	Suggested float64
+}
+
+// Run runs the range test.
+//
+// The parameters are restored even if an error occurs.
+func (l *LRFinder) Run() (curve *LRCurve, err error) {
+	defer essentials.AddCtxTo("learning rate range test", &err)
+	if l.Samples.Len() == 0 {
+		return nil, errors.New("empty sample list")
+	}
+
+	backups := make([]anyvec.Vector, len(l.Params))
+	for i, p := range l.Params {
+		backups[i] = p.Vector.Copy()
+	}
+	defer func() {
+		for i, p := range l.Params {
+			p.Vector.Set(backups[i])
+		}
+	}()
+	if m, ok := l.Transformer.(TransformMarshaler); ok {
+		state, saveErr := m.MarshalBinary()
+		if saveErr != nil {
+			return nil, saveErr
+		}
+		defer func() {
+			if restoreErr := m.UnmarshalBinary(state); restoreErr != nil && err == nil {
+				curve, err = nil, restoreErr
+			}
+		}()
+	}
+
+	minRate := valueOrDefault(l.MinRate, lrFinderDefaultMinRate)
+	maxRate := valueOrDefault(l.MaxRate, lrFinderDefaultMaxRate)
+	numSteps := l.NumSteps
+	if numSteps == 0 {
+		numSteps = lrFinderDefaultNumSteps
+	}
+	smoothing := valueOrDefault(l.Smoothing, lrFinderDefaultSmoothing)
+	diverge := valueOrDefault(l.DivergeFactor, lrFinderDefaultDiverge)
+
+	curve = &LRCurve{}
+	idx := l.Samples.Len()
+	var average float64
+	bestCost := math.Inf(1)
+	for step := 0; step < numSteps; step++ {
+		rate := minRate
+		if numSteps > 1 {
+			rate *= math.Pow(maxRate/minRate, float64(step)/float64(numSteps-1))
+		}
+
+		if idx == l.Samples.Len() {
+			ShuffleRand(l.Samples, l.Rand)
+			idx = 0
+		}
+		batchSize := batchSizeAt(l.Samples, idx, l.BatchSize)
+		batch, err := l.Fetcher.Fetch(l.Samples.Slice(idx, idx+batchSize))
+		if err != nil {
+			return nil, err
+		}
+		idx += batchSize
+
+		grad := l.Gradienter.Gradient(batch)
+		cost, err := numericFloat(l.Cost())
+		if err != nil {
+			return nil, err
+		}
+		if l.Transformer != nil {
+			grad = l.Transformer.Transform(grad)
+		}
+		scaleGrad(grad, -rate)
+		grad.AddToVars()
+
+		average = smoothing*average + (1-smoothing)*cost
+		smoothed := average / (1 - math.Pow(smoothing, float64(step+1)))
+		curve.Rates = append(curve.Rates, rate)
+		curve.Costs = append(curve.Costs, cost)
+		curve.Smoothed = append(curve.Smoothed, smoothed)
+
+		if math.IsNaN(smoothed) || smoothed > bestCost*diverge {
+			break
+		}
+		bestCost = math.Min(bestCost, smoothed)
+	}
+
+	curve.Suggested = curve.Rates[0]
+	bestSlope := math.Inf(1)
+	for i := 1; i < len(curve.Rates); i++ {
+		slope := (curve.Smoothed[i] - curve.Smoothed[i-1]) /
+			(math.Log(curve.Rates[i]) - math.Log(curve.Rates[i-1]))
+		if slope < bestSlope {
+			bestSlope = slope
+			curve.Suggested = curve.Rates[i]
+		}
+	}
+	return curve, nil
+}
+
+func numericFloat(n anyvec.Numeric) (float64, error) {
+	switch n := n.(type) {
+	case float32:
+		return float64(n), nil
+	case float64:
+		return n, nil
+	default:
+		return 0, fmt.Errorf("unsupported cost type: %T", n)
+	}
+}
@@ -0,0 +1,83 @@
+package anysgd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec32"
+)
+
+func TestLRFinder(t *testing.T) {
+	g := &quadraticGradienter{X: anydiff.NewVar(anyvec32.MakeVector(1))}
+	adam := &Adam{Vars: []*anydiff.Var{g.X}}
+	adamState, err := adam.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	finder := &LRFinder{
+		Fetcher:     testFetcher{},
+		Gradienter:  g,
+		Transformer: adam,
+		Cost:        func() anyvec.Numeric { return g.LastCost },
+		Params:      []*anydiff.Var{g.X},
+		Samples:     LengthSampleList(4),
+		BatchSize:   2,
+		MinRate:     1e-4,
+		MaxRate:     100,
+		NumSteps:    60,
+		Smoothing:   0.5,
+	}
+	curve, err := finder.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if x := g.X.Vector.Data().([]float32)[0]; x != 0 {
+		t.Errorf("parameter was not restored: got %f", x)
+	}
+	newState, err := adam.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(newState, adamState) {
+		t.Error("transformer was not restored")
+	}
+
+	if len(curve.Rates) == 0 || len(curve.Rates) != len(curve.Costs) ||
+		len(curve.Rates) != len(curve.Smoothed) {
+		t.Fatalf("bad curve lengths: %d, %d, %d", len(curve.Rates), len(curve.Costs),
+			len(curve.Smoothed))
+	}
+	if len(curve.Rates) == finder.NumSteps {
+		t.Error("test should stop early once the cost diverges")
+	}
+	for i := 1; i < len(curve.Rates); i++ {
+		if curve.Rates[i] <= curve.Rates[i-1] {
+			t.Fatalf("rates should increase: %v", curve.Rates)
+		}
+	}
+	if curve.Costs[0] != 9 {
+		t.Errorf("expected first cost 9 but got %f", curve.Costs[0])
+	}
+	if curve.Suggested < 1e-3 || curve.Suggested > 10 {
+		t.Errorf("unexpected suggested rate: %f", curve.Suggested)
+	}
+}
+
+// quadraticGradienter minimizes (x-3)^2.
+type quadraticGradienter struct {
+	X        *anydiff.Var
+	LastCost anyvec.Numeric
+}
+
+func (q *quadraticGradienter) TotalCost(b Batch) anydiff.Res {
+	c := q.X.Vector.Creator()
+	return anydiff.Square(anydiff.AddScalar(q.X, c.MakeNumeric(-3)))
+}
+
+func (q *quadraticGradienter) Gradient(b Batch) anydiff.Grad {
+	grad, cost := CosterGrad(q, b, []*anydiff.Var{q.X})
+	q.LastCost = cost
+	return grad
+}
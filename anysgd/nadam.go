@@ -0,0 +1,54 @@
+package anysgd
+
+import (
+	"math"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+)
+
+// Nadam implements Adam with Nesterov momentum, as
+// described in http://cs229.stanford.edu/proj2015/054_report.pdf.
+//
+// With first and second moments m and v, the transformed
+// gradient for step t is
+//
+//     (b1*m + (1-b1)*g) / (1-b1^t) / sqrt(v/(1-b2^t) + damping)
+//
+// The hyperparameters and defaults are the same as for
+// Adam, and marshalling works the same way.
+type Nadam struct {
+	Adam
+}
+
+// Transform transforms the gradient using Nadam.
+//
+// This is not thread-safe.
+func (n *Nadam) Transform(realGrad anydiff.Grad) anydiff.Grad {
+	n.updateMoments(copyGrad(realGrad))
+	n.iteration++
+
+	b1 := n.decayRate(1)
+	correction1 := 1 - math.Pow(b1, n.iteration)
+	correction2 := 1 - math.Pow(n.decayRate(2), n.iteration)
+	damping := n.damping()
+	for variable, vec := range realGrad {
+		c := vec.Creator()
+		vec.Scale(c.MakeNumeric((1 - b1) / correction1))
+		momentum := n.firstMoment[variable].Copy()
+		momentum.Scale(c.MakeNumeric(b1 / correction1))
+		vec.Add(momentum)
+		vec.Div(adamDivisor(n.secondMoment[variable], correction2, damping))
+	}
+	return realGrad
+}
+
+// adamDivisor computes sqrt(v/correction + damping).
+func adamDivisor(v anyvec.Vector, correction, damping float64) anyvec.Vector {
+	c := v.Creator()
+	res := v.Copy()
+	res.Scale(c.MakeNumeric(1 / correction))
+	res.AddScalar(c.MakeNumeric(damping))
+	anyvec.Pow(res, c.MakeNumeric(0.5))
+	return res
+}
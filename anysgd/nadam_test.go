@@ -0,0 +1,34 @@
+package anysgd
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestNadamValues(t *testing.T) {
+	var m, v []float64
+	var step float64
+	n := &Nadam{Adam: Adam{DecayRate1: 0.9, DecayRate2: 0.99, Damping: 1e-8}}
+	testTransformerValues(t, n, func(w, g []float64) []float64 {
+		if m == nil {
+			m = make([]float64, len(g))
+			v = make([]float64, len(g))
+		}
+		step++
+		res := make([]float64, len(g))
+		for i, x := range g {
+			m[i] = 0.9*m[i] + 0.1*x
+			v[i] = 0.99*v[i] + 0.01*x*x
+			numerator := (0.9*m[i] + 0.1*x) / (1 - math.Pow(0.9, step))
+			res[i] = numerator / math.Sqrt(v[i]/(1-math.Pow(0.99, step))+1e-8)
+		}
+		return res
+	})
+}
+
+func TestNadamMarshal(t *testing.T) {
+	n := &Nadam{Adam: Adam{DecayRate1: 0.3, Vars: randomVars(anyvec64.DefaultCreator{})}}
+	testMarshal(t, n, n.Vars)
+}
@@ -0,0 +1,344 @@
+package anysgd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+)
+
+const recorderDefaultSmoothing = 0.98
+
+// A Record stores the metrics for a single training step.
+//
+// Metrics which are not known are set to NaN.
+type Record struct {
+	Iteration int
+	Epoch     float64
+	Rate      float64
+	Cost      float64
+	GradNorm  float64
+
+	// Validation stores validation metrics, if any were
+	// computed since the previous step.
+	Validation map[string]float64
+
+	// Time is the number of seconds since the first
+	// record.
+	Time float64
+}
+
+// A RecordWriter writes Records to a file or some other
+// destination.
+type RecordWriter interface {
+	WriteRecord(r *Record) error
+}
+
+// A Recorder records training metrics.
+//
+// A Recorder can be attached to SGD, in which case it
+// records a step after every mini-batch.
+// SGD is the only type which reports into a Recorder by
+// itself.
+// Trainers do not know about Recorders, so their costs
+// are read through the Cost field, and metrics from
+// elsewhere (such as an anyctc.Evaluator) can be added
+// with AddValidation.
+// Other loops, like LRFinder or a custom training loop,
+// can call Record directly.
+//
+// In addition to writing records, a Recorder keeps
+// running averages of the cost, gradient norm, and
+// validation metrics for console output.
+type Recorder struct {
+	// Writer, if non-nil, is used to write every record.
+	Writer RecordWriter
+
+	// Cost is called after every gradient computation in
+	// SGD to get the cost of the batch.
+	// For the trainers in anynet, this can simply return
+	// the trainer's LastCost field.
+	//
+	// If Cost is nil, the cost is not recorded.
+	Cost func() anyvec.Numeric
+
+	// Smoothing is the decay rate of the running
+	// averages.
+	// If it is 0, a default of 0.98 is used.
+	Smoothing float64
+
+	start      time.Time
+	iteration  int
+	validation map[string]float64
+	averages   map[string]*runningAverage
+	last       *Record
+}
+
+// AddValidation stores validation metrics to be included
+// in the next record.
+func (r *Recorder) AddValidation(metrics map[string]float64) {
+	if r.validation == nil {
+		r.validation = map[string]float64{}
+	}
+	for k, v := range metrics {
+		r.validation[k] = v
+	}
+}
+
+// Record writes a record and updates the running
+// averages.
+//
+// Any metrics from AddValidation are added to the
+// record's validation metrics.
+// The Time field is set automatically.
+func (r *Recorder) Record(rec *Record) (err error) {
+	defer essentials.AddCtxTo("record metrics", &err)
+	now := time.Now()
+	if r.start.IsZero() {
+		r.start = now
+	}
+	rec.Time = now.Sub(r.start).Seconds()
+	r.iteration = rec.Iteration
+
+	if len(r.validation) > 0 {
+		if rec.Validation == nil {
+			rec.Validation = map[string]float64{}
+		}
+		for k, v := range r.validation {
+			rec.Validation[k] = v
+		}
+		r.validation = nil
+	}
+
+	r.updateAverage("cost", rec.Cost)
+	r.updateAverage("grad_norm", rec.GradNorm)
+	for k, v := range rec.Validation {
+		r.updateAverage(k, v)
+	}
+	r.last = rec
+
+	if r.Writer != nil {
+		return r.Writer.WriteRecord(rec)
+	}
+	return nil
+}
+
+// Average returns the running average of a metric.
+// The metric may be "cost", "grad_norm", or the name of
+// a validation metric.
+//
+// If the metric has never been recorded, NaN is returned.
+func (r *Recorder) Average(metric string) float64 {
+	if avg, ok := r.averages[metric]; ok {
+		return avg.Value()
+	}
+	return math.NaN()
+}
+
+// Summary produces a one-line summary of the last record
+// and the running averages, suitable for logging.
+func (r *Recorder) Summary() string {
+	if r.last == nil {
+		return "no records"
+	}
+	parts := []string{
+		fmt.Sprintf("iter %d: epoch=%.3f rate=%g", r.last.Iteration, r.last.Epoch,
+			r.last.Rate),
+	}
+	for _, name := range []string{"cost", "grad_norm"} {
+		if avg, ok := r.averages[name]; ok {
+			parts = append(parts, fmt.Sprintf("%s=%g", name, avg.Value()))
+		}
+	}
+	for _, name := range sortedKeys(r.last.Validation) {
+		parts = append(parts, fmt.Sprintf("%s=%g", name, r.Average(name)))
+	}
+	return strings.Join(parts, " ")
+}
+
+// recordStep records a step of SGD.
+func (r *Recorder) recordStep(s *SGD, gradNorm float64) error {
+	cost := math.NaN()
+	if r.Cost != nil {
+		var err error
+		cost, err = numericFloat(r.Cost())
+		if err != nil {
+			return essentials.AddCtx("record metrics", err)
+		}
+	}
+	return r.Record(&Record{
+		Iteration: r.iteration + 1,
+		Epoch:     s.epoch(),
+		Rate:      s.Rater.Rate(s.epoch()),
+		Cost:      cost,
+		GradNorm:  gradNorm,
+	})
+}
+
+func (r *Recorder) updateAverage(name string, value float64) {
+	if math.IsNaN(value) {
+		return
+	}
+	if r.averages == nil {
+		r.averages = map[string]*runningAverage{}
+	}
+	avg, ok := r.averages[name]
+	if !ok {
+		avg = &runningAverage{Decay: valueOrDefault(r.Smoothing, recorderDefaultSmoothing)}
+		r.averages[name] = avg
+	}
+	avg.Add(value)
+}
+
+// A CSVWriter is a RecordWriter that writes a CSV file
+// with a header row.
+type CSVWriter struct {
+	w              *csv.Writer
+	validationKeys []string
+	wroteHeader    bool
+}
+
+// NewCSVWriter creates a CSVWriter.
+//
+// Since every row must have the same columns, the names
+// of the validation metrics must be specified up front.
+// Other validation metrics are not written.
+func NewCSVWriter(w io.Writer, validationKeys []string) *CSVWriter {
+	return &CSVWriter{
+		w:              csv.NewWriter(w),
+		validationKeys: validationKeys,
+	}
+}
+
+// WriteRecord writes a row and flushes the output.
+func (c *CSVWriter) WriteRecord(r *Record) error {
+	if !c.wroteHeader {
+		header := append([]string{"iteration", "epoch", "rate", "cost", "grad_norm",
+			"time"}, c.validationKeys...)
+		if err := c.w.Write(header); err != nil {
+			return err
+		}
+		c.wroteHeader = true
+	}
+	row := []string{
+		strconv.Itoa(r.Iteration),
+		csvFloat(r.Epoch),
+		csvFloat(r.Rate),
+		csvFloat(r.Cost),
+		csvFloat(r.GradNorm),
+		csvFloat(r.Time),
+	}
+	for _, key := range c.validationKeys {
+		if value, ok := r.Validation[key]; ok {
+			row = append(row, csvFloat(value))
+		} else {
+			row = append(row, "")
+		}
+	}
+	if err := c.w.Write(row); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// A JSONWriter is a RecordWriter that writes one JSON
+// object per line.
+//
+// Unknown and non-finite metrics are written as null.
+type JSONWriter struct {
+	w io.Writer
+}
+
+// NewJSONWriter creates a JSONWriter.
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	return &JSONWriter{w: w}
+}
+
+// WriteRecord writes a line of JSON.
+func (j *JSONWriter) WriteRecord(r *Record) error {
+	obj := map[string]interface{}{
+		"iteration": r.Iteration,
+		"epoch":     jsonFloat(r.Epoch),
+		"rate":      jsonFloat(r.Rate),
+		"cost":      jsonFloat(r.Cost),
+		"grad_norm": jsonFloat(r.GradNorm),
+		"time":      jsonFloat(r.Time),
+	}
+	if len(r.Validation) > 0 {
+		validation := map[string]interface{}{}
+		for k, v := range r.Validation {
+			validation[k] = jsonFloat(v)
+		}
+		obj["validation"] = validation
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	_, err = j.w.Write(append(data, '\n'))
+	return err
+}
+
+// runningAverage is a bias-corrected exponential moving
+// average.
+type runningAverage struct {
+	Decay float64
+
+	sum   float64
+	count int
+}
+
+func (r *runningAverage) Add(x float64) {
+	r.sum = r.Decay*r.sum + (1-r.Decay)*x
+	r.count++
+}
+
+func (r *runningAverage) Value() float64 {
+	return r.sum / (1 - math.Pow(r.Decay, float64(r.count)))
+}
+
+// gradNorm computes the Euclidean norm of a gradient.
+func gradNorm(g anydiff.Grad) (float64, error) {
+	var sum float64
+	for _, v := range g {
+		sq, err := numericFloat(v.Dot(v))
+		if err != nil {
+			return 0, err
+		}
+		sum += sq
+	}
+	return math.Sqrt(sum), nil
+}
+
+func csvFloat(x float64) string {
+	if math.IsNaN(x) {
+		return ""
+	}
+	return strconv.FormatFloat(x, 'g', -1, 64)
+}
+
+func jsonFloat(x float64) interface{} {
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return nil
+	}
+	return x
+}
+
+func sortedKeys(m map[string]float64) []string {
+	var res []string
+	for k := range m {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,107 @@
+package anysgd
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec32"
+)
+
+func TestRecorderSGD(t *testing.T) {
+	g := &quadraticGradienter{X: anydiff.NewVar(anyvec32.MakeVector(1))}
+	var buf bytes.Buffer
+	recorder := &Recorder{
+		Writer: NewJSONWriter(&buf),
+		Cost:   func() anyvec.Numeric { return g.LastCost },
+	}
+	stop := newTestStopper(5)
+	s := &SGD{
+		Fetcher:    testFetcher{},
+		Gradienter: g,
+		Samples:    LengthSampleList(2),
+		Rater:      ConstRater(0.1),
+		StatusFunc: stop.StatusFunc,
+		BatchSize:  1,
+		Recorder:   recorder,
+	}
+	if err := s.Run(stop.Chan()); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 records but got %d", len(lines))
+	}
+	for i, line := range lines {
+		var obj map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &obj); err != nil {
+			t.Fatal(err)
+		}
+		if obj["iteration"].(float64) != float64(i+1) {
+			t.Errorf("record %d: bad iteration %v", i, obj["iteration"])
+		}
+		if obj["epoch"].(float64) != float64(i+1)/2 {
+			t.Errorf("record %d: bad epoch %v", i, obj["epoch"])
+		}
+		if math.Abs(obj["rate"].(float64)-0.1) > 1e-8 {
+			t.Errorf("record %d: bad rate %v", i, obj["rate"])
+		}
+		if i == 0 {
+			if obj["cost"].(float64) != 9 || obj["grad_norm"].(float64) != 6 {
+				t.Errorf("bad first record: %s", line)
+			}
+		}
+	}
+	if avg := recorder.Average("cost"); avg >= 9 || avg <= 0 {
+		t.Errorf("unexpected average cost: %f", avg)
+	}
+}
+
+func TestRecorderCSV(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := &Recorder{Writer: NewCSVWriter(&buf, []string{"accuracy"})}
+	err := recorder.Record(&Record{Iteration: 1, Epoch: 0.5, Rate: 0.1, Cost: 2,
+		GradNorm: math.NaN()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder.AddValidation(map[string]float64{"accuracy": 0.75})
+	err = recorder.Record(&Record{Iteration: 2, Epoch: 1, Rate: 0.1, Cost: 1,
+		GradNorm: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := []string{
+		"iteration,epoch,rate,cost,grad_norm,time,accuracy",
+		"1,0.5,0.1,2,,0,",
+		"2,1,0.1,1,3,",
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines but got %d", len(expected), len(lines))
+	}
+	for i, line := range lines[:2] {
+		if line != expected[i] {
+			t.Errorf("line %d: expected %q but got %q", i, expected[i], line)
+		}
+	}
+	if !strings.HasPrefix(lines[2], expected[2]) || !strings.HasSuffix(lines[2], ",0.75") {
+		t.Errorf("unexpected last line: %q", lines[2])
+	}
+
+	if avg := recorder.Average("accuracy"); avg != 0.75 {
+		t.Errorf("expected average accuracy 0.75 but got %f", avg)
+	}
+	if avg := recorder.Average("cost"); avg <= 1 || avg >= 2 {
+		t.Errorf("unexpected average cost: %f", avg)
+	}
+	if !math.IsNaN(recorder.Average("missing")) {
+		t.Error("missing metric should have a NaN average")
+	}
+}
@@ -0,0 +1,187 @@
+package anysgd
+
+import (
+	"math"
+	"sort"
+)
+
+// HashSplitN partitions a Hasher into len(ratios) lists.
+// It generalizes HashSplit to more than two partitions.
+//
+// The i-th ratio specifies the expected fraction of
+// samples that should end up in the i-th partition.
+// The ratios are normalized so that they sum to 1.
+//
+// The Hasher h will be re-ordered as needed for internal
+// computations.
+func HashSplitN(h Hasher, ratios ...float64) []SampleList {
+	return partitionGroups(h, hashGroups(h, ratios), len(ratios))
+}
+
+// HashSplit3 uses HashSplitN to split a Hasher into
+// training, validation, and testing samples.
+//
+// The testing samples get the fraction of the data that
+// is left over by trainRatio and validRatio.
+func HashSplit3(h Hasher, trainRatio, validRatio float64) (train, valid,
+	test SampleList) {
+	testRatio := math.Max(0, 1-(trainRatio+validRatio))
+	res := HashSplitN(h, trainRatio, validRatio, testRatio)
+	return res[0], res[1], res[2]
+}
+
+// StratifiedSplit is like HashSplitN, except that it
+// splits each class separately so that every partition
+// has the same class balance.
+//
+// The label function returns the class of the sample at
+// the given index of h.
+// It is only called before h is re-ordered.
+//
+// Within each class, samples are assigned to partitions
+// in order of their hashes, so the split is deterministic
+// and the partition sizes are as close to the ratios as
+// possible.
+func StratifiedSplit(h Hasher, label func(i int) string,
+	ratios ...float64) []SampleList {
+	return partitionGroups(h, stratifiedGroups(h, label, ratios), len(ratios))
+}
+
+// A Fold is one train/validation split from k-fold
+// cross-validation.
+type Fold struct {
+	Train      SampleList
+	Validation SampleList
+}
+
+// KFold deterministically partitions a Hasher into k
+// folds of roughly equal size.
+// The i-th Fold uses the i-th partition for validation
+// and the remaining partitions for training.
+//
+// The Hasher h will be re-ordered as needed for internal
+// computations.
+func KFold(h Hasher, k int) []*Fold {
+	return foldsForGroups(h, hashGroups(h, equalRatios(k)), k)
+}
+
+// StratifiedKFold is like KFold, except that every fold
+// has the same class balance.
+// See StratifiedSplit for more on the label function.
+func StratifiedKFold(h Hasher, k int, label func(i int) string) []*Fold {
+	return foldsForGroups(h, stratifiedGroups(h, label, equalRatios(k)), k)
+}
+
+// hashGroups assigns every sample in h to a partition
+// based on its hash.
+func hashGroups(h Hasher, ratios []float64) []int {
+	var cutoffs [][]byte
+	var cumulative float64
+	sum := sumRatios(ratios)
+	for _, r := range ratios[:len(ratios)-1] {
+		cumulative += r
+		cutoffs = append(cutoffs, hashCutoff(math.Min(1, cumulative/sum)))
+	}
+	groups := make([]int, h.Len())
+	for i := range groups {
+		hash := h.Hash(i)
+		groups[i] = sort.Search(len(cutoffs), func(j int) bool {
+			return compareHashes(hash, cutoffs[j]) < 0
+		})
+	}
+	return groups
+}
+
+// stratifiedGroups assigns every sample in h to a
+// partition so that each class is split according to the
+// ratios.
+func stratifiedGroups(h Hasher, label func(i int) string, ratios []float64) []int {
+	sum := sumRatios(ratios)
+	classes := map[string][]int{}
+	hashes := make([][]byte, h.Len())
+	for i := range hashes {
+		hashes[i] = h.Hash(i)
+		class := label(i)
+		classes[class] = append(classes[class], i)
+	}
+
+	groups := make([]int, h.Len())
+	for _, indices := range classes {
+		sort.SliceStable(indices, func(i, j int) bool {
+			return compareHashes(hashes[indices[i]], hashes[indices[j]]) < 0
+		})
+		var start int
+		var cumulative float64
+		for group, r := range ratios {
+			cumulative += r
+			end := int(float64(len(indices))*cumulative/sum + 0.5)
+			if group == len(ratios)-1 {
+				end = len(indices)
+			}
+			for _, idx := range indices[start:end] {
+				groups[idx] = group
+			}
+			start = end
+		}
+	}
+	return groups
+}
+
+// partitionGroups re-orders h so that the samples are
+// sorted by group, and then returns one list per group.
+//
+// The groups slice is re-ordered along with h.
+func partitionGroups(h SampleList, groups []int, numGroups int) []SampleList {
+	res := make([]SampleList, numGroups)
+	var start int
+	for group := 0; group < numGroups; group++ {
+		end := start
+		for i := start; i < len(groups); i++ {
+			if groups[i] == group {
+				h.Swap(end, i)
+				groups[end], groups[i] = groups[i], groups[end]
+				end++
+			}
+		}
+		res[group] = h.Slice(start, end)
+		start = end
+	}
+	return res
+}
+
+// foldsForGroups creates one Fold per group.
+func foldsForGroups(h SampleList, groups []int, k int) []*Fold {
+	res := make([]*Fold, k)
+	for fold := range res {
+		// Move the validation samples to the end of h.
+		end := len(groups)
+		for i := len(groups) - 1; i >= 0; i-- {
+			if groups[i] == fold {
+				end--
+				h.Swap(end, i)
+				groups[end], groups[i] = groups[i], groups[end]
+			}
+		}
+		res[fold] = &Fold{
+			Train:      h.Slice(0, end),
+			Validation: h.Slice(end, h.Len()),
+		}
+	}
+	return res
+}
+
+func equalRatios(n int) []float64 {
+	res := make([]float64, n)
+	for i := range res {
+		res[i] = 1
+	}
+	return res
+}
+
+func sumRatios(ratios []float64) float64 {
+	var sum float64
+	for _, r := range ratios {
+		sum += r
+	}
+	return sum
+}
@@ -0,0 +1,193 @@
+package anysgd
+
+import (
+	"crypto/md5"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func TestHashSplitN(t *testing.T) {
+	list := newTestHasher(3000)
+	parts := HashSplitN(list, 0.5, 0.3, 0.2)
+	checkPartition(t, parts, 3000)
+	for i, expected := range []float64{0.5, 0.3, 0.2} {
+		frac := float64(parts[i].Len()) / 3000
+		if math.Abs(frac-expected) > 0.05 {
+			t.Errorf("partition %d: expected fraction %f but got %f", i, expected, frac)
+		}
+	}
+
+	Shuffle(list)
+	train, valid, test := HashSplit3(list, 0.5, 0.3)
+	for i, part := range []SampleList{train, valid, test} {
+		if !sameSamples(part.(testHasher), parts[i].(testHasher)) {
+			t.Errorf("partition %d is not deterministic", i)
+		}
+	}
+}
+
+func TestStratifiedSplit(t *testing.T) {
+	list := newTestHasher(300)
+	label := func(i int) string {
+		return strconv.Itoa(list[i] % 3)
+	}
+	parts := StratifiedSplit(list, label, 0.8, 0.2)
+	checkPartition(t, parts, 300)
+	for i, expected := range []int{80, 20} {
+		counts := map[int]int{}
+		for _, x := range parts[i].(testHasher) {
+			counts[x%3]++
+		}
+		for class := 0; class < 3; class++ {
+			if counts[class] != expected {
+				t.Errorf("partition %d class %d: expected %d but got %d", i, class,
+					expected, counts[class])
+			}
+		}
+	}
+}
+
+func TestKFold(t *testing.T) {
+	list := newTestHasher(500)
+	for _, stratified := range []bool{false, true} {
+		var folds []*Fold
+		if stratified {
+			folds = StratifiedKFold(list, 5, func(i int) string {
+				return strconv.Itoa(list[i] % 2)
+			})
+		} else {
+			folds = KFold(list, 5)
+		}
+		if len(folds) != 5 {
+			t.Fatalf("expected 5 folds but got %d", len(folds))
+		}
+		var validations []SampleList
+		for i, fold := range folds {
+			checkPartition(t, []SampleList{fold.Train, fold.Validation}, 500)
+			if fold.Validation.Len() < 50 || fold.Validation.Len() > 150 {
+				t.Errorf("fold %d: bad validation size %d", i, fold.Validation.Len())
+			}
+			if stratified && fold.Validation.Len() != 100 {
+				t.Errorf("fold %d: expected 100 validation samples but got %d", i,
+					fold.Validation.Len())
+			}
+			validations = append(validations, fold.Validation)
+		}
+		checkPartition(t, validations, 500)
+	}
+}
+
+func TestCrossValidator(t *testing.T) {
+	var trained []int
+	cv := &CrossValidator{
+		Samples:  newTestHasher(100),
+		NumFolds: 4,
+		NewModel: func(fold int) (FoldModel, error) {
+			return &testFoldModel{Fold: fold, Trained: &trained}, nil
+		},
+	}
+	res, err := cv.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Folds) != 4 {
+		t.Fatalf("expected 4 folds but got %d", len(res.Folds))
+	}
+	var total float64
+	for i, metrics := range res.Folds {
+		if metrics["fold"] != float64(i) {
+			t.Errorf("fold %d: got metrics for fold %f", i, metrics["fold"])
+		}
+		if float64(trained[i])+metrics["count"] != 100 {
+			t.Errorf("fold %d: trained on %d and evaluated on %f", i, trained[i],
+				metrics["count"])
+		}
+		total += metrics["count"]
+	}
+	if mean := res.Mean("count"); math.Abs(mean-total/4) > 1e-8 {
+		t.Errorf("expected mean %f but got %f", total/4, mean)
+	}
+	if res.Stddev("fold") < 1 || res.Stddev("fold") > 1.2 {
+		t.Errorf("unexpected standard deviation: %f", res.Stddev("fold"))
+	}
+}
+
+type testHasher []int
+
+func newTestHasher(n int) testHasher {
+	res := make(testHasher, n)
+	for i := range res {
+		res[i] = i
+	}
+	return res
+}
+
+func (t testHasher) Len() int {
+	return len(t)
+}
+
+func (t testHasher) Swap(i, j int) {
+	t[i], t[j] = t[j], t[i]
+}
+
+func (t testHasher) Slice(i, j int) SampleList {
+	return append(testHasher{}, t[i:j]...)
+}
+
+func (t testHasher) Hash(i int) []byte {
+	hash := md5.Sum([]byte(fmt.Sprint(t[i])))
+	return hash[:]
+}
+
+type testFoldModel struct {
+	Fold    int
+	Trained *[]int
+}
+
+func (t *testFoldModel) Train(s SampleList) error {
+	*t.Trained = append(*t.Trained, s.Len())
+	return nil
+}
+
+func (t *testFoldModel) Evaluate(s SampleList) (map[string]float64, error) {
+	return map[string]float64{"fold": float64(t.Fold), "count": float64(s.Len())}, nil
+}
+
+// checkPartition makes sure that every sample from
+// 0 to n-1 appears in exactly one of the parts.
+func checkPartition(t *testing.T, parts []SampleList, n int) {
+	var all []int
+	for _, part := range parts {
+		all = append(all, part.(testHasher)...)
+	}
+	sort.Ints(all)
+	if len(all) != n {
+		t.Errorf("expected %d samples but got %d", n, len(all))
+		return
+	}
+	for i, x := range all {
+		if x != i {
+			t.Errorf("bad partition: missing sample %d", i)
+			return
+		}
+	}
+}
+
+func sameSamples(l1, l2 testHasher) bool {
+	if len(l1) != len(l2) {
+		return false
+	}
+	s1 := append([]int{}, l1...)
+	s2 := append([]int{}, l2...)
+	sort.Ints(s1)
+	sort.Ints(s2)
+	for i, x := range s1 {
+		if s2[i] != x {
+			return false
+		}
+	}
+	return true
+}
@@ -5,6 +5,7 @@ import (
 	"math/rand"
 
 	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet/internal/randutil"
 	"github.com/unixpickle/anyvec"
 )
 
@@ -12,11 +13,24 @@ import (
 // If the list implements PostShuffler, then PostShuffle
 // is called after the shuffle completes.
 func Shuffle(s SampleList) {
+	ShuffleRand(s, nil)
+}
+
+// ShuffleRand is like Shuffle, but it uses the given
+// source of randomness.
+// If r is nil, the global source from math/rand is used.
+//
+// If the list implements RandPostShuffler, then
+// PostShuffleRand is called with r instead of calling
+// PostShuffle.
+func ShuffleRand(s SampleList, r *rand.Rand) {
 	for i := 0; i < s.Len(); i++ {
-		j := i + rand.Intn(s.Len()-i)
+		j := i + randutil.Intn(r, s.Len()-i)
 		s.Swap(i, j)
 	}
-	if p, ok := s.(PostShuffler); ok {
+	if p, ok := s.(RandPostShuffler); ok {
+		p.PostShuffleRand(r)
+	} else if p, ok := s.(PostShuffler); ok {
 		p.PostShuffle()
 	}
 }
@@ -0,0 +1,161 @@
+package anysgd
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/unixpickle/anynet/internal/randutil"
+)
+
+// A SubsetSampleList is a SampleList that can produce a
+// shallow copy of an arbitrary subset of its samples.
+type SubsetSampleList interface {
+	SampleList
+
+	// Subset creates a list containing the samples at the
+	// given indices, in order.
+	// Indices may be repeated.
+	Subset(indices []int) SampleList
+}
+
+// A WeightedSampleList draws samples with replacement
+// from another list, where the probability of drawing a
+// sample is proportional to its weight.
+//
+// Every time the list is shuffled, a new set of samples
+// is drawn.
+// Thus, the length of the list is the number of samples
+// per epoch, and SGD's epoch counter tracks passes over
+// the drawn samples rather than the underlying list.
+type WeightedSampleList struct {
+	Samples SubsetSampleList
+
+	// Weights stores a non-negative weight for each
+	// sample in Samples.
+	Weights []float64
+
+	// EpochSize is the number of samples to draw for each
+	// epoch.
+	// If it is 0, Samples.Len() is used.
+	EpochSize int
+
+	indices []int
+}
+
+// Len returns the number of samples per epoch.
+func (w *WeightedSampleList) Len() int {
+	if w.EpochSize == 0 {
+		return w.Samples.Len()
+	}
+	return w.EpochSize
+}
+
+// Swap swaps two drawn samples.
+//
+// If no samples have been drawn yet, Swap does nothing,
+// since the order of independent draws does not matter.
+// This way, shuffling a new list only draws samples once,
+// in PostShuffle or PostShuffleRand.
+func (w *WeightedSampleList) Swap(i, j int) {
+	if w.indices == nil {
+		return
+	}
+	w.indices[i], w.indices[j] = w.indices[j], w.indices[i]
+}
+
+// Slice produces a list of drawn samples using
+// Samples.Subset.
+func (w *WeightedSampleList) Slice(i, j int) SampleList {
+	w.draw(nil)
+	return w.Samples.Subset(w.indices[i:j])
+}
+
+// PostShuffle draws a new set of samples.
+func (w *WeightedSampleList) PostShuffle() {
+	w.PostShuffleRand(nil)
+}
+
+// PostShuffleRand draws a new set of samples using the
+// given source of randomness.
+func (w *WeightedSampleList) PostShuffleRand(r *rand.Rand) {
+	w.indices = nil
+	w.draw(r)
+}
+
+// Indices returns the indices of the currently drawn
+// samples in Samples.
+func (w *WeightedSampleList) Indices() []int {
+	w.draw(nil)
+	return append([]int{}, w.indices...)
+}
+
+func (w *WeightedSampleList) draw(r *rand.Rand) {
+	if w.indices != nil {
+		return
+	}
+	if len(w.Weights) != w.Samples.Len() {
+		panic("weight count does not match sample count")
+	}
+	cumulative := make([]float64, len(w.Weights))
+	var total float64
+	for i, weight := range w.Weights {
+		if weight < 0 {
+			panic("weights must be non-negative")
+		}
+		total += weight
+		cumulative[i] = total
+	}
+	if total == 0 {
+		panic("weights must not all be zero")
+	}
+	w.indices = make([]int, w.Len())
+	for i := range w.indices {
+		x := randutil.Float64(r) * total
+		idx := sort.Search(len(cumulative), func(j int) bool {
+			return cumulative[j] > x
+		})
+		if idx == len(cumulative) {
+			idx--
+		}
+		w.indices[i] = idx
+	}
+}
+
+// ClassWeights computes sample weights that give every
+// class the same total weight.
+//
+// The label function returns the class of the sample at
+// the given index of s.
+func ClassWeights(s SampleList, label func(i int) string) []float64 {
+	return ClassPowerWeights(s, label, 1)
+}
+
+// ClassPowerWeights computes sample weights that are
+// proportional to count^-power, where count is the number
+// of samples in the sample's class.
+//
+// A power of 1 balances the classes, a power of 0 gives
+// every sample the same weight, and a power in between
+// partially balances the classes.
+//
+// The resulting weights average to 1.
+func ClassPowerWeights(s SampleList, label func(i int) string,
+	power float64) []float64 {
+	labels := make([]string, s.Len())
+	counts := map[string]int{}
+	for i := range labels {
+		labels[i] = label(i)
+		counts[labels[i]]++
+	}
+	res := make([]float64, len(labels))
+	var sum float64
+	for i, l := range labels {
+		res[i] = math.Pow(float64(counts[l]), -power)
+		sum += res[i]
+	}
+	for i := range res {
+		res[i] *= float64(len(res)) / sum
+	}
+	return res
+}
@@ -0,0 +1,91 @@
+package anysgd
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestWeightedSampleList(t *testing.T) {
+	list := &WeightedSampleList{
+		Samples:   testLenList{0, 1, 2, 3},
+		Weights:   []float64{1, 0, 2, 1},
+		EpochSize: 1000,
+	}
+	if list.Len() != 1000 {
+		t.Fatalf("expected length 1000 but got %d", list.Len())
+	}
+
+	counts := make([]float64, 4)
+	for epoch := 0; epoch < 20; epoch++ {
+		Shuffle(list)
+		for _, x := range list.Slice(0, list.Len()).(testLenList) {
+			counts[x]++
+		}
+	}
+	for i, expected := range []float64{0.25, 0, 0.5, 0.25} {
+		actual := counts[i] / 20000
+		if math.Abs(actual-expected) > 0.02 {
+			t.Errorf("sample %d: expected frequency %f but got %f", i, expected, actual)
+		}
+	}
+
+	indices := list.Indices()
+	slice := list.Slice(10, 20).(testLenList)
+	for i, x := range slice {
+		if x != indices[i+10] {
+			t.Errorf("slice index %d: expected %d but got %d", i, indices[i+10], x)
+		}
+	}
+}
+
+func TestWeightedSampleListShuffleRand(t *testing.T) {
+	var indices [][]int
+	for i := 0; i < 2; i++ {
+		list := &WeightedSampleList{
+			Samples: testLenList{0, 1, 2, 3},
+			Weights: []float64{1, 0, 2, 1},
+		}
+		list.Swap(0, 1)
+		if list.indices != nil {
+			t.Fatal("Swap should not draw samples")
+		}
+		ShuffleRand(list, rand.New(rand.NewSource(1337)))
+		indices = append(indices, list.Indices())
+	}
+	if !reflect.DeepEqual(indices[0], indices[1]) {
+		t.Errorf("seeded shuffles differ: %v and %v", indices[0], indices[1])
+	}
+}
+
+func TestClassWeights(t *testing.T) {
+	list := testLenList{0, 0, 0, 1}
+	label := func(i int) string {
+		return string('a' + rune(list[i]))
+	}
+	actual := ClassWeights(list, label)
+	expected := []float64{2.0 / 3, 2.0 / 3, 2.0 / 3, 2}
+	for i, x := range expected {
+		if math.Abs(actual[i]-x) > 1e-8 {
+			t.Errorf("expected %v but got %v", expected, actual)
+			break
+		}
+	}
+
+	actual = ClassPowerWeights(list, label, 0)
+	for _, x := range actual {
+		if math.Abs(x-1) > 1e-8 {
+			t.Errorf("expected uniform weights but got %v", actual)
+			break
+		}
+	}
+}
+
+func (t testLenList) Subset(indices []int) SampleList {
+	res := make(testLenList, len(indices))
+	for i, idx := range indices {
+		res[i] = t[idx]
+	}
+	return res
+}
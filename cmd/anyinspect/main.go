@@ -0,0 +1,85 @@
+// Command anyinspect prints the contents of a serialized
+// model, such as an anynet.Net or an anyrnn.Stack.
+//
+// The file is decoded with the deserializers registered
+// by the anynet packages, so it works for anything saved
+// with serializer.SaveAny or serializer.SerializeWithType.
+// For each object, the layer tree is printed along with
+// layer configurations, parameter counts, and statistics
+// for every parameter.
+//
+// Usage:
+//
+//     anyinspect [-nostats] <file> [file ...]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+
+	// Register the deserializers from every package.
+	_ "github.com/unixpickle/anynet/anyconv"
+	_ "github.com/unixpickle/anynet/anymisc"
+	_ "github.com/unixpickle/anynet/anyrnn"
+	_ "github.com/unixpickle/anynet/anyrnnt"
+	_ "github.com/unixpickle/anynet/anys2v"
+)
+
+func main() {
+	var noStats bool
+	flag.BoolVar(&noStats, "nostats", false, "omit per-parameter statistics")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: anyinspect [flags] <file> [file ...]")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Flags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	for i, path := range flag.Args() {
+		if flag.NArg() > 1 {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Println(path + ":")
+		}
+		objs, err := loadObjects(path)
+		if err != nil {
+			essentials.Die(err)
+		}
+		for _, obj := range objs {
+			PrintTree(os.Stdout, BuildTree("", obj), !noStats)
+		}
+	}
+}
+
+// loadObjects decodes every object in a file.
+//
+// Files written by serializer.SaveAny contain a list of
+// objects, while files written by SerializeWithType
+// contain a single object.
+func loadObjects(path string) (objs []interface{}, err error) {
+	defer essentials.AddCtxTo("load "+path, &err)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if objs, err := serializer.DeserializeSlice(data); err == nil {
+		return objs, nil
+	}
+	obj, err := serializer.DeserializeWithType(data)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{obj}, nil
+}
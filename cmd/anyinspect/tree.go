@@ -0,0 +1,309 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyconv"
+	"github.com/unixpickle/anynet/anymisc"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anynet/anyrnnt"
+	"github.com/unixpickle/anynet/anys2v"
+)
+
+// A Node is an entry in the layer tree of a model.
+type Node struct {
+	// Label describes the role of the node in its parent,
+	// such as "[2]" or "Forward".
+	Label string
+
+	// Type is the Go type of the object.
+	Type string
+
+	// Config summarizes the configuration of the object.
+	Config string
+
+	Params   []*Param
+	Children []*Node
+}
+
+// A Param is a named parameter of a Node.
+type Param struct {
+	Name string
+	Var  *anydiff.Var
+}
+
+// ParamCount returns the total number of parameters in
+// the node and all of its children.
+func (n *Node) ParamCount() int {
+	var res int
+	for _, p := range n.Params {
+		res += p.Var.Vector.Len()
+	}
+	for _, child := range n.Children {
+		res += child.ParamCount()
+	}
+	return res
+}
+
+// BuildTree creates the layer tree for an object.
+//
+// Objects of unknown types become leaves, and their
+// parameters are found through anynet.Parameterizer.
+func BuildTree(label string, obj interface{}) *Node {
+	n := &Node{Label: label, Type: strings.TrimPrefix(fmt.Sprintf("%T", obj), "*")}
+	switch obj := obj.(type) {
+	case anynet.Net:
+		for i, layer := range obj {
+			n.addChild(fmt.Sprintf("[%d]", i), layer)
+		}
+	case anyrnn.Stack:
+		for i, block := range obj {
+			n.addChild(fmt.Sprintf("[%d]", i), block)
+		}
+	case anyrnn.SeqStack:
+		for i, layer := range obj {
+			n.addChild(fmt.Sprintf("[%d]", i), layer)
+		}
+	case *anynet.FC:
+		n.Config = fmt.Sprintf("in=%d out=%d", obj.InCount, obj.OutCount)
+		n.addParam("Weights", obj.Weights)
+		n.addParam("Biases", obj.Biases)
+	case *anynet.Affine:
+		n.addParam("Scalers", obj.Scalers)
+		n.addParam("Biases", obj.Biases)
+	case *anynet.ConstAffine:
+		n.Config = fmt.Sprintf("scale=%g bias=%g", obj.Scale, obj.Bias)
+	case anynet.Activation:
+		n.Config = activationName(obj)
+	case *anynet.Dropout:
+		n.Config = fmt.Sprintf("keep=%g enabled=%v", obj.KeepProb, obj.Enabled)
+	case *anynet.ParamHider:
+		n.addChild("Layer", obj.Layer)
+	case *anynet.AddMixer:
+		n.addChild("In1", obj.In1)
+		n.addChild("In2", obj.In2)
+		n.addChild("Out", obj.Out)
+	case *anyconv.Conv:
+		n.Config = fmt.Sprintf("filters=%d size=%dx%d stride=%dx%d input=%dx%dx%d",
+			obj.FilterCount, obj.FilterWidth, obj.FilterHeight, obj.StrideX, obj.StrideY,
+			obj.InputWidth, obj.InputHeight, obj.InputDepth)
+		n.addParam("Filters", obj.Filters)
+		n.addParam("Biases", obj.Biases)
+	case *anyconv.MaxPool:
+		n.Config = poolConfig(obj.SpanX, obj.SpanY, obj.StrideX, obj.StrideY,
+			obj.InputWidth, obj.InputHeight, obj.InputDepth)
+	case *anyconv.MeanPool:
+		n.Config = poolConfig(obj.SpanX, obj.SpanY, obj.StrideX, obj.StrideY,
+			obj.InputWidth, obj.InputHeight, obj.InputDepth)
+	case *anyconv.Padding:
+		n.Config = fmt.Sprintf("input=%dx%dx%d top=%d right=%d bottom=%d left=%d",
+			obj.InputWidth, obj.InputHeight, obj.InputDepth, obj.PaddingTop,
+			obj.PaddingRight, obj.PaddingBottom, obj.PaddingLeft)
+	case *anyconv.Resize:
+		n.Config = fmt.Sprintf("input=%dx%dx%d output=%dx%dx%d", obj.InputWidth,
+			obj.InputHeight, obj.Depth, obj.OutputWidth, obj.OutputHeight, obj.Depth)
+	case *anyconv.BatchNorm:
+		n.Config = fmt.Sprintf("inputs=%d stabilizer=%g", obj.InputCount, obj.Stabilizer)
+		n.addParam("Scalers", obj.Scalers)
+		n.addParam("Biases", obj.Biases)
+	case *anyconv.Residual:
+		n.addChild("Layer", obj.Layer)
+		n.addChild("Projection", obj.Projection)
+	case *anyrnn.LSTM:
+		n.Config = lstmConfig(obj)
+		n.addChild("In", obj.In)
+		n.addChild("Remember", obj.Remember)
+		n.addChild("InValue", obj.InValue)
+		n.addChild("Output", obj.Output)
+		n.addChild("OutSquash", obj.OutSquash)
+		n.addParam("InitLastOut", obj.InitLastOut)
+		n.addParam("InitInternal", obj.InitInternal)
+	case *anyrnn.LSTMGate:
+		n.addParam("InputWeights", obj.InputWeights)
+		n.addParam("StateWeights", obj.StateWeights)
+		n.addParam("Peephole", obj.Peephole)
+		n.addParam("Biases", obj.Biases)
+		n.addChild("Activation", obj.Activation)
+	case *anyrnn.Vanilla:
+		n.Config = fmt.Sprintf("in=%d out=%d", obj.InCount, obj.OutCount)
+		if obj.Dropout.Enabled {
+			n.Config += fmt.Sprintf(" dropout=%g/%g", obj.Dropout.InKeepProb,
+				obj.Dropout.StateKeepProb)
+		}
+		n.addParam("InputWeights", obj.InputWeights)
+		n.addParam("StateWeights", obj.StateWeights)
+		n.addParam("Biases", obj.Biases)
+		n.addParam("StartState", obj.StartState)
+		n.addChild("Activation", obj.Activation)
+	case *anyrnn.LayerBlock:
+		n.addChild("Layer", obj.Layer)
+	case *anyrnn.MapBlock:
+		n.addChild("Block", obj.Block)
+	case *anyrnn.Residual:
+		n.addChild("Block", obj.Block)
+		n.addChild("Projection", obj.Projection)
+	case *anyrnn.Highway:
+		n.addChild("Block", obj.Block)
+		n.addChild("Gate", obj.Gate)
+		n.addChild("Projection", obj.Projection)
+	case *anyrnn.Bidir:
+		n.addChild("Forward", obj.Forward)
+		n.addChild("Backward", obj.Backward)
+		n.addChild("Mixer", obj.Mixer)
+	case *anyrnn.Parallel:
+		n.addChild("Block1", obj.Block1)
+		n.addChild("Block2", obj.Block2)
+		n.addChild("Mixer", obj.Mixer)
+	case *anyrnn.Feedback:
+		n.addChild("Mixer", obj.Mixer)
+		n.addChild("Block", obj.Block)
+		n.addParam("InitOut", obj.InitOut)
+	case *anyrnn.Markov:
+		n.Config = fmt.Sprintf("history=%d depthwise=%v", obj.HistorySize, obj.DepthWise)
+		n.addParam("StartState", obj.StartState)
+	case *anyrnnt.Model:
+		n.Config = fmt.Sprintf("labels=%d", obj.NumLabels)
+		n.addChild("Predictor", obj.Predictor)
+		n.addChild("Joint", obj.Joint)
+	case *anys2v.AttentionPool:
+		n.addChild("Scorer", obj.Scorer)
+	case *anymisc.SELU:
+		n.Config = fmt.Sprintf("alpha=%g lambda=%g", obj.Alpha, obj.Lambda)
+	case *anymisc.GumbelSoftmax:
+		n.Config = fmt.Sprintf("temperature=%g", obj.Temperature)
+	case anynet.Parameterizer:
+		for i, p := range obj.Parameters() {
+			n.addParam(fmt.Sprintf("[%d]", i), p)
+		}
+	}
+	return n
+}
+
+// PrintTree writes a human-readable layer tree.
+//
+// If stats is true, the mean, standard deviation, and
+// maximum absolute value of every parameter is printed.
+func PrintTree(w io.Writer, n *Node, stats bool) {
+	printNode(w, n, "", stats)
+}
+
+func printNode(w io.Writer, n *Node, indent string, stats bool) {
+	line := indent
+	if n.Label != "" {
+		line += n.Label + " "
+	}
+	line += n.Type
+	if n.Config != "" {
+		line += " (" + n.Config + ")"
+	}
+	if count := n.ParamCount(); count > 0 {
+		line += fmt.Sprintf(" params=%d", count)
+	}
+	fmt.Fprintln(w, line)
+
+	for _, p := range n.Params {
+		line := fmt.Sprintf("%s  - %s: %d", indent, p.Name, p.Var.Vector.Len())
+		if stats {
+			mean, std, maxAbs := varStats(p.Var)
+			line += fmt.Sprintf(" mean=%.4g std=%.4g maxabs=%.4g", mean, std, maxAbs)
+		}
+		fmt.Fprintln(w, line)
+	}
+	for _, child := range n.Children {
+		printNode(w, child, indent+"  ", stats)
+	}
+}
+
+// addChild adds a subtree for obj, unless obj is nil or a
+// nil pointer (such as an unset *anynet.FC field stored
+// in an interface).
+func (n *Node) addChild(label string, obj interface{}) {
+	if obj == nil {
+		return
+	}
+	if v := reflect.ValueOf(obj); v.Kind() == reflect.Ptr && v.IsNil() {
+		return
+	}
+	n.Children = append(n.Children, BuildTree(label, obj))
+}
+
+func (n *Node) addParam(name string, v *anydiff.Var) {
+	if v != nil {
+		n.Params = append(n.Params, &Param{Name: name, Var: v})
+	}
+}
+
+func activationName(a anynet.Activation) string {
+	switch a {
+	case anynet.Tanh:
+		return "Tanh"
+	case anynet.LogSoftmax:
+		return "LogSoftmax"
+	case anynet.Sigmoid:
+		return "Sigmoid"
+	case anynet.ReLU:
+		return "ReLU"
+	case anynet.Sin:
+		return "Sin"
+	case anynet.Exp:
+		return "Exp"
+	default:
+		return fmt.Sprintf("activation %d", a)
+	}
+}
+
+func poolConfig(spanX, spanY, strideX, strideY, width, height, depth int) string {
+	return fmt.Sprintf("span=%dx%d stride=%dx%d input=%dx%dx%d", spanX, spanY,
+		strideX, strideY, width, height, depth)
+}
+
+func lstmConfig(l *anyrnn.LSTM) string {
+	if l.In == nil || l.In.Biases == nil || l.In.InputWeights == nil {
+		return ""
+	}
+	state := l.In.Biases.Vector.Len()
+	res := fmt.Sprintf("in=%d state=%d", l.In.InputWeights.Vector.Len()/state, state)
+	if l.Dropout.Enabled {
+		res += fmt.Sprintf(" dropout=%g/%g", l.Dropout.InKeepProb, l.Dropout.StateKeepProb)
+	}
+	if l.Zoneout.Enabled {
+		res += fmt.Sprintf(" zoneout=%g/%g", l.Zoneout.CellProb, l.Zoneout.HiddenProb)
+	}
+	return res
+}
+
+// varStats computes the mean, standard deviation, and
+// maximum absolute value of a variable.
+func varStats(v *anydiff.Var) (mean, std, maxAbs float64) {
+	var data []float64
+	switch vecData := v.Vector.Data().(type) {
+	case []float32:
+		data = make([]float64, len(vecData))
+		for i, x := range vecData {
+			data[i] = float64(x)
+		}
+	case []float64:
+		data = vecData
+	default:
+		panic(fmt.Sprintf("unsupported numeric type: %T", vecData))
+	}
+	if len(data) == 0 {
+		return
+	}
+	for _, x := range data {
+		mean += x
+		maxAbs = math.Max(maxAbs, math.Abs(x))
+	}
+	mean /= float64(len(data))
+	for _, x := range data {
+		std += (x - mean) * (x - mean)
+	}
+	std = math.Sqrt(std / float64(len(data)))
+	return
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyconv"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestBuildTreeNet(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	net := anynet.Net{
+		&anyconv.Residual{
+			Layer: anynet.NewFC(c, 3, 3),
+		},
+		anynet.Tanh,
+		anynet.NewFC(c, 3, 2),
+	}
+	tree := BuildTree("", net)
+	if tree.Type != "anynet.Net" {
+		t.Errorf("unexpected type: %s", tree.Type)
+	}
+	if count := tree.ParamCount(); count != 12+8 {
+		t.Errorf("expected 20 parameters but got %d", count)
+	}
+	if len(tree.Children) != 3 {
+		t.Fatalf("expected 3 children but got %d", len(tree.Children))
+	}
+	if len(tree.Children[0].Children) != 1 {
+		t.Errorf("expected residual to have 1 child but got %d",
+			len(tree.Children[0].Children))
+	}
+	if tree.Children[1].Config != "Tanh" {
+		t.Errorf("unexpected activation config: %s", tree.Children[1].Config)
+	}
+	fc := tree.Children[2]
+	if fc.Config != "in=3 out=2" {
+		t.Errorf("unexpected FC config: %s", fc.Config)
+	}
+	if len(fc.Params) != 2 || fc.Params[0].Name != "Weights" ||
+		fc.Params[1].Name != "Biases" {
+		t.Errorf("unexpected FC parameters: %v", fc.Params)
+	}
+
+	var buf bytes.Buffer
+	PrintTree(&buf, tree, true)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 9 {
+		t.Errorf("expected 9 lines but got:\n%s", buf.String())
+	}
+	if lines[0] != "anynet.Net params=20" {
+		t.Errorf("unexpected first line: %s", lines[0])
+	}
+}
+
+func TestBuildTreeLSTM(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	stack := anyrnn.Stack{
+		anyrnn.NewLSTM(c, 4, 3),
+		&anyrnn.LayerBlock{Layer: anynet.NewFC(c, 3, 2)},
+	}
+	tree := BuildTree("", stack)
+	lstm := tree.Children[0]
+	if lstm.Config != "in=4 state=3" {
+		t.Errorf("unexpected LSTM config: %s", lstm.Config)
+	}
+	gateParams := 3*4 + 3*3 + 3 + 3
+	if count := lstm.ParamCount(); count != 4*gateParams+2*3 {
+		t.Errorf("unexpected LSTM parameter count: %d", count)
+	}
+	if count := tree.ParamCount(); count != 4*gateParams+2*3+8 {
+		t.Errorf("unexpected total parameter count: %d", count)
+	}
+}
+
+func TestBuildTreeNilChildren(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	var projection *anynet.FC
+	res := &anyconv.Residual{
+		Layer:      anynet.NewFC(c, 3, 3),
+		Projection: projection,
+	}
+	tree := BuildTree("", res)
+	if len(tree.Children) != 1 || tree.Children[0].Label != "Layer" {
+		t.Errorf("unexpected children: %v", tree.Children)
+	}
+	// Every gate of an empty LSTM is a nil pointer.
+	if tree := BuildTree("", &anyrnn.LSTM{}); len(tree.Children) != 0 {
+		t.Errorf("expected no children but got %d", len(tree.Children))
+	}
+}
+
+func TestVarStats(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	v := anydiff.NewVar(c.MakeVectorData([]float64{1, -3, 2, 4}))
+	mean, std, maxAbs := varStats(v)
+	if math.Abs(mean-1) > 1e-8 {
+		t.Errorf("expected mean 1 but got %f", mean)
+	}
+	if expected := math.Sqrt(6.5); math.Abs(std-expected) > 1e-8 {
+		t.Errorf("expected std %f but got %f", expected, std)
+	}
+	if maxAbs != 4 {
+		t.Errorf("expected max abs 4 but got %f", maxAbs)
+	}
+}
@@ -22,13 +22,21 @@ type Cost interface {
 // When you dot the output of a LogSoftmax with the
 // desired probabilities, you get an unbiased measure of
 // cross-entropy error.
-type DotCost struct{}
+type DotCost struct {
+	// If non-nil, ClassWeights specifies a weight for each
+	// component of the output vectors.
+	// Each term of the dot product is scaled by the weight
+	// of its component.
+	// With one-hot desired outputs, this scales the cost of
+	// each sample by the weight of its desired class.
+	ClassWeights anyvec.Vector
+}
 
 // Cost takes the dot product of each actual output with
 // each desired output, negates it, and uses that as the
 // cost.
 func (d DotCost) Cost(desired, actual anydiff.Res, n int) anydiff.Res {
-	comb := anydiff.Mul(desired, actual)
+	comb := applyClassWeights(anydiff.Mul(desired, actual), d.ClassWeights)
 	dots := anydiff.SumCols(&anydiff.Matrix{
 		Data: comb,
 		Rows: n,
@@ -62,7 +70,16 @@ func (m MSE) Cost(desired, actual anydiff.Res, n int) anydiff.Res {
 type SigmoidCE struct {
 	// Average indicates whether or not the cross-entropy
 	// cost should be an average rather than a sum.
+	//
+	// The average is always taken over the number of
+	// output components, regardless of ClassWeights.
 	Average bool
+
+	// If non-nil, ClassWeights specifies a weight for each
+	// component of the output vectors.
+	// The cross-entropy loss for each component is scaled
+	// by the component's weight.
+	ClassWeights anyvec.Vector
 }
 
 // Cost is mathematically equivalent to applying the
@@ -80,6 +97,7 @@ func (s SigmoidCE) Cost(desired, actual anydiff.Res, n int) anydiff.Res {
 			)
 		})
 	})
+	costProducts = applyClassWeights(costProducts, s.ClassWeights)
 	res := anydiff.SumCols(&anydiff.Matrix{
 		Data: costProducts,
 		Rows: n,
@@ -232,3 +250,272 @@ func (m MultiHinge) costSum(desired, actual anydiff.Res, n int) anydiff.Res {
 
 	return anydiff.SumCols(&anydiff.Matrix{Data: subCosts, Rows: n, Cols: cols})
 }
+
+// WeightedMultiHinge is a MultiHinge with a weight for
+// each class.
+//
+// The cost for each sample is the cost from the
+// MultiHinge, scaled by the weight of the desired class.
+type WeightedMultiHinge struct {
+	Hinge MultiHinge
+
+	// ClassWeights specifies the weight for each class.
+	// It should have one component per class.
+	ClassWeights anyvec.Vector
+}
+
+// Cost computes the weighted hinge loss for each batch.
+//
+// The desired vectors are in the same format as for
+// MultiHinge.
+func (w *WeightedMultiHinge) Cost(desired, actual anydiff.Res, n int) anydiff.Res {
+	return anydiff.Pool(desired, func(desired anydiff.Res) anydiff.Res {
+		weights := anydiff.SumCols(&anydiff.Matrix{
+			Data: applyClassWeights(desired, w.ClassWeights),
+			Rows: n,
+			Cols: desired.Output().Len() / n,
+		})
+		return anydiff.Mul(w.Hinge.Cost(desired, actual, n), weights)
+	})
+}
+
+// applyClassWeights scales the columns of a batch of
+// per-component values by the class weights.
+//
+// If weights is nil, in is returned unchanged.
+func applyClassWeights(in anydiff.Res, weights anyvec.Vector) anydiff.Res {
+	if weights == nil {
+		return in
+	}
+	if in.Output().Len()%weights.Len() != 0 {
+		panic("class weight count must divide output size")
+	}
+	return anydiff.ScaleRepeated(in, anydiff.NewConst(weights))
+}
+
+// SoftmaxFocal implements the focal loss for multi-class
+// classification.
+// For details, see https://arxiv.org/abs/1708.02002.
+//
+// Like DotCost, this is meant to be used with LogSoftmax
+// activations.
+// Each term of the cross-entropy loss is scaled by
+// (1-p)^Gamma, where p is the predicted probability of
+// the corresponding class.
+// With a Gamma of 0, this is equivalent to DotCost.
+type SoftmaxFocal struct {
+	Gamma float64
+}
+
+// Cost computes the focal loss for each batch, given
+// log probabilities as the actual output.
+func (s SoftmaxFocal) Cost(desired, actual anydiff.Res, n int) anydiff.Res {
+	c := actual.Output().Creator()
+	products := anydiff.Pool(actual, func(actual anydiff.Res) anydiff.Res {
+		modulator := anydiff.Pow(anydiff.Complement(anydiff.Exp(actual)),
+			c.MakeNumeric(s.Gamma))
+		return anydiff.Mul(desired, anydiff.Mul(modulator, actual))
+	})
+	sums := anydiff.SumCols(&anydiff.Matrix{
+		Data: products,
+		Rows: n,
+		Cols: products.Output().Len() / n,
+	})
+	return anydiff.Scale(sums, c.MakeNumeric(-1))
+}
+
+// SigmoidFocal implements the focal loss for binary
+// classification.
+// For details, see https://arxiv.org/abs/1708.02002.
+//
+// Like SigmoidCE, it takes logits as the actual output and
+// applies the sigmoid implicitly.
+// The cross-entropy loss for each component is scaled by
+// (1-q)^Gamma, where q is the probability assigned to the
+// desired label.
+// With a Gamma of 0 and an Alpha of 0, this is equivalent
+// to SigmoidCE.
+type SigmoidFocal struct {
+	Gamma float64
+
+	// Alpha is the weight for positive labels.
+	// Negative labels are weighted by 1-Alpha.
+	// If it is 0, no weighting is used.
+	Alpha float64
+
+	// Average indicates whether or not the cost should be
+	// an average rather than a sum.
+	Average bool
+}
+
+// Cost computes the focal loss for each batch.
+func (s SigmoidFocal) Cost(desired, actual anydiff.Res, n int) anydiff.Res {
+	c := actual.Output().Creator()
+	gamma := c.MakeNumeric(s.Gamma)
+	costProducts := anydiff.Pool(desired, func(desired anydiff.Res) anydiff.Res {
+		return anydiff.Pool(actual, func(actual anydiff.Res) anydiff.Res {
+			negActual := anydiff.Scale(actual, c.MakeNumeric(-1))
+			posTerm := anydiff.Mul(
+				anydiff.Pow(anydiff.Sigmoid(negActual), gamma),
+				anydiff.LogSigmoid(actual),
+			)
+			negTerm := anydiff.Mul(
+				anydiff.Pow(anydiff.Sigmoid(actual), gamma),
+				anydiff.LogSigmoid(negActual),
+			)
+			if s.Alpha != 0 {
+				posTerm = anydiff.Scale(posTerm, c.MakeNumeric(s.Alpha))
+				negTerm = anydiff.Scale(negTerm, c.MakeNumeric(1-s.Alpha))
+			}
+			return anydiff.Add(
+				anydiff.Mul(desired, posTerm),
+				anydiff.Mul(anydiff.Complement(desired), negTerm),
+			)
+		})
+	})
+	res := anydiff.SumCols(&anydiff.Matrix{
+		Data: costProducts,
+		Rows: n,
+		Cols: actual.Output().Len() / n,
+	})
+	d := -1.0
+	if s.Average {
+		d /= float64(actual.Output().Len() / n)
+	}
+	return anydiff.Scale(res, c.MakeNumeric(d))
+}
+
+// LabelSmoothing is a DotCost which smooths the desired
+// distribution before computing the cross-entropy.
+// For details, see https://arxiv.org/abs/1512.00567.
+//
+// The desired probabilities are replaced by
+//
+//     (1-Epsilon)*desired + Epsilon/k
+//
+// where k is the number of classes.
+// Like DotCost, this is meant to be used with LogSoftmax
+// activations.
+type LabelSmoothing struct {
+	Epsilon float64
+}
+
+// Cost computes the cross-entropy between the smoothed
+// desired outputs and the actual log probabilities.
+func (l LabelSmoothing) Cost(desired, actual anydiff.Res, n int) anydiff.Res {
+	c := desired.Output().Creator()
+	numClasses := desired.Output().Len() / n
+	smoothed := anydiff.AddScalar(
+		anydiff.Scale(desired, c.MakeNumeric(1-l.Epsilon)),
+		c.MakeNumeric(l.Epsilon/float64(numClasses)),
+	)
+	return DotCost{}.Cost(smoothed, actual, n)
+}
+
+// KLDivergence computes the KL divergence from the desired
+// distributions to the actual distributions.
+//
+// The desired outputs are probabilities, and the actual
+// outputs are log probabilities, such as the outputs of a
+// LogSoftmax.
+// Desired probabilities of 0 contribute nothing to the
+// divergence.
+//
+// The desired outputs are treated as constants, so no
+// gradients are propagated through them.
+type KLDivergence struct{}
+
+// Cost computes the KL divergence for each batch.
+func (k KLDivergence) Cost(desired, actual anydiff.Res, n int) anydiff.Res {
+	c := desired.Output().Creator()
+
+	// Compute log(desired), replacing log(0) with 0.
+	logDesired := desired.Output().Copy()
+	zeroMask := logDesired.Copy()
+	anyvec.EqualTo(zeroMask, c.MakeNumeric(0))
+	logDesired.Add(zeroMask)
+	anyvec.Log(logDesired)
+
+	desiredConst := anydiff.NewConst(desired.Output())
+	products := anydiff.Mul(desiredConst, anydiff.Sub(anydiff.NewConst(logDesired), actual))
+	return anydiff.SumCols(&anydiff.Matrix{
+		Data: products,
+		Rows: n,
+		Cols: products.Output().Len() / n,
+	})
+}
+
+// Huber implements the Huber loss, which is quadratic for
+// small errors and linear for large errors.
+//
+// For each component, let x be the difference between the
+// actual and desired output.
+// The loss is 0.5*x^2 if |x| <= Delta, and
+// Delta*(|x| - 0.5*Delta) otherwise.
+// With a Delta of 1, this is the smooth L1 loss.
+//
+// Like MSE, the loss for each batch is the mean of the
+// loss for each component.
+type Huber struct {
+	// Delta is the threshold at which the loss becomes
+	// linear.
+	// If it is 0, 1 is used.
+	Delta float64
+}
+
+// Cost computes the mean Huber loss for each batch.
+func (h Huber) Cost(desired, actual anydiff.Res, n int) anydiff.Res {
+	c := actual.Output().Creator()
+	delta := h.Delta
+	if delta == 0 {
+		delta = 1
+	}
+	losses := anydiff.Pool(absDiff(desired, actual), func(abs anydiff.Res) anydiff.Res {
+		// The absolute error, clipped to delta.
+		clipped := anydiff.Sub(
+			abs,
+			anydiff.ClipPos(anydiff.AddScalar(abs, c.MakeNumeric(-delta))),
+		)
+		return anydiff.Pool(clipped, func(clipped anydiff.Res) anydiff.Res {
+			return anydiff.Add(
+				anydiff.Scale(anydiff.Square(clipped), c.MakeNumeric(0.5)),
+				anydiff.Scale(anydiff.Sub(abs, clipped), c.MakeNumeric(delta)),
+			)
+		})
+	})
+	return meanCols(losses, n)
+}
+
+// AbsError evaluates cost as the mean absolute difference
+// between the actual and desired output components.
+type AbsError struct{}
+
+// Cost computes, for each output, the mean absolute
+// difference between the actual and desired output value.
+func (a AbsError) Cost(desired, actual anydiff.Res, n int) anydiff.Res {
+	return meanCols(absDiff(desired, actual), n)
+}
+
+// absDiff computes |actual-desired| component-wise.
+func absDiff(desired, actual anydiff.Res) anydiff.Res {
+	minusOne := actual.Output().Creator().MakeNumeric(-1)
+	return anydiff.Pool(anydiff.Sub(actual, desired), func(diff anydiff.Res) anydiff.Res {
+		return anydiff.Add(
+			anydiff.ClipPos(diff),
+			anydiff.ClipPos(anydiff.Scale(diff, minusOne)),
+		)
+	})
+}
+
+// meanCols averages the components of each vector in a
+// batch.
+func meanCols(in anydiff.Res, n int) anydiff.Res {
+	numComps := in.Output().Len() / n
+	sum := anydiff.SumCols(&anydiff.Matrix{
+		Data: in,
+		Rows: n,
+		Cols: numComps,
+	})
+	normalizer := 1.0 / float64(numComps)
+	return anydiff.Scale(sum, sum.Output().Creator().MakeNumeric(normalizer))
+}
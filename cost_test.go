@@ -7,6 +7,7 @@ import (
 	"github.com/unixpickle/anydiff"
 	"github.com/unixpickle/anydiff/anydifftest"
 	"github.com/unixpickle/anyvec/anyvec32"
+	"github.com/unixpickle/anyvec/anyvec64"
 )
 
 func TestDotCost(t *testing.T) {
@@ -19,6 +20,17 @@ func TestDotCost(t *testing.T) {
 	}, []float32{8, 5}, 2)
 }
 
+func TestDotCostClassWeights(t *testing.T) {
+	weights := anyvec32.MakeVectorData([]float32{1, 2, 0.5})
+	testCost(t, DotCost{ClassWeights: weights}, []float32{
+		1, 0.5, 2,
+		3, -1, 2,
+	}, []float32{
+		-1, -2, -3,
+		-2, -3, -1,
+	}, []float32{1 + 2 + 3, 6 - 6 + 1}, 2)
+}
+
 func TestMSE(t *testing.T) {
 	testCost(t, MSE{}, []float32{
 		1, 0.5, 2,
@@ -56,6 +68,30 @@ func TestSigmoidCE(t *testing.T) {
 	})
 }
 
+func TestSigmoidCEClassWeights(t *testing.T) {
+	weights := anyvec32.MakeVectorData([]float32{2, 0.5})
+	testCost(t, SigmoidCE{ClassWeights: weights}, []float32{
+		1, 0.6,
+		0.2, 0,
+	}, []float32{
+		1, 0,
+		2, -1,
+	}, []float32{
+		2*0.3132616875 + 0.5*0.6931471806,
+		2*(0.02538560221+1.7015424088) + 0.5*0.3132616875,
+	}, 2)
+	testCost(t, SigmoidCE{ClassWeights: weights, Average: true}, []float32{
+		1, 0.6,
+		0.2, 0,
+	}, []float32{
+		1, 0,
+		2, -1,
+	}, []float32{
+		(2*0.3132616875 + 0.5*0.6931471806) / 2,
+		(2*(0.02538560221+1.7015424088) + 0.5*0.3132616875) / 2,
+	}, 2)
+}
+
 func TestHinge(t *testing.T) {
 	testCost(t, Hinge{}, []float32{
 		1, -1, -1, 1, 1, 1, -1, -1,
@@ -93,6 +129,21 @@ func TestMultiHinge(t *testing.T) {
 	})
 }
 
+func TestWeightedMultiHinge(t *testing.T) {
+	weights := anyvec32.MakeVectorData([]float32{3, 2, 0.5})
+	testCost(t, &WeightedMultiHinge{Hinge: WestonWatkins, ClassWeights: weights}, []float32{
+		0, 1, 0,
+		0, 0, 1,
+		1, 0, 0,
+	}, []float32{
+		1, 2.5, 2,
+		-2, -5, -3,
+		-5, -2, -3,
+	}, []float32{
+		0.5 * 2, 2 * 0.5, 7 * 3,
+	}, 3)
+}
+
 func TestMultiHingeProp(t *testing.T) {
 	v1 := anydiff.NewVar(anyvec32.MakeVectorData([]float32{1, 2, 2.5, 3, 3.5, 4, 4.5, 5}))
 	v2 := anydiff.NewVar(anyvec32.MakeVectorData([]float32{0, 0, 1, 0, 1, 0, 0, 0}))
@@ -118,6 +169,123 @@ func TestMultiHingeProp(t *testing.T) {
 	})
 }
 
+func TestSoftmaxFocal(t *testing.T) {
+	testCost(t, SoftmaxFocal{Gamma: 2}, []float32{
+		0, 1, 0,
+		1, 0, 0,
+	}, []float32{
+		-1.6094379, -0.6931472, -1.2039728,
+		-0.3566749, -1.6094379, -2.3025851,
+	}, []float32{0.1732868, 0.0321007}, 2)
+}
+
+func TestSigmoidFocal(t *testing.T) {
+	testCost(t, SigmoidFocal{Gamma: 2, Alpha: 0.25}, []float32{
+		1, 0,
+	}, []float32{
+		1, -1,
+	}, []float32{0.0056645 + 0.0169935}, 1)
+	testCost(t, SigmoidFocal{Average: true}, []float32{
+		1, 0.6,
+		0.2, 0,
+	}, []float32{
+		1, 0,
+		2, -1,
+	}, []float32{
+		0.5 * (0.3132616875 + 0.6931471806),
+		0.5 * (0.02538560221 + 1.7015424088 + 0.3132616875),
+	}, 2)
+}
+
+func TestLabelSmoothing(t *testing.T) {
+	testCost(t, LabelSmoothing{Epsilon: 0.1}, []float32{
+		0, 1, 0,
+	}, []float32{
+		-1.6094379, -0.6931472, -1.2039728,
+	}, []float32{0.7407177}, 1)
+}
+
+func TestKLDivergence(t *testing.T) {
+	testCost(t, KLDivergence{}, []float32{
+		0.5, 0.5, 0,
+		0.2, 0.5, 0.3,
+	}, []float32{
+		-1.6094379, -0.6931472, -1.2039728,
+		-1.6094379, -0.6931472, -1.2039728,
+	}, []float32{0.4581454, 0}, 2)
+}
+
+func TestHuber(t *testing.T) {
+	testCost(t, Huber{}, []float32{
+		0, 0, 0, 0,
+		1, 1, 1, 1,
+	}, []float32{
+		0.5, -2, 1.5, -0.2,
+		1.5, -1, 2.5, 0.8,
+	}, []float32{0.66125, 0.66125}, 2)
+	testCost(t, Huber{Delta: 2}, []float32{
+		0, 0, 0, 0,
+	}, []float32{
+		0.5, -3, 1.5, -0.2,
+	}, []float32{(0.125 + 4 + 1.125 + 0.02) / 4}, 1)
+}
+
+func TestAbsError(t *testing.T) {
+	testCost(t, AbsError{}, []float32{
+		0, 0, 0, 0,
+		1, 1, 1, 1,
+	}, []float32{
+		0.5, -2, 1.5, -0.2,
+		1.5, -1, 2.5, 0.8,
+	}, []float32{1.05, 1.05}, 2)
+}
+
+func TestExtraCostProp(t *testing.T) {
+	actual := anydiff.NewVar(anyvec64.MakeVectorData([]float64{
+		0.5, -2, 1.7, -0.3,
+		1.3, -1.1, 2.5, 0.8,
+	}))
+	desired := anydiff.NewVar(anyvec64.MakeVectorData([]float64{
+		0, 1, 0, 0,
+		0.1, 0.2, 0.3, 0.4,
+	}))
+	costs := map[string]Cost{
+		"SoftmaxFocal":   SoftmaxFocal{Gamma: 2},
+		"LabelSmoothing": LabelSmoothing{Epsilon: 0.1},
+		"KLDivergence":   KLDivergence{},
+	}
+	for name, cost := range costs {
+		cost := cost
+		t.Run(name, func(t *testing.T) {
+			checker := &anydifftest.ResChecker{
+				F: func() anydiff.Res {
+					return cost.Cost(desired, anydiff.LogSoftmax(actual, 4), 2)
+				},
+				V: []*anydiff.Var{actual},
+			}
+			checker.FullCheck(t)
+		})
+	}
+
+	costs = map[string]Cost{
+		"SigmoidFocal": SigmoidFocal{Gamma: 2, Alpha: 0.25, Average: true},
+		"Huber":        Huber{Delta: 1},
+		"AbsError":     AbsError{},
+	}
+	for name, cost := range costs {
+		cost := cost
+		t.Run(name, func(t *testing.T) {
+			checker := &anydifftest.ResChecker{
+				F: func() anydiff.Res {
+					return cost.Cost(desired, actual, 2)
+				},
+				V: []*anydiff.Var{actual, desired},
+			}
+			checker.FullCheck(t)
+		})
+	}
+}
+
 func testCost(t *testing.T, c Cost, desired, output, expected []float32, n int) {
 	desiredRes := anydiff.NewConst(anyvec32.MakeVectorData(desired))
 	outputRes := anydiff.NewConst(anyvec32.MakeVectorData(output))
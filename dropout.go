@@ -1,6 +1,8 @@
 package anynet
 
 import (
+	"math/rand"
+
 	"github.com/unixpickle/anydiff"
 	"github.com/unixpickle/anyvec"
 	"github.com/unixpickle/essentials"
@@ -20,6 +22,10 @@ type Dropout struct {
 
 	// The probability of keeping any given input.
 	KeepProb float64
+
+	// Rand, if non-nil, is used to generate masks.
+	// Otherwise, the global source from math/rand is used.
+	Rand *rand.Rand
 }
 
 // DeserializeDropout deserializes a Dropout.
@@ -42,11 +48,16 @@ func (d *Dropout) Apply(in anydiff.Res, n int) anydiff.Res {
 		return anydiff.Scale(in, c.MakeNumeric(d.KeepProb))
 	}
 	mask := c.MakeVector(in.Output().Len())
-	anyvec.Rand(mask, anyvec.Uniform, nil)
+	anyvec.Rand(mask, anyvec.Uniform, d.Rand)
 	anyvec.LessThan(mask, c.MakeNumeric(d.KeepProb))
 	return anydiff.Mul(in, anydiff.NewConst(mask))
 }
 
+// SetRand sets d.Rand.
+func (d *Dropout) SetRand(r *rand.Rand) {
+	d.Rand = r
+}
+
 // SerializerType returns the unique ID used to serialize
 // a Dropout with the serializer package.
 func (d *Dropout) SerializerType() string {
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"math/rand"
 
 	"github.com/unixpickle/anydiff"
 	"github.com/unixpickle/anyvec"
@@ -48,8 +49,15 @@ func DeserializeFC(d []byte) (*FC, error) {
 // The randomization scheme targets an output variance of
 // 1, given that the input variance is 1.
 func NewFC(c anyvec.Creator, in, out int) *FC {
+	return NewFCRand(c, in, out, nil)
+}
+
+// NewFCRand is like NewFC, but it uses the given source
+// of randomness.
+// If r is nil, the global source from math/rand is used.
+func NewFCRand(c anyvec.Creator, in, out int, r *rand.Rand) *FC {
 	res := NewFCZero(c, in, out)
-	anyvec.Rand(res.Weights.Vector, anyvec.Normal, nil)
+	anyvec.Rand(res.Weights.Vector, anyvec.Normal, r)
 	res.Weights.Vector.Scale(c.MakeNumeric(1 / math.Sqrt(float64(in))))
 	return res
 }
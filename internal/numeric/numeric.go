@@ -0,0 +1,28 @@
+// Package numeric contains helpers for the numeric types
+// used by anyvec, shared between the anynet packages.
+package numeric
+
+import (
+	"fmt"
+
+	"github.com/unixpickle/anyvec"
+)
+
+// Float64s converts a numeric list, such as the result
+// of anyvec.Vector.Data, to a []float64.
+//
+// A []float64 is returned as-is rather than being copied.
+func Float64s(data anyvec.NumericList) []float64 {
+	switch data := data.(type) {
+	case []float64:
+		return data
+	case []float32:
+		res := make([]float64, len(data))
+		for i, x := range data {
+			res[i] = float64(x)
+		}
+		return res
+	default:
+		panic(fmt.Sprintf("unsupported numeric type: %T", data))
+	}
+}
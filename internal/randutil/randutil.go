@@ -0,0 +1,32 @@
+// Package randutil contains helpers for code which accepts
+// an optional source of randomness.
+//
+// Every function uses the given *rand.Rand if it is
+// non-nil, or the global source from math/rand otherwise.
+package randutil
+
+import "math/rand"
+
+// Intn is like rand.Intn.
+func Intn(r *rand.Rand, n int) int {
+	if r == nil {
+		return rand.Intn(n)
+	}
+	return r.Intn(n)
+}
+
+// Perm is like rand.Perm.
+func Perm(r *rand.Rand, n int) []int {
+	if r == nil {
+		return rand.Perm(n)
+	}
+	return r.Perm(n)
+}
+
+// Float64 is like rand.Float64.
+func Float64(r *rand.Rand) float64 {
+	if r == nil {
+		return rand.Float64()
+	}
+	return r.Float64()
+}
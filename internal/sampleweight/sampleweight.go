@@ -0,0 +1,45 @@
+// Package sampleweight implements the per-sample weights
+// used by the trainers in anyff, anys2s, and anys2v.
+//
+// A sample weight of 0 means that no weight was set, so
+// it is treated as a weight of 1.
+// This way, samples with a zero Weight field train the
+// same as they would without weighting.
+package sampleweight
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+)
+
+// Value returns the weight to use for a sample whose
+// Weight field is w.
+func Value(w float64) float64 {
+	if w == 0 {
+		return 1
+	}
+	return w
+}
+
+// Pack creates a weight vector for a batch of samples and
+// computes its sum.
+// Each weight is passed through Value, and the weights
+// slice itself is not modified.
+//
+// If every weight is 1, nil is returned.
+func Pack(c anyvec.Creator, weights []float64) (*anydiff.Const, float64) {
+	values := make([]float64, len(weights))
+	var sum float64
+	var weighted bool
+	for i, w := range weights {
+		values[i] = Value(w)
+		if values[i] != 1 {
+			weighted = true
+		}
+		sum += values[i]
+	}
+	if !weighted {
+		return nil, 0
+	}
+	return anydiff.NewConst(c.MakeVectorData(c.MakeNumericList(values))), sum
+}
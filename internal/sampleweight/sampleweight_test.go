@@ -0,0 +1,35 @@
+package sampleweight
+
+import (
+	"testing"
+
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestPack(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+
+	if vec, sum := Pack(c, []float64{0, 1, 0}); vec != nil || sum != 0 {
+		t.Errorf("expected no weights but got %v (sum %f)", vec, sum)
+	}
+
+	weights := []float64{0, 2, 0.5}
+	vec, sum := Pack(c, weights)
+	if vec == nil {
+		t.Fatal("expected weights")
+	}
+	expected := []float64{1, 2, 0.5}
+	actual := vec.Output().Data().([]float64)
+	for i, x := range expected {
+		if actual[i] != x {
+			t.Errorf("expected %v but got %v", expected, actual)
+			break
+		}
+	}
+	if sum != 3.5 {
+		t.Errorf("expected sum 3.5 but got %f", sum)
+	}
+	if weights[0] != 0 {
+		t.Error("weights slice was modified")
+	}
+}
@@ -0,0 +1,287 @@
+package anynet
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+)
+
+// metricEpsilon is added to squared distances before they
+// are square rooted, preventing infinite gradients.
+const metricEpsilon = 1e-8
+
+// A MetricCost measures the error of a batch of embeddings
+// by comparing the embeddings to each other.
+//
+// Labels specify how the embeddings are related.
+// Embeddings with equal labels are positives for each
+// other, while embeddings with different labels are
+// negatives for each other.
+//
+// The embeddings are packed into a single vector, and n
+// specifies the number of embeddings.
+// The result contains one cost per embedding, where each
+// embedding acts as an anchor.
+type MetricCost interface {
+	MetricCost(labels []int, embeddings anydiff.Res, n int) anydiff.Res
+}
+
+// Contrastive implements the contrastive loss.
+// For details, see
+// http://yann.lecun.com/exdb/publis/pdf/hadsell-chopra-lecun-06.pdf.
+//
+// For each pair of embeddings, let d be the Euclidean
+// distance between them.
+// Positive pairs cost d^2, while negative pairs cost
+// max(0, Margin-d)^2.
+// The cost for each anchor is the mean cost of the pairs
+// it is in.
+type Contrastive struct {
+	Margin float64
+}
+
+// MetricCost computes the contrastive loss for each
+// anchor.
+func (c Contrastive) MetricCost(labels []int, embeddings anydiff.Res,
+	n int) anydiff.Res {
+	cr := embeddings.Output().Creator()
+	if n == 1 {
+		return anydiff.NewConst(cr.MakeVector(1))
+	}
+	masks := newMetricMasks(cr, labels, n)
+	pairCosts := anydiff.Pool(squaredDistances(embeddings, n), func(sq anydiff.Res) anydiff.Res {
+		dist := euclideanDistances(sq)
+		margins := anydiff.ClipPos(anydiff.AddScalar(
+			anydiff.Scale(dist, cr.MakeNumeric(-1)),
+			cr.MakeNumeric(c.Margin),
+		))
+		return anydiff.Add(
+			anydiff.Mul(anydiff.NewConst(masks.Pos), anydiff.ClipPos(sq)),
+			anydiff.Mul(anydiff.NewConst(masks.Neg), anydiff.Square(margins)),
+		)
+	})
+	sums := anydiff.SumCols(&anydiff.Matrix{Data: pairCosts, Rows: n, Cols: n})
+	return anydiff.Scale(sums, cr.MakeNumeric(1/float64(n-1)))
+}
+
+// BatchHardTriplet implements the triplet loss with
+// batch-hard mining.
+// For details, see https://arxiv.org/abs/1703.07737.
+//
+// For each anchor, the farthest positive and the nearest
+// negative are found in the batch, and the cost is
+//
+//     max(0, Margin + d(anchor, pos) - d(anchor, neg))
+//
+// where d is the Euclidean distance.
+//
+// Anchors without any positives or without any negatives
+// in the batch have a cost of 0.
+type BatchHardTriplet struct {
+	Margin float64
+}
+
+// MetricCost computes the triplet loss for each anchor.
+func (b BatchHardTriplet) MetricCost(labels []int, embeddings anydiff.Res,
+	n int) anydiff.Res {
+	cr := embeddings.Output().Creator()
+	masks := newMetricMasks(cr, labels, n)
+	valid := masks.HasPos.Copy()
+	valid.Mul(masks.HasNeg)
+
+	dists := euclideanDistances(squaredDistances(embeddings, n))
+	return anydiff.Pool(dists, func(dists anydiff.Res) anydiff.Res {
+		// Distances are non-negative, so masked out entries
+		// will never be the unique maximum.
+		posDists := dists.Output().Copy()
+		posDists.Mul(masks.Pos)
+		hardPos := anydiff.Map(anyvec.MapMax(posDists, n), dists)
+
+		// 1/(1+d) is maximized by the nearest negative, and
+		// it is positive for every negative.
+		negScores := dists.Output().Copy()
+		negScores.AddScalar(cr.MakeNumeric(1))
+		anyvec.Pow(negScores, cr.MakeNumeric(-1))
+		negScores.Mul(masks.Neg)
+		hardNeg := anydiff.Map(anyvec.MapMax(negScores, n), dists)
+
+		losses := anydiff.ClipPos(anydiff.AddScalar(
+			anydiff.Sub(hardPos, hardNeg),
+			cr.MakeNumeric(b.Margin),
+		))
+		return anydiff.Mul(losses, anydiff.NewConst(valid))
+	})
+}
+
+// InfoNCE implements the InfoNCE loss, where the other
+// embeddings in the batch serve as negatives.
+// For details, see https://arxiv.org/abs/1807.03748.
+//
+// The similarity between two embeddings is their dot
+// product divided by Temperature.
+// For each anchor, a softmax is taken over the
+// similarities to every other embedding, and the cost is
+// the negative log of the total probability assigned to
+// the anchor's positives.
+//
+// With Cosine set, the embeddings are normalized before
+// similarities are computed.
+// This gives the NT-Xent loss from
+// https://arxiv.org/abs/2002.05709 when every label is
+// shared by exactly two embeddings.
+//
+// Anchors without any positives in the batch have a cost
+// of 0.
+type InfoNCE struct {
+	// Temperature scales the similarities.
+	// If it is 0, 1 is used.
+	Temperature float64
+
+	Cosine bool
+}
+
+// MetricCost computes the InfoNCE loss for each anchor.
+func (i InfoNCE) MetricCost(labels []int, embeddings anydiff.Res,
+	n int) anydiff.Res {
+	cr := embeddings.Output().Creator()
+	temp := i.Temperature
+	if temp == 0 {
+		temp = 1
+	}
+	if i.Cosine {
+		embeddings = normalizeRows(embeddings, n)
+	}
+	masks := newMetricMasks(cr, labels, n)
+	noPos := masks.HasPos.Copy()
+	anyvec.Complement(noPos)
+
+	// Exclude each anchor from its own softmax.
+	selfData := make([]float64, n*n)
+	for j := 0; j < n; j++ {
+		selfData[j*n+j] = -1e9
+	}
+	selfMask := cr.MakeVectorData(cr.MakeNumericList(selfData))
+
+	sims := anydiff.Scale(gramMatrix(embeddings, n), cr.MakeNumeric(1/temp))
+	logProbs := anydiff.LogSoftmax(anydiff.Add(sims, anydiff.NewConst(selfMask)), n)
+	posProbs := anydiff.SumCols(&anydiff.Matrix{
+		Data: anydiff.Mul(anydiff.Exp(logProbs), anydiff.NewConst(masks.Pos)),
+		Rows: n,
+		Cols: n,
+	})
+	logPos := anydiff.Log(anydiff.Add(posProbs, anydiff.NewConst(noPos)))
+	return anydiff.Mul(anydiff.Scale(logPos, cr.MakeNumeric(-1)),
+		anydiff.NewConst(masks.HasPos))
+}
+
+// metricMasks stores information about the relations
+// between embeddings in a batch.
+type metricMasks struct {
+	// Pos and Neg are n-by-n matrices indicating which
+	// pairs of embeddings are positives and negatives.
+	// An embedding is never its own positive.
+	Pos anyvec.Vector
+	Neg anyvec.Vector
+
+	// HasPos and HasNeg indicate which anchors have at
+	// least one positive or negative.
+	HasPos anyvec.Vector
+	HasNeg anyvec.Vector
+}
+
+func newMetricMasks(c anyvec.Creator, labels []int, n int) *metricMasks {
+	if len(labels) != n {
+		panic("label count must match embedding count")
+	}
+	pos := make([]float64, n*n)
+	neg := make([]float64, n*n)
+	hasPos := make([]float64, n)
+	hasNeg := make([]float64, n)
+	for i, l1 := range labels {
+		for j, l2 := range labels {
+			if l1 != l2 {
+				neg[i*n+j] = 1
+				hasNeg[i] = 1
+			} else if i != j {
+				pos[i*n+j] = 1
+				hasPos[i] = 1
+			}
+		}
+	}
+	vec := func(data []float64) anyvec.Vector {
+		return c.MakeVectorData(c.MakeNumericList(data))
+	}
+	return &metricMasks{
+		Pos:    vec(pos),
+		Neg:    vec(neg),
+		HasPos: vec(hasPos),
+		HasNeg: vec(hasNeg),
+	}
+}
+
+// gramMatrix computes the n-by-n matrix of dot products
+// between embeddings.
+func gramMatrix(embeddings anydiff.Res, n int) anydiff.Res {
+	return anydiff.Pool(embeddings, func(embeddings anydiff.Res) anydiff.Res {
+		m := &anydiff.Matrix{
+			Data: embeddings,
+			Rows: n,
+			Cols: embeddings.Output().Len() / n,
+		}
+		return anydiff.MatMul(false, true, m, m).Data
+	})
+}
+
+// squaredDistances computes the n-by-n matrix of squared
+// Euclidean distances between embeddings.
+func squaredDistances(embeddings anydiff.Res, n int) anydiff.Res {
+	c := embeddings.Output().Creator()
+	return anydiff.Pool(embeddings, func(embeddings anydiff.Res) anydiff.Res {
+		norms := anydiff.SumCols(&anydiff.Matrix{
+			Data: anydiff.Square(embeddings),
+			Rows: n,
+			Cols: embeddings.Output().Len() / n,
+		})
+		return anydiff.Pool(norms, func(norms anydiff.Res) anydiff.Res {
+			ones := c.MakeVector(n)
+			ones.AddScalar(c.MakeNumeric(1))
+			rowNorms := anydiff.MatMul(false, false,
+				&anydiff.Matrix{Data: norms, Rows: n, Cols: 1},
+				&anydiff.Matrix{Data: anydiff.NewConst(ones), Rows: 1, Cols: n},
+			).Data
+			gram := anydiff.Scale(gramMatrix(embeddings, n), c.MakeNumeric(-2))
+			return anydiff.AddRepeated(anydiff.Add(gram, rowNorms), norms)
+		})
+	})
+}
+
+// euclideanDistances computes distances from squared
+// distances.
+func euclideanDistances(sq anydiff.Res) anydiff.Res {
+	c := sq.Output().Creator()
+	return anydiff.Pow(
+		anydiff.AddScalar(anydiff.ClipPos(sq), c.MakeNumeric(metricEpsilon)),
+		c.MakeNumeric(0.5),
+	)
+}
+
+// normalizeRows scales each embedding to unit length.
+func normalizeRows(embeddings anydiff.Res, n int) anydiff.Res {
+	c := embeddings.Output().Creator()
+	return anydiff.Pool(embeddings, func(embeddings anydiff.Res) anydiff.Res {
+		cols := embeddings.Output().Len() / n
+		norms := anydiff.SumCols(&anydiff.Matrix{
+			Data: anydiff.Square(embeddings),
+			Rows: n,
+			Cols: cols,
+		})
+		scales := anydiff.Pow(
+			anydiff.AddScalar(norms, c.MakeNumeric(metricEpsilon)),
+			c.MakeNumeric(-0.5),
+		)
+		return anydiff.ScaleRows(&anydiff.Matrix{
+			Data: embeddings,
+			Rows: n,
+			Cols: cols,
+		}, scales).Data
+	})
+}
@@ -0,0 +1,70 @@
+package anynet
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anydifftest"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestContrastive(t *testing.T) {
+	testMetricCost(t, Contrastive{Margin: 2.5}, []int{0, 0, 1},
+		[]float64{0, 1, 3}, []float64{0.5, 0.625, 0.125})
+}
+
+func TestBatchHardTriplet(t *testing.T) {
+	testMetricCost(t, BatchHardTriplet{Margin: 1}, []int{0, 0, 1, 1},
+		[]float64{0, 1, 3, 0.6}, []float64{1.4, 1.6, 1.4, 3})
+}
+
+func TestInfoNCE(t *testing.T) {
+	for _, cosine := range []bool{false, true} {
+		testMetricCost(t, InfoNCE{Cosine: cosine}, []int{0, 0, 1},
+			[]float64{1, 0, 1, 0, 0, 1}, []float64{0.3132617, 0.3132617, 0})
+	}
+}
+
+func TestMetricCostProp(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	vec := c.MakeVector(6 * 3)
+	anyvec.Rand(vec, anyvec.Normal, nil)
+	embeddings := anydiff.NewVar(vec)
+	labels := []int{0, 1, 0, 2, 1, 0}
+
+	costs := map[string]MetricCost{
+		"Contrastive":      Contrastive{Margin: 2},
+		"BatchHardTriplet": BatchHardTriplet{Margin: 1},
+		"InfoNCE":          InfoNCE{Temperature: 0.5},
+		"NTXent":           InfoNCE{Temperature: 0.5, Cosine: true},
+	}
+	for name, cost := range costs {
+		cost := cost
+		t.Run(name, func(t *testing.T) {
+			checker := &anydifftest.ResChecker{
+				F: func() anydiff.Res {
+					return cost.MetricCost(labels, embeddings, 6)
+				},
+				V: []*anydiff.Var{embeddings},
+			}
+			checker.FullCheck(t)
+		})
+	}
+}
+
+func testMetricCost(t *testing.T, c MetricCost, labels []int, embeddings,
+	expected []float64) {
+	embRes := anydiff.NewConst(anyvec64.MakeVectorData(embeddings))
+	actual := c.MetricCost(labels, embRes, len(labels)).Output().Data().([]float64)
+	if len(actual) != len(expected) {
+		t.Fatalf("expected %d costs but got %d", len(expected), len(actual))
+	}
+	for i, x := range expected {
+		a := actual[i]
+		if math.IsNaN(a) || math.Abs(x-a) > 1e-3 {
+			t.Errorf("anchor %d: expected %f but got %f", i, x, a)
+		}
+	}
+}
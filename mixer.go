@@ -1,6 +1,8 @@
 package anynet
 
 import (
+	"math/rand"
+
 	"github.com/unixpickle/anydiff"
 	"github.com/unixpickle/essentials"
 	"github.com/unixpickle/serializer"
@@ -52,6 +54,12 @@ func (a *AddMixer) Parameters() []*anydiff.Var {
 	return AllParameters(a.In1, a.In2, a.Out)
 }
 
+// SetRand sets the source of randomness for all the
+// layers that implement RandSetter.
+func (a *AddMixer) SetRand(r *rand.Rand) {
+	SetAllRand(r, a.In1, a.In2, a.Out)
+}
+
 // SerializerType returns the unique ID used to serialize
 // an AddMixer with the serializer package.
 func (a *AddMixer) SerializerType() string {
@@ -1,6 +1,8 @@
 package anynet
 
 import (
+	"math/rand"
+
 	"github.com/unixpickle/anydiff"
 	"github.com/unixpickle/anydiff/anyfwd"
 	"github.com/unixpickle/essentials"
@@ -33,6 +35,15 @@ func (p *ParamHider) Apply(in anydiff.Res, n int) anydiff.Res {
 	return p.Layer.Apply(in, n)
 }
 
+// SetRand sets the source of randomness for the layer if
+// it is a RandSetter.
+//
+// Unlike the parameters, the randomness of the layer is
+// not hidden.
+func (p *ParamHider) SetRand(r *rand.Rand) {
+	SetAllRand(r, p.Layer)
+}
+
 // SerializerType returns the unique ID used to serialize
 // a ParamHider with the serializer package.
 func (p *ParamHider) SerializerType() string {